@@ -0,0 +1,72 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+
+	github "go.okkur.org/gomiler/github"
+	gitlab "go.okkur.org/gomiler/gitlab"
+	"go.okkur.org/gomiler/utils"
+)
+
+// runAdopt marks existing milestones following the title format as managed by gomiler
+func runAdopt(args []string) {
+	var o options
+	fs := flag.NewFlagSet("adopt", flag.ExitOnError)
+	o.registerFlags(fs)
+	fs.Parse(args)
+
+	if err := utils.ValidateOutputFormat(o.output); err != nil {
+		logger.Fatal(err)
+	}
+	titleFormat, err := o.format()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if err := o.resolveToken(); err != nil {
+		logger.Fatal(err)
+	}
+	ctx, cancel := o.runContext()
+	defer cancel()
+	t, err := connect(ctx, o)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	var adoptableMilestones, adoptedMilestones map[string]utils.Milestone
+	switch t.api {
+	case "gitlab":
+		adoptableMilestones, err = gitlab.GetAdoptableMilestones(ctx, t.baseURL, o.token, t.project, titleFormat)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		adoptedMilestones, err = gitlab.AdoptMilestones(ctx, adoptableMilestones, t.baseURL, o.token, t.project)
+	case "github":
+		adoptableMilestones, err = github.GetAdoptableMilestones(ctx, t.baseURL, o.token, t.project, titleFormat)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		adoptedMilestones, err = github.AdoptMilestones(ctx, adoptableMilestones, t.baseURL, o.token, t.project)
+	}
+	result := utils.Result{Adopted: utils.MilestoneResults(adoptedMilestones)}
+	if err != nil {
+		result.Failed = utils.ReasonResults(failureReasons(err, adoptableMilestones), adoptableMilestones)
+	}
+	writeResult(result, o.output)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.okkur.org/gomiler/utils"
+)
+
+// apiProject is a configured project listed by the API
+type apiProject struct {
+	Name string `json:"name"`
+	// Error of listing the projects of a namespace
+	Error string `json:"error,omitempty"`
+}
+
+// applyRequest is the body of an apply request
+type applyRequest struct {
+	Project string     `json:"project"`
+	Plan    utils.Plan `json:"plan"`
+}
+
+// writeJSON writes v as JSON response with the status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		logger.Println(err)
+	}
+}
+
+// writeAPIError writes an error as JSON response with the status code
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+// apiStatus returns the status of a response failing with err
+func apiStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, utils.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, utils.ErrRateLimited):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusBadGateway
+}
+
+// authorized only passes requests with the bearer token of the API to next, once the daemon is ready
+func (d *daemon) authorized(method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(header, "Bearer ")
+		if token == header || subtle.ConstantTimeCompare([]byte(token), []byte(d.apiToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAPIError(w, http.StatusUnauthorized, fmt.Errorf("invalid bearer token"))
+			return
+		}
+		if !d.isReady() {
+			writeAPIError(w, http.StatusServiceUnavailable, fmt.Errorf("not ready"))
+			return
+		}
+		if r.Method != method {
+			writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// configSnapshot returns the current configuration
+func (d *daemon) configSnapshot() utils.Config {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.config
+}
+
+// findRun returns the run of a configured project by its path
+func (d *daemon) findRun(ctx context.Context, c utils.Config, name string) (projectRun, int, error) {
+	if name == "" {
+		return projectRun{}, http.StatusBadRequest, fmt.Errorf("project is required")
+	}
+	providers := map[string]bool{}
+	for _, p := range c.Providers {
+		providers[p.Name] = true
+	}
+	runs, err := projectRuns(ctx, c, d.args, providers, name)
+	if err != nil {
+		return projectRun{}, apiStatus(err), err
+	}
+	if len(runs) == 0 {
+		return projectRun{}, http.StatusNotFound, fmt.Errorf("project %s is not configured", name)
+	}
+	return runs[0], http.StatusOK, nil
+}
+
+// listProjects lists the configured projects with namespaces expanded
+func (d *daemon) listProjects(w http.ResponseWriter, r *http.Request) {
+	runs, err := configRuns(r.Context(), d.configSnapshot(), d.args)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	projects := []apiProject{}
+	for _, p := range runs {
+		project := apiProject{Name: p.name}
+		if p.err != nil {
+			project.Error = p.err.Error()
+		}
+		projects = append(projects, project)
+	}
+	writeJSON(w, http.StatusOK, projects)
+}
+
+// getPlan plans the project given by the project query parameter without applying it
+func (d *daemon) getPlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	p, status, err := d.findRun(ctx, d.configSnapshot(), r.URL.Query().Get("project"))
+	if err != nil {
+		writeAPIError(w, status, err)
+		return
+	}
+	ctx = p.o.apiContext(ctx)
+	t, err := p.target(ctx)
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+	plan, err := makePlan(ctx, t, p.o, p.r)
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// applyPlan applies a plan returned by getPlan unless the milestones changed since planning
+func (d *daemon) applyPlan(w http.ResponseWriter, r *http.Request) {
+	var req applyRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookSize)).Decode(&req)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %v", err))
+		return
+	}
+	// Not cancelled by the client going away, so a plan is not left half applied
+	ctx, cancel := d.passContext()
+	defer cancel()
+	p, status, err := d.findRun(ctx, d.configSnapshot(), req.Project)
+	if err != nil {
+		writeAPIError(w, status, err)
+		return
+	}
+	ctx = p.o.apiContext(ctx)
+	t, err := p.target(ctx)
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+
+	d.passMu.Lock()
+	defer d.passMu.Unlock()
+	start := utils.DefaultClient.Stats()
+	allMilestones, err := getAllMilestones(ctx, t, p.o.token)
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+	err = req.Plan.Check(t.api, t.baseURL, t.project, allMilestones)
+	if err == utils.ErrStalePlan {
+		writeAPIError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	result, err := applyPlan(ctx, t, p.o.token, req.Plan)
+	result.Project = p.name
+	result.Throttled = utils.DefaultClient.Stats().Since(start)
+	status = http.StatusOK
+	if err != nil {
+		result.Error = err.Error()
+		status = apiStatus(err)
+	}
+	d.record("api", []utils.Result{result})
+	writeJSON(w, status, result)
+}
+
+// listRuns lists the run history, newest first, optionally of the project given by the project query parameter
+func (d *daemon) listRuns(w http.ResponseWriter, r *http.Request) {
+	project := r.URL.Query().Get("project")
+	d.mu.Lock()
+	runs := []runRecord{}
+	for i := len(d.history) - 1; i >= 0; i-- {
+		if project == "" || d.history[i].Result.Project == project {
+			runs = append(runs, d.history[i])
+		}
+	}
+	d.mu.Unlock()
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// registerAPI mounts the REST API under /api/v1
+func (d *daemon) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/projects", d.authorized("GET", d.listProjects))
+	mux.HandleFunc("/api/v1/plan", d.authorized("GET", d.getPlan))
+	mux.HandleFunc("/api/v1/apply", d.authorized("POST", d.applyPlan))
+	mux.HandleFunc("/api/v1/runs", d.authorized("GET", d.listRuns))
+}
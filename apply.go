@@ -0,0 +1,320 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	github "go.okkur.org/gomiler/github"
+	gitlab "go.okkur.org/gomiler/gitlab"
+	"go.okkur.org/gomiler/utils"
+)
+
+// runApply applies a saved plan unless the milestones changed since planning
+func runApply(args []string) {
+	var o options
+	var planFile string
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	o.registerFlags(fs)
+	fs.StringVar(&planFile, "plan", "", "Plan file saved by gomiler plan -out")
+	fs.Parse(args)
+
+	if planFile == "" {
+		logger.Fatal("Error: -plan is required to apply a plan")
+	}
+	if err := utils.ValidateOutputFormat(o.output); err != nil {
+		logger.Fatal(err)
+	}
+	f, err := os.Open(planFile)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	plan, err := utils.LoadPlan(f)
+	f.Close()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if err := o.resolveToken(); err != nil {
+		logger.Fatal(err)
+	}
+	ctx, cancel := o.runContext()
+	defer cancel()
+	start := utils.DefaultClient.Stats()
+	t, err := connect(ctx, o)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	allMilestones, err := getAllMilestones(ctx, t, o.token)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	err = plan.Check(t.api, t.baseURL, t.project, allMilestones)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	result, err := applyPlan(ctx, t, o.token, plan)
+	result.Throttled = utils.DefaultClient.Stats().Since(start)
+	writeResult(result, o.output)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// applyPlan executes the actions of a plan in the order of their kinds.
+// Milestones whose action failed are reported as failed and the remaining actions applied, except for a failed rollover,
+// which stops before closing any milestone. The first error of a failed action is returned.
+// Once ctx is done no further actions are started, those not applied are returned as pending.
+func applyPlan(ctx context.Context, t target, token string, plan utils.Plan) (result utils.Result, err error) {
+	result.Skipped = plan.Skipped
+	result.Drifted = plan.Drifted
+	applied := map[string]map[string]utils.Milestone{}
+	failed := map[string]map[string]string{}
+	defer func() {
+		if ctx.Err() != nil {
+			result.Pending = pendingResults(plan, applied, failed)
+			err = firstError(err, ctx.Err())
+		}
+	}()
+	// fail reports the milestones of an action that failed with actionErr and keeps the first error
+	fail := func(kind string, milestones map[string]utils.Milestone, reasons map[string]string, actionErr error) {
+		reasons = withoutCancelled(ctx, reasons)
+		if len(reasons) == 0 {
+			return
+		}
+		failed[kind] = reasons
+		result.Failed = append(result.Failed, utils.ReasonResults(reasons, milestones)...)
+		err = firstError(err, fmt.Errorf("%s milestones: %w", kind, actionErr))
+	}
+
+	if newMilestones := plan.Milestones(utils.ActionCreate); len(newMilestones) > 0 && ctx.Err() == nil {
+		createdMilestones, failedMilestones := createMilestones(ctx, t, token, newMilestones)
+		applied[utils.ActionCreate] = createdMilestones
+		result.Created = utils.MilestoneResults(createdMilestones)
+		if len(failedMilestones) > 0 {
+			fail(utils.ActionCreate, newMilestones, failedMilestones, firstReason(failedMilestones))
+		}
+	}
+
+	if drifts := plan.Drifts(); len(drifts) > 0 && ctx.Err() == nil {
+		driftedMilestones := plan.Milestones(utils.ActionUpdate)
+		updateErr := updateDriftedMilestones(ctx, t, token, drifts)
+		reasons := map[string]string{}
+		if updateErr != nil {
+			reasons = failureReasons(updateErr, driftedMilestones)
+			fail(utils.ActionUpdate, driftedMilestones, reasons, updateErr)
+		}
+		applied[utils.ActionUpdate] = map[string]utils.Milestone{}
+		for _, d := range drifts {
+			if _, ok := reasons[d.Existing.Title]; ok {
+				continue
+			}
+			applied[utils.ActionUpdate][d.Existing.Title] = d.Existing
+			result.Drifted = append(result.Drifted, driftResults([]utils.Drift{d})...)
+		}
+	}
+
+	if closedMilestones := plan.Milestones(utils.ActionReopen); len(closedMilestones) > 0 && ctx.Err() == nil {
+		reactivatedMilestones, reopenErr := reactivateMilestones(ctx, t, token, closedMilestones)
+		if reopenErr != nil {
+			fail(utils.ActionReopen, closedMilestones, failureReasons(reopenErr, closedMilestones), reopenErr)
+		}
+		applied[utils.ActionReopen] = reactivatedMilestones
+		result.Reactivated = utils.MilestoneResults(reactivatedMilestones)
+	}
+
+	var rollover *utils.Action
+	for i, a := range plan.Actions {
+		if a.Kind == utils.ActionRollover {
+			rollover = &plan.Actions[i]
+			break
+		}
+	}
+	if rollover != nil && ctx.Err() == nil {
+		next, rolloverErr := getNextMilestone(ctx, t, token, map[string]utils.Milestone{rollover.Target: {Title: rollover.Target}})
+		if rolloverErr != nil {
+			return result, firstError(err, rolloverErr)
+		}
+		items, rolloverErr := rollOverMilestones(ctx, t, token, plan.Milestones(utils.ActionRollover), next, rollover.Comment, rollover.Label)
+		result.RolledOver = items
+		if rolloverErr != nil {
+			return result, firstError(err, rolloverErr)
+		}
+		applied[utils.ActionRollover] = plan.Milestones(utils.ActionRollover)
+	}
+
+	if expiredMilestones := plan.Milestones(utils.ActionClose); len(expiredMilestones) > 0 && ctx.Err() == nil {
+		closedMilestones, closeErr := closeMilestones(ctx, t, token, expiredMilestones)
+		if closeErr != nil {
+			fail(utils.ActionClose, expiredMilestones, failureReasons(closeErr, expiredMilestones), closeErr)
+		}
+		applied[utils.ActionClose] = closedMilestones
+		result.Closed = utils.MilestoneResults(closedMilestones)
+	}
+
+	if prunableMilestones := plan.Milestones(utils.ActionDelete); len(prunableMilestones) > 0 && ctx.Err() == nil {
+		deletedMilestones, deleteErr := deleteMilestones(ctx, t, token, prunableMilestones)
+		if deleteErr != nil {
+			fail(utils.ActionDelete, prunableMilestones, failureReasons(deleteErr, prunableMilestones), deleteErr)
+		}
+		applied[utils.ActionDelete] = deletedMilestones
+		result.Pruned = utils.MilestoneResults(deletedMilestones)
+	}
+
+	return result, err
+}
+
+// firstError returns err unless it is nil, then next
+func firstError(err error, next error) error {
+	if err != nil {
+		return err
+	}
+	return next
+}
+
+// failureReasons returns the reasons of the milestones an action failed for by title.
+// Unless err lists the errors of single milestones, all milestones of the action failed with it.
+func failureReasons(err error, milestones map[string]utils.Milestone) map[string]string {
+	if failed, ok := err.(utils.MilestoneErrors); ok {
+		return failed.Reasons()
+	}
+	reasons := map[string]string{}
+	for k := range milestones {
+		reasons[k] = err.Error()
+	}
+	return reasons
+}
+
+// firstReason returns the reason of the first milestone by title as error
+func firstReason(reasons map[string]string) error {
+	var keys []string
+	for k := range reasons {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return fmt.Errorf("%s: %s", keys[0], reasons[keys[0]])
+}
+
+// withoutCancelled returns the reasons without the milestones cancelled before their action was applied, they are pending rather than failed
+func withoutCancelled(ctx context.Context, reasons map[string]string) map[string]string {
+	kept := map[string]string{}
+	for k, reason := range reasons {
+		if ctx.Err() != nil && strings.HasSuffix(reason, ctx.Err().Error()) {
+			continue
+		}
+		kept[k] = reason
+	}
+	return kept
+}
+
+// pendingResults returns the actions of a plan whose milestone was neither applied nor failed, with the kind as detail
+func pendingResults(plan utils.Plan, applied map[string]map[string]utils.Milestone, failed map[string]map[string]string) []utils.MilestoneResult {
+	var pending []utils.MilestoneResult
+	for _, a := range plan.Actions {
+		if _, ok := applied[a.Kind][a.Milestone.Title]; ok {
+			continue
+		}
+		if _, ok := failed[a.Kind][a.Milestone.Title]; ok {
+			continue
+		}
+		pending = append(pending, utils.NewMilestoneResult(a.Milestone, a.Kind))
+	}
+	return pending
+}
+
+// createMilestones creates milestones in the target
+func createMilestones(ctx context.Context, t target, token string, milestones map[string]utils.Milestone) (map[string]utils.Milestone, map[string]string) {
+	switch t.api {
+	case "gitlab":
+		return gitlab.CreateMilestones(ctx, t.baseURL, token, t.project, milestones)
+	case "github":
+		return github.CreateMilestones(ctx, t.baseURL, token, t.project, milestones)
+	}
+	return nil, nil
+}
+
+// updateDriftedMilestones updates drifted milestones of the target to the schedule
+func updateDriftedMilestones(ctx context.Context, t target, token string, drifts []utils.Drift) error {
+	switch t.api {
+	case "gitlab":
+		return gitlab.UpdateDriftedMilestones(ctx, drifts, t.baseURL, token, t.project)
+	case "github":
+		return github.UpdateDriftedMilestones(ctx, drifts, t.baseURL, token, t.project)
+	}
+	return nil
+}
+
+// reactivateMilestones reactivates closed milestones of the target
+func reactivateMilestones(ctx context.Context, t target, token string, milestones map[string]utils.Milestone) (map[string]utils.Milestone, error) {
+	var reactivatedMilestones map[string]utils.Milestone
+	var err error
+	switch t.api {
+	case "gitlab":
+		reactivatedMilestones, _, err = gitlab.ReactivateClosedMilestones(ctx, milestones, t.baseURL, token, t.project, "always")
+	case "github":
+		reactivatedMilestones, _, err = github.ReactivateClosedMilestones(ctx, milestones, t.baseURL, token, t.project, "always")
+	}
+	return reactivatedMilestones, err
+}
+
+// getNextMilestone gets the earliest open milestone of the target in milestoneData not due yet
+func getNextMilestone(ctx context.Context, t target, token string, milestoneData map[string]utils.Milestone) (utils.Milestone, error) {
+	switch t.api {
+	case "gitlab":
+		return gitlab.GetNextMilestone(ctx, t.baseURL, token, t.project, milestoneData)
+	case "github":
+		return github.GetNextMilestone(ctx, t.baseURL, token, t.project, milestoneData)
+	}
+	return utils.Milestone{}, nil
+}
+
+// rollOverMilestones moves open issues and merge/pull requests of milestones of the target to next
+func rollOverMilestones(ctx context.Context, t target, token string, milestones map[string]utils.Milestone, next utils.Milestone, comment string, label string) ([]utils.RolledOverItem, error) {
+	switch t.api {
+	case "gitlab":
+		return gitlab.RollOverMilestones(ctx, milestones, next, t.baseURL, token, t.project, comment, label)
+	case "github":
+		return github.RollOverMilestones(ctx, milestones, next, t.baseURL, token, t.project, comment, label)
+	}
+	return nil, nil
+}
+
+// closeMilestones closes milestones of the target
+func closeMilestones(ctx context.Context, t target, token string, milestones map[string]utils.Milestone) (map[string]utils.Milestone, error) {
+	switch t.api {
+	case "gitlab":
+		return gitlab.CloseExpiredMilestones(ctx, milestones, t.baseURL, token, t.project)
+	case "github":
+		return github.CloseExpiredMilestones(ctx, milestones, t.baseURL, token, t.project)
+	}
+	return nil, nil
+}
+
+// deleteMilestones deletes milestones of the target
+func deleteMilestones(ctx context.Context, t target, token string, milestones map[string]utils.Milestone) (map[string]utils.Milestone, error) {
+	switch t.api {
+	case "gitlab":
+		return gitlab.DeleteMilestones(ctx, milestones, t.baseURL, token, t.project)
+	case "github":
+		return github.DeleteMilestones(ctx, milestones, t.baseURL, token, t.project)
+	}
+	return nil, nil
+}
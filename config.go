@@ -0,0 +1,137 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+
+	"go.okkur.org/gomiler/utils"
+)
+
+// newRunFlagSet returns the flags of a run bound to o and r
+func newRunFlagSet(name string, o *options, r *runOptions, configFile *string, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, errorHandling)
+	o.registerFlags(fs)
+	r.registerFlags(fs)
+	r.selection.registerFlags(fs)
+	fs.StringVar(configFile, "config", "", "YAML configuration file describing providers, projects and their schedules")
+	return fs
+}
+
+// projectOptions returns the options of a configured project.
+// Settings of the project override the defaults, flags set in args override both.
+func projectOptions(c utils.Config, p utils.ProjectConfig, args []string) (options, runOptions, error) {
+	var o options
+	var r runOptions
+	var configFile string
+	fs := newRunFlagSet("gomiler", &o, &r, &configFile, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+
+	provider := c.Provider(p)
+	token, err := provider.ResolveToken()
+	if err != nil {
+		return o, r, err
+	}
+	settings := map[string]string{
+		"url":       provider.URL,
+		"token":     token,
+		"namespace": p.Namespace,
+		"project":   p.Project,
+	}
+	if provider.GithubAppID != 0 {
+		settings["github-app-id"] = strconv.FormatInt(provider.GithubAppID, 10)
+		settings["github-app-key"] = provider.GithubAppKeyFile
+	}
+	for _, s := range []struct {
+		name     string
+		settings map[string]string
+	}{
+		{"defaults", c.Defaults},
+		{"project " + p.Name(), p.Settings},
+		{"", settings},
+	} {
+		var keys []string
+		for k := range s.settings {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if fs.Lookup(k) == nil || k == "config" {
+				return o, r, fmt.Errorf("Error: %s: unknown setting %s", s.name, k)
+			}
+			err := fs.Set(k, s.settings[k])
+			if err != nil {
+				return o, r, fmt.Errorf("Error: %s: invalid value %q for %s: %v", s.name, s.settings[k], k, err)
+			}
+		}
+	}
+
+	err = fs.Parse(args)
+	if err != nil {
+		return o, r, err
+	}
+	if err := o.resolveToken(); err != nil {
+		return o, r, fmt.Errorf("project %s: %v", p.Name(), err)
+	}
+	if err := r.validate(); err != nil {
+		return o, r, fmt.Errorf("project %s: %v", p.Name(), err)
+	}
+	if _, err := o.format(); err != nil {
+		return o, r, fmt.Errorf("project %s: %v", p.Name(), err)
+	}
+	return o, r, nil
+}
+
+// configRuns returns the runs of all projects of a configuration.
+// Projects without a project name expand to the selected projects of their namespace,
+// a namespace whose projects cannot be listed fails its run.
+func configRuns(ctx context.Context, c utils.Config, args []string) ([]projectRun, error) {
+	var projects []projectRun
+	for _, p := range c.Projects {
+		o, r, err := projectOptions(c, p, args)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, projectRun{name: p.Name(), o: o, r: r})
+	}
+
+	var runs []projectRun
+	for _, p := range projects {
+		expanded, err := expandProjects(ctx, p.o, p.r)
+		if err != nil {
+			expanded = []projectRun{{name: p.name, err: err}}
+		}
+		runs = append(runs, expanded...)
+	}
+	return runs, nil
+}
+
+// runConfig runs every project of a configuration file
+func runConfig(ctx context.Context, configFile string, args []string, output string) {
+	c, err := utils.LoadConfigFile(configFile)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	runs, err := configRuns(ctx, c, args)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	runProjects(ctx, runs, output)
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.okkur.org/gomiler/utils"
+)
+
+// tokenRefreshMargin is how long before it expires an installation token is renewed
+const tokenRefreshMargin = 5 * time.Minute
+
+// App is a GitHub App authenticating as one of its installations
+type App struct {
+	// API URL, e.g. https://api.github.com
+	URL string
+	ID  int64
+	Key *rsa.PrivateKey
+}
+
+// LoadApp returns the app with the private key of the PEM file, as downloaded from the settings of the app
+func LoadApp(URL string, id int64, keyFile string) (App, error) {
+	content, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return App{}, err
+	}
+	key, err := parsePrivateKey(content)
+	if err != nil {
+		return App{}, fmt.Errorf("Error: private key of GitHub App %d: %v", id, err)
+	}
+	return App{URL: URL, ID: id, Key: key}, nil
+}
+
+// parsePrivateKey parses a PKCS #1 or PKCS #8 RSA private key
+func parsePrivateKey(content []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+			return rsaKey, nil
+		}
+		return nil, errors.New("not an RSA key")
+	}
+	return nil, fmt.Errorf("unexpected PEM block %s", block.Type)
+}
+
+// jwt returns the JSON Web Token authenticating as the app, valid for 9 minutes.
+// It is issued a minute in the past to allow for clock drift, as GitHub recommends.
+func (a App) jwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(a.ID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// sendAppRequest sends a request authenticated as the app and decodes the response into v
+func (a App) sendAppRequest(ctx context.Context, method string, URL string, v interface{}) error {
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Accept", "application/vnd.github.v3+json")
+	header.Set("Authorization", "Bearer "+jwt)
+	resp, respBytes, err := utils.DefaultClient.Send(ctx, "github-app", method, URL, header, nil)
+	if err != nil {
+		return err
+	}
+	if err := utils.CheckResponse(method, URL, resp, respBytes); err != nil {
+		return err
+	}
+	return json.Unmarshal(respBytes, v)
+}
+
+// Installation returns the ID of the installation of the app on the repository of owner,
+// or if repo is empty on owner, an organisation or user
+func (a App) Installation(ctx context.Context, owner string, repo string) (int64, error) {
+	var installation struct {
+		ID int64 `json:"id"`
+	}
+	if repo != "" {
+		err := a.sendAppRequest(ctx, "GET", a.URL+"/repos/"+owner+"/"+repo+"/installation", &installation)
+		return installation.ID, err
+	}
+	err := a.sendAppRequest(ctx, "GET", a.URL+"/orgs/"+owner+"/installation", &installation)
+	if errors.Is(err, utils.ErrNotFound) {
+		err = a.sendAppRequest(ctx, "GET", a.URL+"/users/"+owner+"/installation", &installation)
+	}
+	return installation.ID, err
+}
+
+// InstallationToken returns a new token of the installation with the time it expires, usually an hour later
+func (a App) InstallationToken(ctx context.Context, installation int64) (string, time.Time, error) {
+	var token struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	URL := a.URL + "/app/installations/" + strconv.FormatInt(installation, 10) + "/access_tokens"
+	err := a.sendAppRequest(ctx, "POST", URL, &token)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if token.Token == "" {
+		return "", time.Time{}, fmt.Errorf("Error: GitHub returned no token for installation %d", installation)
+	}
+	return token.Token, token.ExpiresAt, nil
+}
+
+// InstallationTokenSource returns the tokens of the installation of an app on a repository or account,
+// it looks up the installation once and renews the token shortly before it expires
+type InstallationTokenSource struct {
+	app   App
+	owner string
+	repo  string
+
+	mu           sync.Mutex
+	installation int64
+	token        string
+	expires      time.Time
+}
+
+// TokenSource returns the source of the installation tokens of the repository of owner, or if repo is empty of owner
+func (a App) TokenSource(owner string, repo string) *InstallationTokenSource {
+	return &InstallationTokenSource{app: a, owner: owner, repo: repo}
+}
+
+// Token returns the current installation token, requesting a new one if it expires soon
+func (s *InstallationTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Until(s.expires) > tokenRefreshMargin {
+		return s.token, nil
+	}
+	if s.installation == 0 {
+		installation, err := s.app.Installation(ctx, s.owner, s.repo)
+		if err != nil {
+			return "", fmt.Errorf("Error: installation of GitHub App %d for %s: %w", s.app.ID, s.name(), err)
+		}
+		s.installation = installation
+	}
+	token, expires, err := s.app.InstallationToken(ctx, s.installation)
+	if err != nil {
+		return "", fmt.Errorf("Error: token of GitHub App %d for %s: %w", s.app.ID, s.name(), err)
+	}
+	s.token, s.expires = token, expires
+	return token, nil
+}
+
+// name returns the repository or account of the source
+func (s *InstallationTokenSource) name() string {
+	if s.repo == "" {
+		return s.owner
+	}
+	return s.owner + "/" + s.repo
+}
+
+var (
+	tokenSourcesMu sync.Mutex
+	// Sources registered by Authorize, kept so tokens are reused by later runs
+	tokenSources = map[string]*InstallationTokenSource{}
+)
+
+// Authorize authenticates the requests of client to the repository of owner, or if repo is empty
+// to the repositories, organisation and user of owner, with the installation tokens of the app.
+// The source of the tokens is reused when the same app authorizes the same repository again.
+func Authorize(client *utils.Client, a App, owner string, repo string) {
+	key := fmt.Sprintf("%s %d %s/%s", a.URL, a.ID, owner, repo)
+	tokenSourcesMu.Lock()
+	s, ok := tokenSources[key]
+	if !ok || s.app.Key.N.Cmp(a.Key.N) != 0 {
+		s = a.TokenSource(owner, repo)
+		tokenSources[key] = s
+	}
+	tokenSourcesMu.Unlock()
+
+	if repo != "" {
+		client.Authorize(a.URL+"/repos/"+owner+"/"+repo, s)
+		return
+	}
+	for _, prefix := range []string{"/repos/", "/orgs/", "/users/"} {
+		client.Authorize(a.URL+prefix+owner, s)
+	}
+}
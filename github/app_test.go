@@ -0,0 +1,174 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.okkur.org/gomiler/utils"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+// testApp returns an app with a newly generated key
+func testApp(t *testing.T) App {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return App{URL: "https://api.github.com", ID: 42, Key: key}
+}
+
+func TestAppJWT(t *testing.T) {
+	app := testApp(t)
+	now := time.Unix(1500000000, 0)
+	jwt, err := app.jwt(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected a JWT of 3 parts, got %s", jwt)
+	}
+
+	var header, claims map[string]interface{}
+	for i, v := range []*map[string]interface{}{&header, &claims} {
+		content, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(content, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if header["alg"] != "RS256" || header["typ"] != "JWT" {
+		t.Errorf("Unexpected header %v", header)
+	}
+	if claims["iss"] != "42" || claims["iat"] != float64(1500000000-60) || claims["exp"] != float64(1500000000+540) {
+		t.Errorf("Unexpected claims %v", claims)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&app.Key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("Invalid signature: %v", err)
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	app := testApp(t)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(app.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range []*pem.Block{
+		{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(app.Key)},
+		{Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		key, err := parsePrivateKey(pem.EncodeToMemory(block))
+		if err != nil || key.N.Cmp(app.Key.N) != 0 {
+			t.Errorf("%s: expected the key, got %v", block.Type, err)
+		}
+	}
+	for _, content := range []string{"", "-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n"} {
+		if _, err := parsePrivateKey([]byte(content)); err == nil {
+			t.Errorf("Expected an error for %q", content)
+		}
+	}
+}
+
+func TestInstallationTokenSource(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	app := testApp(t)
+
+	issued := MockGithubAppRequests(app.URL, "namespace", time.Hour)
+	s := app.TokenSource("namespace", "")
+	for i := 0; i < 2; i++ {
+		token, err := s.Token(context.Background())
+		if err != nil || token != "ghs-1" {
+			t.Errorf("Expected ghs-1, got %s, %v", token, err)
+		}
+	}
+	if *issued != 1 || s.installation != 7 {
+		t.Errorf("Expected 1 token of installation 7, got %d of %d", *issued, s.installation)
+	}
+
+	// Tokens expiring within the refresh margin are renewed
+	issued = MockGithubAppRequests(app.URL, "namespace", time.Minute)
+	s = app.TokenSource("namespace", "app")
+	for i := 1; i <= 2; i++ {
+		token, err := s.Token(context.Background())
+		if err != nil || token != "ghs-"+strconv.Itoa(i) {
+			t.Errorf("Expected token %d, got %s, %v", i, token, err)
+		}
+	}
+
+	httpmock.RegisterResponder("GET", app.URL+"/users/missing/installation", httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
+	httpmock.RegisterResponder("GET", app.URL+"/orgs/missing/installation", httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
+	if _, err := app.TokenSource("missing", "").Token(context.Background()); err == nil || !strings.Contains(err.Error(), "installation of GitHub App 42 for missing") {
+		t.Errorf("Expected an error for a missing installation, got %v", err)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	app := testApp(t)
+	issued := MockGithubAppRequests(app.URL, "authorized", time.Hour)
+	MockGithubAPIRepositoriesRequest(app.URL, "authorized")
+	var authorization string
+	httpmock.RegisterResponder("GET", app.URL+"/users/authorized/repos", func(req *http.Request) (*http.Response, error) {
+		authorization = req.Header.Get("Authorization")
+		return httpmock.NewStringResponse(200, `[{"name": "app"}]`), nil
+	})
+
+	client := utils.DefaultClient
+	Authorize(client, app, "authorized", "")
+	defer func() {
+		for _, prefix := range []string{"/repos/", "/orgs/", "/users/"} {
+			client.Authorize(app.URL+prefix+"authorized", nil)
+		}
+	}()
+	for i := 0; i < 2; i++ {
+		// Authorizing again, e.g. in the next pass of serve, keeps the token
+		Authorize(client, app, "authorized", "")
+		repositories, err := ListRepositories(context.Background(), app.URL, "", "authorized")
+		if err != nil || len(repositories) != 1 {
+			t.Fatalf("Expected the repository, got %v, %v", repositories, err)
+		}
+		if authorization != "token ghs-1" {
+			t.Errorf("Expected the installation token, got %q", authorization)
+		}
+	}
+	if *issued != 1 {
+		t.Errorf("Expected 1 token, got %d", *issued)
+	}
+}
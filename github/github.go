@@ -15,10 +15,10 @@ limitations under the License.
 package github
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"log"
-	"net/http"
+	"errors"
+	"fmt"
 	"net/url"
 	"sort"
 	"strconv"
@@ -30,15 +30,18 @@ import (
 
 // GithubAPI struct
 type githubAPI struct {
-	ID          int        `json:"id"`
-	Title       string     `json:"title"`
-	Description string     `json:"description"`
-	State       string     `json:"state"`
-	CreatedAt   *time.Time `json:"created_at"`
-	UpdatedAt   *time.Time `json:"updated_at"`
-	StartDate   string     `json:"start_date"`
-	DueDate     string     `json:"due_on"`
-	Number      int        `json:"number"`
+	ID           int        `json:"id"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	State        string     `json:"state"`
+	CreatedAt    *time.Time `json:"created_at"`
+	UpdatedAt    *time.Time `json:"updated_at"`
+	StartDate    string     `json:"start_date"`
+	DueDate      string     `json:"due_on"`
+	Number       int        `json:"number"`
+	URL          string     `json:"html_url"`
+	OpenIssues   int        `json:"open_issues"`
+	ClosedIssues int        `json:"closed_issues"`
 }
 
 // CreateGithubMilestoneMap creates a map of GitHub milestones
@@ -47,10 +50,14 @@ func CreateGithubMilestoneMap(githubAPI []githubAPI) map[string]utils.Milestone
 	for _, v := range githubAPI {
 		var m utils.Milestone
 		m.DueDate = v.DueDate
+		m.Description = v.Description
 		m.ID = strconv.Itoa(v.ID)
 		m.Title = v.Title
 		m.State = v.State
 		m.Number = v.Number
+		m.URL = v.URL
+		m.OpenIssues = v.OpenIssues
+		m.ClosedIssues = v.ClosedIssues
 		milestones[v.Title] = m
 	}
 
@@ -58,66 +65,111 @@ func CreateGithubMilestoneMap(githubAPI []githubAPI) map[string]utils.Milestone
 }
 
 // Get and return currently active milestones
-func getActiveMilestones(baseURL string, token string, projectID string) ([]githubAPI, error) {
+func getActiveMilestones(ctx context.Context, baseURL string, token string, projectID string) ([]githubAPI, error) {
 	var state string
 	state = "open"
-	return getMilestones(baseURL, token, projectID, state)
+	return getMilestones(ctx, baseURL, token, projectID, state)
 }
 
 // Get and return inactive milestones
-func getInactiveMilestones(baseURL string, token string, project string) ([]githubAPI, error) {
+func getInactiveMilestones(ctx context.Context, baseURL string, token string, project string) ([]githubAPI, error) {
 	state := "closed"
-	return getMilestones(baseURL, token, project, state)
+	return getMilestones(ctx, baseURL, token, project, state)
 }
 
-// ReactivateClosedMilestones reactivates closed milestones that occur in the future
+// GetReactivatableMilestones splits closed milestones into those allowed to be reactivated by policy
+// and those that are not, with the reason. Unlike GitLab, GitHub lists the issue counts with the milestones.
+func GetReactivatableMilestones(milestones map[string]utils.Milestone, policy string) (map[string]utils.Milestone, map[string]string, error) {
+	now := time.Now()
+	reactivatableMilestones := make(map[string]utils.Milestone, len(milestones))
+	skippedMilestones := map[string]string{}
+	for k, v := range milestones {
+		reason := utils.ReactivationSkipReason(v, policy, v.OpenIssues+v.ClosedIssues, now)
+		if reason != "" {
+			skippedMilestones[k] = reason
+			continue
+		}
+		reactivatableMilestones[k] = v
+	}
+
+	return reactivatableMilestones, skippedMilestones, nil
+}
+
+// ReactivateClosedMilestones reactivates closed milestones that occur in the future.
+// Milestones not allowed to be reactivated by policy are returned with the reason.
 func ReactivateClosedMilestones(
+	ctx context.Context,
+	milestones map[string]utils.Milestone,
+	baseURL string,
+	token string,
+	project string,
+	policy string,
+) (map[string]utils.Milestone, map[string]string, error) {
+	reactivatableMilestones, skippedMilestones, err := GetReactivatableMilestones(milestones, policy)
+	if err != nil {
+		return nil, skippedMilestones, err
+	}
+	reactivatedMilestones, failed := utils.ForEachMilestone(ctx, reactivatableMilestones, func(v utils.Milestone) (utils.Milestone, error) {
+		v.State = "open"
+		return v, updateMilestoneState(ctx, baseURL, token, project, v.Number, "open")
+	})
+
+	return reactivatedMilestones, skippedMilestones, failed.Err()
+}
+
+// CloseExpiredMilestones closes milestones whose due date has passed
+func CloseExpiredMilestones(
+	ctx context.Context,
 	milestones map[string]utils.Milestone,
 	baseURL string,
 	token string,
 	project string,
 ) (map[string]utils.Milestone, error) {
-	client := http.Client{}
-	var strURL []string
-	for _, v := range milestones {
-		milestoneID := strconv.Itoa(v.Number)
-		strURL = []string{baseURL, project, "/milestones/", milestoneID}
-		URL := strings.Join(strURL, "")
-		var req *http.Request
-		var err error
+	closedMilestones, failed := utils.ForEachMilestone(ctx, milestones, func(v utils.Milestone) (utils.Milestone, error) {
+		v.State = "closed"
+		return v, updateMilestoneState(ctx, baseURL, token, project, v.Number, "closed")
+	})
 
-		updatePatch := struct {
-			State string `json:"state"`
-		}{
-			State: "open",
-		}
-		updatePatchBytes, err := json.Marshal(updatePatch)
-		if err != nil {
-			return nil, err
-		}
-		req, err = http.NewRequest("PATCH", URL, bytes.NewReader(updatePatchBytes))
-		if err != nil {
-			return nil, err
-		}
-		req.Header.Add("Accept", "application/vnd.github.v3+json")
-		req.Header.Add("Authorization", "token "+token)
-		resp, err := client.Do(req)
+	return closedMilestones, failed.Err()
+}
+
+func updateMilestoneState(ctx context.Context, baseURL string, token string, project string, number int, state string) error {
+	strURL := []string{baseURL, project, "/milestones/", strconv.Itoa(number)}
+	URL := strings.Join(strURL, "")
+	updatePatch := struct {
+		State string `json:"state"`
+	}{
+		State: state,
+	}
+	return sendRequest(ctx, "PATCH", URL, token, updatePatch, nil)
+}
+
+// Send a request with a JSON encoded body, a nil body is not sent.
+// The response is decoded into v unless v is nil.
+func sendRequest(ctx context.Context, method string, URL string, token string, body interface{}, v interface{}) error {
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		bodyBytes, err = json.Marshal(body)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		defer resp.Body.Close()
 	}
-	// copy map of milestones with states changed to open for testing purposes
-	reactivatedMilestones := make(map[string]utils.Milestone, len(milestones))
-	for k, v := range milestones {
-		v.State = "open"
-		reactivatedMilestones[k] = v
+	resp, respBytes, err := utils.DefaultClient.Send(ctx, "github", method, URL, utils.APIHeader("github", token), bodyBytes)
+	if err != nil {
+		return err
+	}
+	if err := utils.CheckResponse(method, URL, resp, respBytes); err != nil {
+		return err
+	}
+	if v != nil {
+		return json.Unmarshal(respBytes, v)
 	}
 
-	return reactivatedMilestones, nil
+	return nil
 }
 
-func getMilestones(baseURL string, token string, project string, state string) ([]githubAPI, error) {
+func getMilestones(ctx context.Context, baseURL string, token string, project string, state string) ([]githubAPI, error) {
 	var strURL []string
 	var URL, newURL string
 	var apiData [][]byte
@@ -128,112 +180,587 @@ func getMilestones(baseURL string, token string, project string, state string) (
 	q.Set("state", state)
 	u.RawQuery = q.Encode()
 	newURL = u.String()
-	apiData, err := utils.Paginate(newURL, "gitlab", token)
+	apiData, err := utils.Paginate(ctx, newURL, "github", token)
 	if err != nil {
 		return nil, err
 	}
 	milestones := []githubAPI{}
 	tmpM := []githubAPI{}
 	for _, v := range apiData {
-		json.Unmarshal(v, &tmpM)
+		if err := json.Unmarshal(v, &tmpM); err != nil {
+			return nil, err
+		}
 		milestones = append(milestones, tmpM...)
 	}
 	return milestones, nil
 }
 
-func createMilestones(baseURL string, token string, project string, milestones map[string]utils.Milestone) error {
-	client := http.Client{}
+// CreateMilestones creates milestones, returning the created ones and the errors of those that failed
+func CreateMilestones(ctx context.Context, baseURL string, token string, project string, milestones map[string]utils.Milestone) (map[string]utils.Milestone, map[string]string) {
 	var strURL []string
 	strURL = []string{baseURL, project, "/milestones"}
 	URL := strings.Join(strURL, "")
-	for _, v := range milestones {
-		var req *http.Request
-		var err error
+	createdMilestones, failed := utils.ForEachMilestone(ctx, milestones, func(v utils.Milestone) (utils.Milestone, error) {
 		create := struct {
-			Title   string `json:"title"`
-			DueDate string `json:"due_on"`
+			Title       string `json:"title"`
+			DueDate     string `json:"due_on"`
+			Description string `json:"description,omitempty"`
 		}{
-			Title:   v.Title,
-			DueDate: v.DueDate,
+			Title:       v.Title,
+			DueDate:     utils.GithubDueDate(v.DueDate),
+			Description: utils.AddMarker(v.Description),
 		}
-		createBytes, err := json.Marshal(create)
+		var created githubAPI
+		err := sendRequest(ctx, "POST", URL, token, create, &created)
 		if err != nil {
-			return err
+			return v, err
+		}
+		return CreateGithubMilestoneMap([]githubAPI{created})[created.Title], nil
+	})
+
+	return createdMilestones, failed.Reasons()
+}
+
+// GetClosedMilestones gets closed milestones
+func GetClosedMilestones(ctx context.Context, baseURL string, token string, projectID string, milestoneData map[string]utils.Milestone) (map[string]utils.Milestone, error) {
+	closedMilestonesAPI, err := getInactiveMilestones(ctx, baseURL, token, projectID)
+	if err != nil {
+		return nil, err
+	}
+	closedGithubMilestones := CreateGithubMilestoneMap(closedMilestonesAPI)
+
+	// copy map of closed milestones
+	milestones := map[string]utils.Milestone{}
+	for k := range milestoneData {
+		for ek, ev := range closedGithubMilestones {
+			if k == ek && utils.IsManaged(ev.Description) {
+				milestones[ek] = ev
+			}
 		}
-		req, err = http.NewRequest("POST", URL, bytes.NewReader(createBytes))
+	}
+
+	return milestones, nil
+}
+
+// GetExpiredMilestones gets open milestones following the naming scheme titleFormat whose due date passed more than grace days ago.
+// Milestones with open issues are only returned if allowOpenIssues is set.
+func GetExpiredMilestones(ctx context.Context, baseURL string, token string, projectID string, titleFormat string, grace int, allowOpenIssues bool) (map[string]utils.Milestone, error) {
+	activeMilestonesAPI, err := getActiveMilestones(ctx, baseURL, token, projectID)
+	if err != nil {
+		return nil, err
+	}
+	activeMilestones := CreateGithubMilestoneMap(activeMilestonesAPI)
+
+	now := time.Now()
+	milestones := map[string]utils.Milestone{}
+	for k, v := range activeMilestones {
+		if !utils.IsManaged(v.Description) || !utils.IsGeneratedTitle(v.Title, titleFormat) || !utils.DueDatePassed(v.DueDate, grace, now) {
+			continue
+		}
+		if v.OpenIssues > 0 && !allowOpenIssues {
+			continue
+		}
+		milestones[k] = v
+	}
+
+	return milestones, nil
+}
+
+// githubIssue struct for issues and pull requests
+type githubIssue struct {
+	Number      int                `json:"number"`
+	Title       string             `json:"title"`
+	State       string             `json:"state"`
+	URL         string             `json:"html_url"`
+	PullRequest *githubPullRequest `json:"pull_request"`
+	CreatedAt   *time.Time         `json:"created_at"`
+	ClosedAt    *time.Time         `json:"closed_at"`
+	User        struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+// Pull request details of an issue
+type githubPullRequest struct {
+	MergedAt *time.Time `json:"merged_at"`
+}
+
+// Convert a GitHub issue or pull request
+func (v githubIssue) issue() utils.Issue {
+	issue := utils.Issue{
+		Kind:      "issue",
+		Number:    v.Number,
+		Title:     v.Title,
+		State:     v.State,
+		URL:       v.URL,
+		Author:    v.User.Login,
+		CreatedAt: v.CreatedAt,
+		ClosedAt:  v.ClosedAt,
+	}
+	if v.PullRequest != nil {
+		issue.Kind = "pull_request"
+	}
+	for _, l := range v.Labels {
+		issue.Labels = append(issue.Labels, l.Name)
+	}
+	return issue
+}
+
+// Get and return open issues and pull requests assigned to a milestone
+func getOpenIssues(ctx context.Context, baseURL string, token string, project string, number int) ([]githubIssue, error) {
+	return getIssues(ctx, baseURL, token, project, number, "open")
+}
+
+// Get and return issues and pull requests assigned to a milestone, state being open, closed or all
+func getIssues(ctx context.Context, baseURL string, token string, project string, number int, state string) ([]githubIssue, error) {
+	strURL := []string{baseURL, project, "/issues"}
+	URL := strings.Join(strURL, "")
+	u, err := url.Parse(URL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("milestone", strconv.Itoa(number))
+	q.Set("state", state)
+	u.RawQuery = q.Encode()
+	apiData, err := utils.Paginate(ctx, u.String(), "github", token)
+	if err != nil {
+		return nil, err
+	}
+	issues := []githubIssue{}
+	for _, v := range apiData {
+		tmpI := []githubIssue{}
+		if err := json.Unmarshal(v, &tmpI); err != nil {
+			return nil, err
+		}
+		issues = append(issues, tmpI...)
+	}
+	return issues, nil
+}
+
+// Move an issue or pull request to a milestone, optionally labeling and commenting on it
+func moveIssue(ctx context.Context, baseURL string, token string, project string, number int, milestone int, comment string, label string) error {
+	strURL := []string{baseURL, project, "/issues/", strconv.Itoa(number)}
+	URL := strings.Join(strURL, "")
+	update := struct {
+		Milestone int `json:"milestone"`
+	}{
+		Milestone: milestone,
+	}
+	err := sendRequest(ctx, "PATCH", URL, token, update, nil)
+	if err != nil {
+		return err
+	}
+	if label != "" {
+		labels := struct {
+			Labels []string `json:"labels"`
+		}{
+			Labels: []string{label},
+		}
+		err = sendRequest(ctx, "POST", URL+"/labels", token, labels, nil)
 		if err != nil {
 			return err
 		}
-		req.Header.Add("Accept", "application/vnd.github.v3+json")
-		req.Header.Add("Authorization", "token "+token)
-		resp, err := client.Do(req)
+	}
+	if comment != "" {
+		note := struct {
+			Body string `json:"body"`
+		}{
+			Body: comment,
+		}
+		err = sendRequest(ctx, "POST", URL+"/comments", token, note, nil)
 		if err != nil {
 			return err
 		}
-		defer resp.Body.Close()
 	}
 
 	return nil
 }
 
-// CreateAndDisplayNewMilestones creates and displays new milestones
-func CreateAndDisplayNewMilestones(baseURL string, token string,
-	projectID string, milestoneData map[string]utils.Milestone, logger *log.Logger) error {
-	activeMilestonesAPI, err := getActiveMilestones(baseURL, token, projectID)
+// GetNextMilestone gets the open milestone of milestoneData with the earliest due date that has not passed yet.
+// Milestones without the gomiler marker are not rolled over to.
+func GetNextMilestone(ctx context.Context, baseURL string, token string, projectID string, milestoneData map[string]utils.Milestone) (utils.Milestone, error) {
+	activeMilestonesAPI, err := getActiveMilestones(ctx, baseURL, token, projectID)
 	if err != nil {
-		return err
+		return utils.Milestone{}, err
 	}
 	activeMilestones := CreateGithubMilestoneMap(activeMilestonesAPI)
 
-	// copy map of active milestones
-	newMilestones := map[string]utils.Milestone{}
-	for k, v := range milestoneData {
-		newMilestones[k] = v
-	}
+	now := time.Now()
+	var next utils.Milestone
+	var nextDue time.Time
 	for k := range milestoneData {
-		for ok := range activeMilestones {
-			if k == ok {
-				delete(newMilestones, k)
-			}
+		m, ok := activeMilestones[k]
+		if !ok || !utils.IsManaged(m.Description) || utils.DueDatePassed(m.DueDate, 0, now) {
+			continue
 		}
-	}
-	if len(newMilestones) == 0 {
-		logger.Println("No milestone creation needed")
-	} else {
-		logger.Println("New milestones:")
-		var keys []string
-		for k := range newMilestones {
-			keys = append(keys, k)
+		due, err := utils.ParseDueDate(m.DueDate)
+		if err != nil {
+			continue
 		}
-		sort.Strings(keys)
-		for _, key := range keys {
-			logger.Printf("Title: %s - Due Date: %s", newMilestones[key].Title, newMilestones[key].DueDate)
+		if next.Title == "" || due.Before(nextDue) {
+			next = m
+			nextDue = due
 		}
-		err = createMilestones(baseURL, token, projectID, newMilestones)
+	}
+	if next.Title == "" {
+		return next, fmt.Errorf("no open milestone to roll over to")
+	}
+
+	return next, nil
+}
+
+// RollOverMilestones moves open issues and pull requests of milestones to the next milestone.
+// Moved items are labeled with label and commented on with comment, if set.
+func RollOverMilestones(
+	ctx context.Context,
+	milestones map[string]utils.Milestone,
+	next utils.Milestone,
+	baseURL string,
+	token string,
+	project string,
+	comment string,
+	label string,
+) ([]utils.RolledOverItem, error) {
+	var keys []string
+	for k := range milestones {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := []utils.RolledOverItem{}
+	for _, key := range keys {
+		m := milestones[key]
+		if m.Number == next.Number {
+			continue
+		}
+		issues, err := getOpenIssues(ctx, baseURL, token, project, m.Number)
 		if err != nil {
-			return (err)
+			return items, err
+		}
+		for _, issue := range issues {
+			err = moveIssue(ctx, baseURL, token, project, issue.Number, next.Number, comment, label)
+			if err != nil {
+				return items, err
+			}
+			kind := "issue"
+			if issue.PullRequest != nil {
+				kind = "pull_request"
+			}
+			items = append(items, utils.RolledOverItem{
+				Kind:   kind,
+				Number: issue.Number,
+				Title:  issue.Title,
+				From:   m.Title,
+				To:     next.Title,
+			})
 		}
 	}
-	return nil
+
+	return items, nil
 }
 
-// GetClosedMilestones gets closed milestones
-func GetClosedMilestones(baseURL string, token string, projectID string, milestoneData map[string]utils.Milestone) (map[string]utils.Milestone, error) {
-	closedMilestonesAPI, err := getInactiveMilestones(baseURL, token, projectID)
+// GetPrunableMilestones gets milestones following the naming scheme titleFormat without any issues or pull requests
+// whose due date passed more than retention days ago
+func GetPrunableMilestones(ctx context.Context, baseURL string, token string, projectID string, titleFormat string, retention int) (map[string]utils.Milestone, error) {
+	milestonesAPI, err := getMilestones(ctx, baseURL, token, projectID, "all")
 	if err != nil {
 		return nil, err
 	}
-	closedGithubMilestones := CreateGithubMilestoneMap(closedMilestonesAPI)
+	allMilestones := CreateGithubMilestoneMap(milestonesAPI)
 
-	// copy map of closed milestones
+	now := time.Now()
 	milestones := map[string]utils.Milestone{}
+	for k, v := range allMilestones {
+		if !utils.IsManaged(v.Description) || !utils.IsGeneratedTitle(v.Title, titleFormat) || !utils.DueDatePassed(v.DueDate, retention, now) {
+			continue
+		}
+		if v.OpenIssues+v.ClosedIssues > 0 {
+			continue
+		}
+		milestones[k] = v
+	}
+
+	return milestones, nil
+}
+
+// DeleteMilestones deletes milestones
+func DeleteMilestones(
+	ctx context.Context,
+	milestones map[string]utils.Milestone,
+	baseURL string,
+	token string,
+	project string,
+) (map[string]utils.Milestone, error) {
+	deletedMilestones, failed := utils.ForEachMilestone(ctx, milestones, func(v utils.Milestone) (utils.Milestone, error) {
+		strURL := []string{baseURL, project, "/milestones/", strconv.Itoa(v.Number)}
+		URL := strings.Join(strURL, "")
+		return v, sendRequest(ctx, "DELETE", URL, token, nil, nil)
+	})
+
+	return deletedMilestones, failed.Err()
+}
+
+// GetDriftedMilestones compares existing milestones of milestoneData on due date and description and returns the differences.
+// GitHub milestones have no start date.
+func GetDriftedMilestones(ctx context.Context, baseURL string, token string, projectID string, milestoneData map[string]utils.Milestone) ([]utils.Drift, error) {
+	milestonesAPI, err := getMilestones(ctx, baseURL, token, projectID, "all")
+	if err != nil {
+		return nil, err
+	}
+	existingMilestones := CreateGithubMilestoneMap(milestonesAPI)
+
+	var keys []string
 	for k := range milestoneData {
-		for ek, ev := range closedGithubMilestones {
-			if k == ek {
-				milestones[ek] = ev
-			}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	drifts := []utils.Drift{}
+	for _, key := range keys {
+		existing, ok := existingMilestones[key]
+		if !ok || !utils.IsManaged(existing.Description) {
+			continue
+		}
+		fields := utils.CompareMilestones(existing, milestoneData[key], false)
+		if len(fields) == 0 {
+			continue
+		}
+		drifts = append(drifts, utils.Drift{Existing: existing, Desired: milestoneData[key], Fields: fields})
+	}
+
+	return drifts, nil
+}
+
+// UpdateDriftedMilestones updates drifted milestones to their desired due date and description.
+// Milestones that could not be updated are returned as MilestoneErrors by title.
+func UpdateDriftedMilestones(ctx context.Context, drifts []utils.Drift, baseURL string, token string, project string) error {
+	byTitle := map[string]utils.Drift{}
+	var titles []string
+	for _, d := range drifts {
+		byTitle[d.Existing.Title] = d
+		titles = append(titles, d.Existing.Title)
+	}
+	failed := utils.ForEach(ctx, titles, func(i int, title string) error {
+		d := byTitle[title]
+		strURL := []string{baseURL, project, "/milestones/", strconv.Itoa(d.Existing.Number)}
+		URL := strings.Join(strURL, "")
+		update := struct {
+			DueDate     string `json:"due_on"`
+			Description string `json:"description,omitempty"`
+		}{
+			DueDate: utils.GithubDueDate(d.Desired.DueDate),
+		}
+		if d.Desired.Description != "" {
+			update.Description = utils.AddMarker(d.Desired.Description)
+		}
+		return sendRequest(ctx, "PATCH", URL, token, update, nil)
+	})
+	return failed.Err()
+}
+
+// GetAllMilestones gets open and closed milestones
+func GetAllMilestones(ctx context.Context, baseURL string, token string, projectID string) (map[string]utils.Milestone, error) {
+	milestonesAPI, err := getMilestones(ctx, baseURL, token, projectID, "all")
+	if err != nil {
+		return nil, err
+	}
+	return CreateGithubMilestoneMap(milestonesAPI), nil
+}
+
+// RenameMilestones renames milestones in place, keeping their issues and pull requests.
+// Renames are sent concurrently, the renamed milestones are returned ordered by their old title.
+// Renames that failed or were not started before ctx was done are returned as MilestoneErrors by old title.
+func RenameMilestones(ctx context.Context, renames []utils.Rename, baseURL string, token string, project string) ([]utils.Rename, error) {
+	byTitle := map[string]utils.Rename{}
+	var titles []string
+	for _, r := range renames {
+		byTitle[r.Milestone.Title] = r
+		titles = append(titles, r.Milestone.Title)
+	}
+	failed := utils.ForEach(ctx, titles, func(i int, title string) error {
+		r := byTitle[title]
+		strURL := []string{baseURL, project, "/milestones/", strconv.Itoa(r.Milestone.Number)}
+		URL := strings.Join(strURL, "")
+		update := struct {
+			Title string `json:"title"`
+		}{
+			Title: r.Title,
 		}
+		return sendRequest(ctx, "PATCH", URL, token, update, nil)
+	})
+
+	sort.Strings(titles)
+	renamed := []utils.Rename{}
+	for _, title := range titles {
+		if _, ok := failed[title]; !ok {
+			renamed = append(renamed, byTitle[title])
+		}
+	}
+	return renamed, failed.Err()
+}
+
+// GetAdoptableMilestones gets milestones following the naming scheme titleFormat that are not managed by gomiler yet
+func GetAdoptableMilestones(ctx context.Context, baseURL string, token string, projectID string, titleFormat string) (map[string]utils.Milestone, error) {
+	allMilestones, err := GetAllMilestones(ctx, baseURL, token, projectID)
+	if err != nil {
+		return nil, err
+	}
+	milestones := map[string]utils.Milestone{}
+	for k, v := range allMilestones {
+		if utils.IsManaged(v.Description) || !utils.IsGeneratedTitle(v.Title, titleFormat) {
+			continue
+		}
+		milestones[k] = v
 	}
 
 	return milestones, nil
 }
+
+// AdoptMilestones marks milestones as managed by gomiler.
+// Milestones that could not be adopted are returned as MilestoneErrors by title.
+func AdoptMilestones(ctx context.Context, milestones map[string]utils.Milestone, baseURL string, token string, project string) (map[string]utils.Milestone, error) {
+	adoptedMilestones, failed := utils.ForEachMilestone(ctx, milestones, func(v utils.Milestone) (utils.Milestone, error) {
+		strURL := []string{baseURL, project, "/milestones/", strconv.Itoa(v.Number)}
+		URL := strings.Join(strURL, "")
+		update := struct {
+			Description string `json:"description"`
+		}{
+			Description: utils.AddMarker(v.Description),
+		}
+		v.Description = update.Description
+		return v, sendRequest(ctx, "PATCH", URL, token, update, nil)
+	})
+
+	return adoptedMilestones, failed.Err()
+}
+
+// GetMilestoneIssues gets the issues of a milestone, pull requests are left out
+func GetMilestoneIssues(ctx context.Context, baseURL string, token string, project string, m utils.Milestone) ([]utils.Issue, error) {
+	githubIssues, err := getIssues(ctx, baseURL, token, project, m.Number, "all")
+	if err != nil {
+		return nil, err
+	}
+	issues := []utils.Issue{}
+	for _, v := range githubIssues {
+		if v.PullRequest != nil {
+			continue
+		}
+		issues = append(issues, v.issue())
+	}
+
+	return issues, nil
+}
+
+// GetReleaseItems gets the closed issues and merged pull requests of a milestone
+func GetReleaseItems(ctx context.Context, baseURL string, token string, project string, m utils.Milestone) ([]utils.Issue, error) {
+	githubIssues, err := getIssues(ctx, baseURL, token, project, m.Number, "closed")
+	if err != nil {
+		return nil, err
+	}
+	items := []utils.Issue{}
+	for _, v := range githubIssues {
+		if v.PullRequest != nil && v.PullRequest.MergedAt == nil {
+			continue
+		}
+		items = append(items, v.issue())
+	}
+
+	return items, nil
+}
+
+type githubRelease struct {
+	ID      int    `json:"id"`
+	TagName string `json:"tag_name"`
+	URL     string `json:"html_url"`
+}
+
+// PublishRelease creates the release of tag or updates its name and notes if it exists.
+// A new tag is created from ref, defaulting to the default branch. The URL of the release is returned.
+func PublishRelease(ctx context.Context, baseURL string, token string, project string, tag string, ref string, name string, notes string) (string, error) {
+	strURL := []string{baseURL, project, "/releases"}
+	URL := strings.Join(strURL, "")
+	apiData, err := utils.Paginate(ctx, URL, "github", token)
+	if err != nil {
+		return "", err
+	}
+	releases := []githubRelease{}
+	for _, v := range apiData {
+		tmpR := []githubRelease{}
+		if err := json.Unmarshal(v, &tmpR); err != nil {
+			return "", err
+		}
+		releases = append(releases, tmpR...)
+	}
+	release := struct {
+		TagName string `json:"tag_name"`
+		Ref     string `json:"target_commitish,omitempty"`
+		Name    string `json:"name"`
+		Body    string `json:"body"`
+	}{
+		TagName: tag,
+		Ref:     ref,
+		Name:    name,
+		Body:    notes,
+	}
+	var published githubRelease
+	for _, r := range releases {
+		if r.TagName == tag {
+			err = sendRequest(ctx, "PATCH", URL+"/"+strconv.Itoa(r.ID), token, release, &published)
+			return published.URL, err
+		}
+	}
+	err = sendRequest(ctx, "POST", URL, token, release, &published)
+	return published.URL, err
+}
+
+type githubRepository struct {
+	Name     string   `json:"name"`
+	Archived bool     `json:"archived"`
+	Topics   []string `json:"topics"`
+	Owner    struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// ListRepositories lists the repositories of an organisation or, if there is none of that name, of a user
+func ListRepositories(ctx context.Context, URL string, token string, owner string) ([]utils.Repository, error) {
+	repositories, err := listRepositories(ctx, URL+"/orgs/"+owner+"/repos?type=all&per_page=100", token)
+	if errors.Is(err, utils.ErrNotFound) {
+		repositories, err = listRepositories(ctx, URL+"/users/"+owner+"/repos?type=owner&per_page=100", token)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not list repositories of %s: %w", owner, err)
+	}
+	for i := range repositories {
+		repositories[i].Namespace = owner
+	}
+	return repositories, nil
+}
+
+func listRepositories(ctx context.Context, URL string, token string) ([]utils.Repository, error) {
+	apiData, err := utils.Paginate(ctx, URL, "github", token)
+	if err != nil {
+		return nil, err
+	}
+	repositories := []utils.Repository{}
+	for _, v := range apiData {
+		tmpR := []githubRepository{}
+		if err := json.Unmarshal(v, &tmpR); err != nil {
+			return nil, err
+		}
+		for _, r := range tmpR {
+			repositories = append(repositories, utils.Repository{
+				Path:     r.Name,
+				Name:     r.Name,
+				ID:       r.Name,
+				Archived: r.Archived,
+				Topics:   r.Topics,
+			})
+		}
+	}
+	return repositories, nil
+}
@@ -15,17 +15,24 @@ limitations under the License.
 package github
 
 import (
-	"log"
+	"context"
+	"errors"
+	"net/http"
 	"os"
 	"testing"
+	"time"
 
 	"go.okkur.org/gomiler/utils"
 	httpmock "gopkg.in/jarcoal/httpmock.v1"
 )
 
-var logger = log.New(os.Stderr, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
+func TestMain(m *testing.M) {
+	utils.DefaultClient.GithubWriteInterval = 0
+	utils.DefaultClient.Backoff = 0
+	os.Exit(m.Run())
+}
 
-func TestGithubCreateAndDisplayNewMilestones(t *testing.T) {
+func TestCreateMilestones(t *testing.T) {
 	milestoneData, err := utils.CreateMilestoneData(10, "daily", nil, "github")
 	if err != nil {
 		t.Error(err)
@@ -35,9 +42,17 @@ func TestGithubCreateAndDisplayNewMilestones(t *testing.T) {
 	mockURL := "https://" + "api.github.com"
 	MockGithubAPIGetRequest(mockURL, "open")
 	MockGithubAPIPostRequest(mockURL, "open")
-	err = CreateAndDisplayNewMilestones(mockURL, "213123", "1", milestoneData, logger)
-	if err != nil {
-		t.Error(err)
+	created, failed := CreateMilestones(context.Background(), mockURL, "213123", "1", milestoneData)
+	if len(failed) != 0 {
+		t.Errorf("Expected no failed milestones, got %v", failed)
+	}
+	if len(created) == 0 {
+		t.Error("Expected created milestones")
+	}
+	for k, m := range created {
+		if m.Title != k || m.DueDate != milestoneData[k].DueDate || m.URL != "https://github.com/namespace/1/milestone/1" {
+			t.Errorf("Unexpected created milestone %v", m)
+		}
 	}
 }
 
@@ -46,7 +61,7 @@ func TestGetActiveMilestones(t *testing.T) {
 	defer httpmock.DeactivateAndReset()
 	mockURL := "https://" + "api.github.com"
 	MockGithubAPIGetRequest(mockURL, "open")
-	activeMilestonesAPI, err := getActiveMilestones(mockURL, "token", "1")
+	activeMilestonesAPI, err := getActiveMilestones(context.Background(), mockURL, "token", "1")
 	if err != nil {
 		t.Error(err)
 	}
@@ -62,7 +77,7 @@ func TestGetInactiveMilestones(t *testing.T) {
 	defer httpmock.DeactivateAndReset()
 	mockURL := "https://" + "api.github.com"
 	MockGithubAPIGetRequest(mockURL, "closed")
-	inactiveMilestonesAPI, err := getInactiveMilestones(mockURL, "token", "1")
+	inactiveMilestonesAPI, err := getInactiveMilestones(context.Background(), mockURL, "token", "1")
 	if err != nil {
 		t.Error(err)
 	}
@@ -78,7 +93,7 @@ func TestReactivateClosedMilestones(t *testing.T) {
 	defer httpmock.DeactivateAndReset()
 	mockURL := "https://" + "api.github.com"
 	MockGithubAPIGetRequest(mockURL, "closed")
-	inactiveMilestonesAPI, err := getInactiveMilestones(mockURL, "token", "1")
+	inactiveMilestonesAPI, err := getInactiveMilestones(context.Background(), mockURL, "token", "1")
 	if err != nil {
 		t.Error(err)
 	}
@@ -86,7 +101,7 @@ func TestReactivateClosedMilestones(t *testing.T) {
 	for _, v := range inactiveMilestones {
 		MockGithubAPIPatchRequest(mockURL, "open", v.ID)
 	}
-	reactivatedMilestones, err := ReactivateClosedMilestones(inactiveMilestones, mockURL, "token", "1")
+	reactivatedMilestones, _, err := ReactivateClosedMilestones(context.Background(), inactiveMilestones, mockURL, "token", "1", "always")
 	if err != nil {
 		t.Error(err)
 	}
@@ -96,3 +111,428 @@ func TestReactivateClosedMilestones(t *testing.T) {
 		}
 	}
 }
+
+func TestGetExpiredMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIExpiredGetRequest(mockURL)
+	expiredMilestones, err := GetExpiredMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 0, false)
+	if err != nil {
+		t.Error(err)
+	}
+	yesterday := time.Now().Local().AddDate(0, 0, -1).Format("2006-01-02")
+	if len(expiredMilestones) != 1 {
+		t.Errorf("Expected %d, got %d", 1, len(expiredMilestones))
+	}
+	if _, ok := expiredMilestones[yesterday]; !ok {
+		t.Errorf("Expected milestone %s to be expired", yesterday)
+	}
+	expiredMilestones, err = GetExpiredMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 0, true)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(expiredMilestones) != 2 {
+		t.Errorf("Expected %d, got %d", 2, len(expiredMilestones))
+	}
+}
+
+func TestCloseExpiredMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIExpiredGetRequest(mockURL)
+	expiredMilestones, err := GetExpiredMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 0, true)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, v := range expiredMilestones {
+		MockGithubAPIPatchRequest(mockURL, "closed", v.ID)
+	}
+	closedMilestones, err := CloseExpiredMilestones(context.Background(), expiredMilestones, mockURL, "token", "1")
+	if err != nil {
+		t.Error(err)
+	}
+	for _, v := range closedMilestones {
+		if v.State != "closed" {
+			t.Errorf("Expected %s, got %s", "closed", v.State)
+		}
+	}
+}
+
+func TestGetNextMilestone(t *testing.T) {
+	milestoneData, err := utils.CreateMilestoneData(10, "daily", nil, "github")
+	if err != nil {
+		t.Error(err)
+	}
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIExpiredGetRequest(mockURL)
+	next, err := GetNextMilestone(context.Background(), mockURL, "token", "1", milestoneData)
+	if err != nil {
+		t.Error(err)
+	}
+	today := time.Now().Local().Format("2006-01-02")
+	if next.Title != today {
+		t.Errorf("Expected %s, got %s", today, next.Title)
+	}
+}
+
+func TestGetNextMilestoneSkipsUnmanaged(t *testing.T) {
+	tomorrow := time.Now().Local().AddDate(0, 0, 1).Format("2006-01-02")
+	later := time.Now().Local().AddDate(0, 0, 2).Format("2006-01-02")
+	milestoneData := map[string]utils.Milestone{tomorrow: {Title: tomorrow}, later: {Title: later}}
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	httpmock.RegisterResponder("GET", mockURL+"1/milestones", func(req *http.Request) (*http.Response, error) {
+		return httpmock.NewJsonResponse(200, []githubAPI{
+			// created by hand with a generated title
+			{Number: 1, Title: tomorrow, DueDate: utils.GithubDueDate(tomorrow), State: "open"},
+			{Number: 2, Title: later, DueDate: utils.GithubDueDate(later), Description: utils.Marker, State: "open"},
+		})
+	})
+	next, err := GetNextMilestone(context.Background(), mockURL, "token", "1", milestoneData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Title != later {
+		t.Errorf("Expected %s, got %s", later, next.Title)
+	}
+}
+
+func TestRollOverMilestones(t *testing.T) {
+	milestoneData, err := utils.CreateMilestoneData(10, "daily", nil, "github")
+	if err != nil {
+		t.Error(err)
+	}
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIExpiredGetRequest(mockURL)
+	MockGithubAPIIssuesRequest(mockURL)
+	expiredMilestones, err := GetExpiredMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 0, true)
+	if err != nil {
+		t.Error(err)
+	}
+	next, err := GetNextMilestone(context.Background(), mockURL, "token", "1", milestoneData)
+	if err != nil {
+		t.Error(err)
+	}
+	items, err := RollOverMilestones(context.Background(), expiredMilestones, next, mockURL, "token", "1", "Rolled over", "rolled-over")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(items) != 2*len(expiredMilestones) {
+		t.Errorf("Expected %d, got %d", 2*len(expiredMilestones), len(items))
+	}
+	for _, item := range items {
+		if item.To != next.Title {
+			t.Errorf("Expected %s, got %s", next.Title, item.To)
+		}
+		if item.Number == 2 && item.Kind != "pull_request" {
+			t.Errorf("Expected %s, got %s", "pull_request", item.Kind)
+		}
+	}
+}
+
+func TestGetPrunableMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIExpiredGetRequest(mockURL)
+	prunableMilestones, err := GetPrunableMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 0)
+	if err != nil {
+		t.Error(err)
+	}
+	yesterday := time.Now().Local().AddDate(0, 0, -1).Format("2006-01-02")
+	if len(prunableMilestones) != 1 {
+		t.Errorf("Expected %d, got %d", 1, len(prunableMilestones))
+	}
+	if _, ok := prunableMilestones[yesterday]; !ok {
+		t.Errorf("Expected milestone %s to be prunable", yesterday)
+	}
+	prunableMilestones, err = GetPrunableMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 1)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(prunableMilestones) != 0 {
+		t.Errorf("Expected %d, got %d", 0, len(prunableMilestones))
+	}
+}
+
+func TestDeleteMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIExpiredGetRequest(mockURL)
+	prunableMilestones, err := GetPrunableMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 0)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, v := range prunableMilestones {
+		MockGithubAPIDeleteRequest(mockURL, v.ID)
+	}
+	deletedMilestones, err := DeleteMilestones(context.Background(), prunableMilestones, mockURL, "token", "1")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(deletedMilestones) != len(prunableMilestones) {
+		t.Errorf("Expected %d, got %d", len(prunableMilestones), len(deletedMilestones))
+	}
+}
+
+func TestGetDriftedMilestones(t *testing.T) {
+	milestoneData, err := utils.CreateMilestoneData(10, "daily", nil, "github")
+	if err != nil {
+		t.Error(err)
+	}
+	today := time.Now().Local().Format("2006-01-02")
+	m := milestoneData[today]
+	m.Description = "daily sprint"
+	milestoneData[today] = m
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIExpiredGetRequest(mockURL)
+	drifts, err := GetDriftedMilestones(context.Background(), mockURL, "token", "1", milestoneData)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(drifts) != 1 {
+		t.Fatalf("Expected %d, got %d", 1, len(drifts))
+	}
+	if drifts[0].Existing.Title != today {
+		t.Errorf("Expected %s, got %s", today, drifts[0].Existing.Title)
+	}
+	found := false
+	for _, field := range drifts[0].Fields {
+		if field == "description" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected description drift, got %v", drifts[0].Fields)
+	}
+}
+
+func TestUpdateDriftedMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIPatchRequest(mockURL, "open", "1")
+	drifts := []utils.Drift{
+		{
+			Existing: utils.Milestone{ID: "1", Number: 1, Title: "2017-03", DueDate: "2017-03-30"},
+			Desired:  utils.Milestone{Title: "2017-03", DueDate: "2017-03-31"},
+			Fields:   []string{"due date"},
+		},
+	}
+	err := UpdateDriftedMilestones(context.Background(), drifts, mockURL, "token", "1")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRenameMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIPatchRequest(mockURL, "open", "1")
+	renames := []utils.Rename{
+		{Milestone: utils.Milestone{ID: "1", Number: 1, Title: "2017-w9"}, Title: "Sprint 2017-9"},
+	}
+	renamed, err := RenameMilestones(context.Background(), renames, mockURL, "token", "1")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(renamed) != len(renames) {
+		t.Errorf("Expected %d, got %d", len(renames), len(renamed))
+	}
+}
+
+func TestRenameMilestonesPartialFailure(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIPatchRequest(mockURL, "open", "1")
+	httpmock.RegisterResponder("PATCH", mockURL+"1/milestones/2", httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
+	renames := []utils.Rename{
+		{Milestone: utils.Milestone{ID: "1", Number: 1, Title: "2017-w9"}, Title: "Sprint 2017-9"},
+		{Milestone: utils.Milestone{ID: "2", Number: 2, Title: "2017-w10"}, Title: "Sprint 2017-10"},
+	}
+	renamed, err := RenameMilestones(context.Background(), renames, mockURL, "token", "1")
+	failed, ok := err.(utils.MilestoneErrors)
+	if !ok || len(failed) != 1 || failed["2017-w10"] == nil || !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("Expected the error of the failed rename by old title, got %v", err)
+	}
+	if len(renamed) != 1 || renamed[0].Milestone.Title != "2017-w9" {
+		t.Errorf("Expected the other milestone to be renamed, got %v", renamed)
+	}
+}
+
+func TestReactivateClosedMilestonesWithPolicy(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	inactiveMilestones := map[string]utils.Milestone{
+		"2017-03-04": {Number: 1, Title: "2017-03-04", State: "closed", ClosedIssues: 1},
+		"2017-03-05": {Number: 2, Title: "2017-03-05", State: "closed"},
+	}
+	MockGithubAPIPatchRequest(mockURL, "open", "2")
+	reactivatedMilestones, skippedMilestones, err := ReactivateClosedMilestones(context.Background(), inactiveMilestones, mockURL, "token", "1", "only-if-empty")
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := reactivatedMilestones["2017-03-05"]; !ok || len(reactivatedMilestones) != 1 {
+		t.Errorf("Expected only %s to be reactivated, got %v", "2017-03-05", reactivatedMilestones)
+	}
+	if _, ok := skippedMilestones["2017-03-04"]; !ok {
+		t.Errorf("Expected %s to be skipped", "2017-03-04")
+	}
+	reactivatedMilestones, skippedMilestones, err = ReactivateClosedMilestones(context.Background(), inactiveMilestones, mockURL, "token", "1", "never")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(reactivatedMilestones) != 0 || len(skippedMilestones) != 2 {
+		t.Errorf("Expected all milestones to be skipped, got %v", skippedMilestones)
+	}
+}
+
+func TestAdoptMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIExpiredGetRequest(mockURL)
+	adoptableMilestones, err := GetAdoptableMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"))
+	if err != nil {
+		t.Error(err)
+	}
+	unmanaged := time.Now().Local().AddDate(0, 0, -4).Format("2006-01-02")
+	if _, ok := adoptableMilestones[unmanaged]; !ok || len(adoptableMilestones) != 1 {
+		t.Errorf("Expected only %s to be adoptable, got %v", unmanaged, adoptableMilestones)
+	}
+	for _, v := range adoptableMilestones {
+		MockGithubAPIPatchRequest(mockURL, "open", v.ID)
+	}
+	adoptedMilestones, err := AdoptMilestones(context.Background(), adoptableMilestones, mockURL, "token", "1")
+	if err != nil {
+		t.Error(err)
+	}
+	for _, v := range adoptedMilestones {
+		if !utils.IsManaged(v.Description) {
+			t.Errorf("Expected %s to be managed", v.Title)
+		}
+	}
+}
+
+func TestAdoptMilestonesPartialFailure(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIPatchRequest(mockURL, "open", "1")
+	httpmock.RegisterResponder("PATCH", mockURL+"1/milestones/2", httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
+	milestones := map[string]utils.Milestone{
+		"2018-01-01": {Title: "2018-01-01", Number: 1},
+		"2018-01-02": {Title: "2018-01-02", Number: 2},
+	}
+	adoptedMilestones, err := AdoptMilestones(context.Background(), milestones, mockURL, "token", "1")
+	failed, ok := err.(utils.MilestoneErrors)
+	if !ok || len(failed) != 1 || failed["2018-01-02"] == nil || !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("Expected the error of the failed milestone, got %v", err)
+	}
+	if _, ok := adoptedMilestones["2018-01-01"]; !ok || len(adoptedMilestones) != 1 {
+		t.Errorf("Expected the other milestone to be adopted, got %v", adoptedMilestones)
+	}
+}
+
+func TestGetMilestoneIssues(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIIssuesRequest(mockURL)
+	issues, err := GetMilestoneIssues(context.Background(), mockURL, "token", "1", utils.Milestone{Number: 1})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(issues) != 1 {
+		t.Errorf("Expected %d, got %d", 1, len(issues))
+	}
+}
+
+func TestGetReleaseItems(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIClosedIssuesRequest(mockURL)
+	items, err := GetReleaseItems(context.Background(), mockURL, "token", "1", utils.Milestone{Number: 1})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected %d, got %d", 2, len(items))
+	}
+	if items[0].Kind != "issue" || items[0].Labels[0] != "bug" || items[0].URL == "" {
+		t.Errorf("Unexpected issue %v", items[0])
+	}
+	if items[1].Kind != "pull_request" || items[1].Number != 3 || items[1].Author != "octocat" {
+		t.Errorf("Unexpected pull request %v", items[1])
+	}
+}
+
+func TestPublishRelease(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIReleaseRequest(mockURL)
+	cases := map[string]string{
+		"new":      "https://github.com/namespace/1/releases/tag/new",
+		"existing": "https://github.com/namespace/1/releases/tag/existing",
+	}
+	for tag, expected := range cases {
+		releaseURL, err := PublishRelease(context.Background(), mockURL, "token", "1", tag, "", tag, "notes")
+		if err != nil {
+			t.Error(err)
+		}
+		if releaseURL != expected {
+			t.Errorf("Expected %s, got %s", expected, releaseURL)
+		}
+	}
+}
+
+func TestListRepositories(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	MockGithubAPIRepositoriesRequest(mockURL, "octocat")
+	repositories, err := ListRepositories(context.Background(), mockURL, "token", "octocat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repositories) != 3 {
+		t.Fatalf("Expected %d, got %d", 3, len(repositories))
+	}
+	r := repositories[2]
+	if r.Path != "old" || r.ID != "old" || r.Namespace != "octocat" || !r.Archived || r.Topics[0] != "gomiler" {
+		t.Errorf("Unexpected repository %v", r)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	httpmock.RegisterResponder("GET", mockURL+"1/milestones", httpmock.NewStringResponder(401, `{"message": "Bad credentials"}`))
+	httpmock.RegisterResponder("POST", mockURL+"1/milestones", httpmock.NewStringResponder(422,
+		`{"message": "Validation Failed", "errors": [{"resource": "Milestone", "field": "title", "code": "already_exists"}]}`))
+
+	_, err := GetAllMilestones(context.Background(), mockURL, "token", "1")
+	if !errors.Is(err, utils.ErrUnauthorized) {
+		t.Errorf("Expected an unauthorized error, got %v", err)
+	}
+	_, failed := CreateMilestones(context.Background(), mockURL, "token", "1", map[string]utils.Milestone{"2018-01-01": {Title: "2018-01-01"}})
+	if failed["2018-01-01"] != "POST https://api.github.com1/milestones returned 422: Validation Failed: title already_exists" {
+		t.Errorf("Unexpected failure %v", failed)
+	}
+}
@@ -15,11 +15,13 @@ limitations under the License.
 package github
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"go.okkur.org/gomiler/utils"
 	httpmock "gopkg.in/jarcoal/httpmock.v1"
 )
 
@@ -48,6 +50,48 @@ func MockGithubAPI(state string) []githubAPI {
 	return githubAPImock
 }
 
+// MockGithubAPIExpired populates a []githubAPI with open daily milestones that are past due, due today, not generated or not managed by gomiler
+func MockGithubAPIExpired() []githubAPI {
+	now := time.Now().Local()
+	githubAPImock := []githubAPI{}
+	for i := 0; i < 5; i++ {
+		mock := githubAPI{}
+		date := now.AddDate(0, 0, -i)
+		mock.ID = i
+		mock.Number = i
+		mock.Title = date.Format("2006-01-02")
+		mock.DueDate = utils.GithubDueDate(mock.Title)
+		mock.Description = utils.Marker
+		mock.State = "open"
+		githubAPImock = append(githubAPImock, mock)
+	}
+	// milestone with open issues
+	githubAPImock[3].OpenIssues = 2
+	// milestone not following the naming scheme
+	githubAPImock[2].Title = "Release " + githubAPImock[2].Title
+	// milestone not managed by gomiler
+	githubAPImock[4].Description = ""
+
+	return githubAPImock
+}
+
+// MockGithubAPIExpiredGetRequest creates a mock responder for the milestone endpoint and sends back expired milestones
+func MockGithubAPIExpiredGetRequest(URL string) {
+	json := MockGithubAPIExpired()
+	var strURL []string
+	strURL = []string{URL, "1", "/milestones"}
+	newURL := strings.Join(strURL, "")
+	httpmock.RegisterResponder("GET", newURL,
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(200, json)
+			if err != nil {
+				return httpmock.NewStringResponse(500, ""), nil
+			}
+			return resp, nil
+		},
+	)
+}
+
 // MockGithubAPIGetRequest creates a mock responder for a specific milestone endpoint and sends back mock JSON data
 func MockGithubAPIGetRequest(URL string, state string) {
 	json := MockGithubAPI(state)
@@ -66,15 +110,22 @@ func MockGithubAPIGetRequest(URL string, state string) {
 	)
 }
 
-// MockGithubAPIPostRequest creates a mock responder for a specific milestone endpoint and sends back mock JSON data
+// MockGithubAPIPostRequest creates a mock responder for a specific milestone endpoint and sends back the created milestone
 func MockGithubAPIPostRequest(URL string, state string) {
-	json := MockGithubAPI(state)
 	var strURL []string
 	strURL = []string{URL, "1", "/milestones"}
 	newURL := strings.Join(strURL, "")
 	httpmock.RegisterResponder("POST", newURL,
 		func(req *http.Request) (*http.Response, error) {
-			resp, err := httpmock.NewJsonResponse(200, json)
+			var created githubAPI
+			err := json.NewDecoder(req.Body).Decode(&created)
+			if err != nil {
+				return httpmock.NewStringResponse(422, ""), nil
+			}
+			created.Number = 1
+			created.State = state
+			created.URL = "https://github.com/namespace/1/milestone/1"
+			resp, err := httpmock.NewJsonResponse(201, created)
 			if err != nil {
 				return httpmock.NewStringResponse(500, ""), nil
 			}
@@ -99,3 +150,107 @@ func MockGithubAPIPatchRequest(URL string, state string, id string) {
 		},
 	)
 }
+
+// MockGithubAPIIssuesRequest creates mock responders for listing, updating, labeling and commenting on an issue and a pull request
+func MockGithubAPIIssuesRequest(URL string) {
+	json := []githubIssue{
+		{Number: 1, Title: "issue", State: "open"},
+		{Number: 2, Title: "pull request", State: "open", PullRequest: &githubPullRequest{}},
+	}
+	var strURL []string
+	strURL = []string{URL, "1", "/issues"}
+	newURL := strings.Join(strURL, "")
+	httpmock.RegisterResponder("GET", newURL,
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(200, json)
+			if err != nil {
+				return httpmock.NewStringResponse(500, ""), nil
+			}
+			return resp, nil
+		},
+	)
+	for _, v := range json {
+		issueURL := newURL + "/" + strconv.Itoa(v.Number)
+		httpmock.RegisterResponder("PATCH", issueURL, httpmock.NewStringResponder(200, "{}"))
+		httpmock.RegisterResponder("POST", issueURL+"/labels", httpmock.NewStringResponder(200, "[]"))
+		httpmock.RegisterResponder("POST", issueURL+"/comments", httpmock.NewStringResponder(201, "{}"))
+	}
+}
+
+// MockGithubAPIClosedIssuesRequest creates a mock responder for the closed issues and pull requests of a milestone
+func MockGithubAPIClosedIssuesRequest(URL string) {
+	merged := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	json := []githubIssue{
+		{Number: 1, Title: "fixed bug", State: "closed", URL: "https://github.com/namespace/1/issues/1"},
+		{Number: 2, Title: "rejected pull request", State: "closed", PullRequest: &githubPullRequest{}},
+		{Number: 3, Title: "merged pull request", State: "closed", PullRequest: &githubPullRequest{MergedAt: &merged}},
+	}
+	json[0].Labels = append(json[0].Labels, struct {
+		Name string `json:"name"`
+	}{Name: "bug"})
+	json[2].User.Login = "octocat"
+	var strURL []string
+	strURL = []string{URL, "1", "/issues"}
+	newURL := strings.Join(strURL, "")
+	httpmock.RegisterResponder("GET", newURL,
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(200, json)
+			if err != nil {
+				return httpmock.NewStringResponse(500, ""), nil
+			}
+			return resp, nil
+		},
+	)
+}
+
+// MockGithubAPIReleaseRequest creates mock responders for listing, creating and updating releases.
+// A release with tag "existing" and ID 1 exists already.
+func MockGithubAPIReleaseRequest(URL string) {
+	var strURL []string
+	strURL = []string{URL, "1", "/releases"}
+	newURL := strings.Join(strURL, "")
+	httpmock.RegisterResponder("GET", newURL, httpmock.NewStringResponder(200, `[{"id": 1, "tag_name": "existing"}]`))
+	httpmock.RegisterResponder("POST", newURL, httpmock.NewStringResponder(201, `{"id": 2, "html_url": "https://github.com/namespace/1/releases/tag/new"}`))
+	httpmock.RegisterResponder("PATCH", newURL+"/1", httpmock.NewStringResponder(200, `{"id": 1, "html_url": "https://github.com/namespace/1/releases/tag/existing"}`))
+}
+
+// MockGithubAPIDeleteRequest creates a mock responder for deleting a specific milestone
+func MockGithubAPIDeleteRequest(URL string, id string) {
+	var strURL []string
+	strURL = []string{URL, "1", "/milestones/", id}
+	newURL := strings.Join(strURL, "")
+	httpmock.RegisterResponder("DELETE", newURL, httpmock.NewStringResponder(204, ""))
+}
+
+// MockGithubAPIRepositoriesRequest creates mock responders listing the repositories of a user, which is no organisation
+func MockGithubAPIRepositoriesRequest(URL string, owner string) {
+	httpmock.RegisterResponder("GET", URL+"/orgs/"+owner+"/repos", httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
+	httpmock.RegisterResponder("GET", URL+"/users/"+owner+"/repos", httpmock.NewStringResponder(200, `[
+		{"name": "app", "archived": false, "topics": ["gomiler"]},
+		{"name": "lib", "archived": false, "topics": []},
+		{"name": "old", "archived": true, "topics": ["gomiler"]}
+	]`))
+}
+
+// MockGithubAppRequests creates mock responders for the installation 7 of a GitHub App on owner, which is a user,
+// and its repository app. Installation tokens are numbered ghs-1, ghs-2, ... and expire after the lifetime.
+// It returns the number of tokens issued.
+func MockGithubAppRequests(URL string, owner string, lifetime time.Duration) *int {
+	issued := 0
+	installation := httpmock.NewStringResponder(200, `{"id": 7}`)
+	httpmock.RegisterResponder("GET", URL+"/orgs/"+owner+"/installation", httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
+	httpmock.RegisterResponder("GET", URL+"/users/"+owner+"/installation", installation)
+	httpmock.RegisterResponder("GET", URL+"/repos/"+owner+"/app/installation", installation)
+	httpmock.RegisterResponder("POST", URL+"/app/installations/7/access_tokens", func(req *http.Request) (*http.Response, error) {
+		if !strings.HasPrefix(req.Header.Get("Authorization"), "Bearer ") {
+			return httpmock.NewStringResponse(401, `{"message": "A JSON web token could not be decoded"}`), nil
+		}
+		issued++
+		token := map[string]interface{}{
+			"token":      "ghs-" + strconv.Itoa(issued),
+			"expires_at": time.Now().Add(lifetime).UTC().Format(time.RFC3339),
+		}
+		return httpmock.NewJsonResponse(201, token)
+	})
+	return &issued
+}
@@ -15,10 +15,10 @@ limitations under the License.
 package gitlab
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
@@ -41,6 +41,7 @@ type gitlabAPI struct {
 	UpdatedAt   *time.Time `json:"updated_at"`
 	CreatedAt   *time.Time `json:"created_at"`
 	Name        string     `json:"name"`
+	WebURL      string     `json:"web_url"`
 	NameSpace   struct {
 		ID       int    `json:"id"`
 		Name     string `json:"name"`
@@ -51,34 +52,32 @@ type gitlabAPI struct {
 }
 
 // GetProjectID function that gets a project's ID from the gitLabAPI
-func GetProjectID(baseURL string, token string, projectname string, namespace string) (string, error) {
+func GetProjectID(ctx context.Context, baseURL string, token string, projectname string, namespace string) (string, error) {
 	strURL := []string{baseURL, "/projects/"}
 	URL := strings.Join(strURL, "")
 	u, _ := url.Parse(URL)
 	q := u.Query()
 	q.Set("search", projectname)
 	u.RawQuery = q.Encode()
-	apiData, err := utils.Paginate(u.String(), "gitlab", token)
+	apiData, err := utils.Paginate(ctx, u.String(), "gitlab", token)
 	if err != nil {
 		return "", err
 	}
 	projects := []gitlabAPI{}
 	tmpM := []gitlabAPI{}
 	for _, v := range apiData {
-		json.Unmarshal(v, &tmpM)
+		if err := json.Unmarshal(v, &tmpM); err != nil {
+			return "", err
+		}
 		projects = append(projects, tmpM...)
 	}
 	for _, p := range projects {
-		// Check for returned error messages
-		if p.Name == "message" {
-			return "", fmt.Errorf("api returned error %s", "error")
-		}
 		if p.Name == projectname && p.NameSpace.Path == namespace {
 			return strconv.Itoa(p.ID), nil
 		}
 	}
 
-	return "", fmt.Errorf("project %s not found", projectname)
+	return "", fmt.Errorf("project %s %w", projectname, utils.ErrNotFound)
 }
 
 func createGitlabMilestoneMap(gitlabAPI []gitlabAPI) map[string]utils.Milestone {
@@ -86,8 +85,12 @@ func createGitlabMilestoneMap(gitlabAPI []gitlabAPI) map[string]utils.Milestone
 	for _, v := range gitlabAPI {
 		var m utils.Milestone
 		m.DueDate = v.DueDate
+		m.StartDate = v.StartDate
+		m.Description = v.Description
 		m.ID = strconv.Itoa(v.ID)
 		m.Title = v.Title
+		m.State = v.State
+		m.URL = v.WebURL
 		milestones[v.Title] = m
 	}
 
@@ -95,61 +98,98 @@ func createGitlabMilestoneMap(gitlabAPI []gitlabAPI) map[string]utils.Milestone
 }
 
 // Get and return currently active milestones
-func getActiveMilestones(baseURL string, token string, projectID string) ([]gitlabAPI, error) {
+func getActiveMilestones(ctx context.Context, baseURL string, token string, projectID string) ([]gitlabAPI, error) {
 	var state string
 	state = "active"
-	return getMilestones(baseURL, token, projectID, state)
+	return getMilestones(ctx, baseURL, token, projectID, state)
 }
 
 // Get and return inactive milestones
-func getInactiveMilestones(baseURL string, token string, project string) ([]gitlabAPI, error) {
+func getInactiveMilestones(ctx context.Context, baseURL string, token string, project string) ([]gitlabAPI, error) {
 	state := "closed"
-	return getMilestones(baseURL, token, project, state)
+	return getMilestones(ctx, baseURL, token, project, state)
 }
 
-// ReactivateClosedMilestones reactivates closed milestones that occur in the future
-func ReactivateClosedMilestones(
+// GetReactivatableMilestones splits closed milestones into those allowed to be reactivated by policy
+// and those that are not, with the reason
+func GetReactivatableMilestones(
+	ctx context.Context,
 	milestones map[string]utils.Milestone,
 	baseURL string,
 	token string,
 	project string,
-	logger *log.Logger,
-) (map[string]utils.Milestone, error) {
-	client := http.Client{}
-	var strURL []string
-	for _, v := range milestones {
-		milestoneID := v.ID
-		strURL = []string{baseURL, "/projects/", project, "/milestones/", milestoneID}
-		URL := strings.Join(strURL, "")
-		var req *http.Request
+	policy string,
+) (map[string]utils.Milestone, map[string]string, error) {
+	now := time.Now()
+	reactivatableMilestones := make(map[string]utils.Milestone, len(milestones))
+	skippedMilestones := map[string]string{}
+	counts := map[string]int{}
+	if policy == "only-if-empty" {
 		var err error
-		// Overwrite state information in URL
-		u, _ := url.Parse(URL)
-		q := u.Query()
-		q.Set("state_event", "activate")
-		u.RawQuery = q.Encode()
-		req, err = http.NewRequest("PUT", u.String(), nil)
-		if err != nil {
-			logger.Println(err)
-		}
-		req.Header.Add("PRIVATE-TOKEN", token)
-		resp, err := client.Do(req)
+		counts, err = getItemCounts(ctx, baseURL, token, project, milestones)
 		if err != nil {
-			return nil, err
+			return reactivatableMilestones, skippedMilestones, err
 		}
-		defer resp.Body.Close()
 	}
-	// copy map of milestones with states changed to active for testing purposes
-	reactivatedMilestones := make(map[string]utils.Milestone, len(milestones))
 	for k, v := range milestones {
-		v.State = "active"
-		reactivatedMilestones[k] = v
+		reason := utils.ReactivationSkipReason(v, policy, counts[k], now)
+		if reason != "" {
+			skippedMilestones[k] = reason
+			continue
+		}
+		reactivatableMilestones[k] = v
+	}
+
+	return reactivatableMilestones, skippedMilestones, nil
+}
+
+// ReactivateClosedMilestones reactivates closed milestones that occur in the future.
+// Milestones not allowed to be reactivated by policy are returned with the reason.
+func ReactivateClosedMilestones(
+	ctx context.Context,
+	milestones map[string]utils.Milestone,
+	baseURL string,
+	token string,
+	project string,
+	policy string,
+) (map[string]utils.Milestone, map[string]string, error) {
+	reactivatableMilestones, skippedMilestones, err := GetReactivatableMilestones(ctx, milestones, baseURL, token, project, policy)
+	if err != nil {
+		return nil, skippedMilestones, err
 	}
+	reactivatedMilestones, failed := utils.ForEachMilestone(ctx, reactivatableMilestones, func(v utils.Milestone) (utils.Milestone, error) {
+		v.State = "active"
+		return v, updateMilestoneState(ctx, baseURL, token, project, v.ID, "activate")
+	})
 
-	return reactivatedMilestones, nil
+	return reactivatedMilestones, skippedMilestones, failed.Err()
+}
+
+// CloseExpiredMilestones closes milestones whose due date has passed
+func CloseExpiredMilestones(
+	ctx context.Context,
+	milestones map[string]utils.Milestone,
+	baseURL string,
+	token string,
+	project string,
+) (map[string]utils.Milestone, error) {
+	closedMilestones, failed := utils.ForEachMilestone(ctx, milestones, func(v utils.Milestone) (utils.Milestone, error) {
+		v.State = "closed"
+		return v, updateMilestoneState(ctx, baseURL, token, project, v.ID, "close")
+	})
+
+	return closedMilestones, failed.Err()
+}
+
+func updateMilestoneState(ctx context.Context, baseURL string, token string, project string, milestoneID string, stateEvent string) error {
+	strURL := []string{baseURL, "/projects/", project, "/milestones/", milestoneID}
+	URL := strings.Join(strURL, "")
+	params := url.Values{}
+	params.Set("state_event", stateEvent)
+	return sendRequest(ctx, "PUT", URL, token, params, nil)
 }
 
-func getMilestones(baseURL string, token string, project string, state string) ([]gitlabAPI, error) {
+func getMilestones(ctx context.Context, baseURL string, token string, project string, state string) ([]gitlabAPI, error) {
 	var strURL []string
 	var URL, newURL string
 	var apiData [][]byte
@@ -157,106 +197,636 @@ func getMilestones(baseURL string, token string, project string, state string) (
 	URL = strings.Join(strURL, "")
 	u, _ := url.Parse(URL)
 	q := u.Query()
-	q.Set("state", state)
+	// An empty state lists active and closed milestones
+	if state != "" {
+		q.Set("state", state)
+	}
 	u.RawQuery = q.Encode()
 	newURL = u.String()
-	apiData, err := utils.Paginate(newURL, "gitlab", token)
+	apiData, err := utils.Paginate(ctx, newURL, "gitlab", token)
 	if err != nil {
 		return nil, err
 	}
 	milestones := []gitlabAPI{}
 	tmpM := []gitlabAPI{}
 	for _, v := range apiData {
-		json.Unmarshal(v, &tmpM)
+		if err := json.Unmarshal(v, &tmpM); err != nil {
+			return nil, err
+		}
 		milestones = append(milestones, tmpM...)
 	}
 	return milestones, nil
 }
 
-func createMilestones(baseURL string, token string, project string, milestones map[string]utils.Milestone) error {
-	client := http.Client{}
+// CreateMilestones creates milestones, returning the created ones and the errors of those that failed
+func CreateMilestones(ctx context.Context, baseURL string, token string, project string, milestones map[string]utils.Milestone) (map[string]utils.Milestone, map[string]string) {
 	var strURL []string
 	strURL = []string{baseURL, "/projects/", project, "/milestones"}
 	URL := strings.Join(strURL, "")
-	params := url.Values{}
-	for _, v := range milestones {
-		var req *http.Request
-		var err error
-
+	createdMilestones, failed := utils.ForEachMilestone(ctx, milestones, func(v utils.Milestone) (utils.Milestone, error) {
+		params := url.Values{}
 		params.Set("due_date", v.DueDate)
 		params.Set("title", v.Title)
-		req, err = http.NewRequest("POST", URL, strings.NewReader((params.Encode())))
+		params.Set("start_date", v.StartDate)
+		params.Set("description", utils.AddMarker(v.Description))
+		var created gitlabAPI
+		err := sendRequest(ctx, "POST", URL, token, params, &created)
 		if err != nil {
-			return err
+			return v, err
+		}
+		return createGitlabMilestoneMap([]gitlabAPI{created})[created.Title], nil
+	})
+
+	return createdMilestones, failed.Reasons()
+}
+
+// GetClosedMilestones gets closed milestones
+func GetClosedMilestones(ctx context.Context, baseURL string, token string, projectID string, milestoneData map[string]utils.Milestone) (map[string]utils.Milestone, error) {
+	closedMilestonesAPI, err := getInactiveMilestones(ctx, baseURL, token, projectID)
+	if err != nil {
+		return nil, err
+	}
+	closedGitlabMilestones := createGitlabMilestoneMap(closedMilestonesAPI)
+
+	// copy map of closed milestones
+	milestones := map[string]utils.Milestone{}
+	for k := range milestoneData {
+		for ek, ev := range closedGitlabMilestones {
+			if k == ek && utils.IsManaged(ev.Description) {
+				milestones[ek] = ev
+			}
+		}
+	}
+	return milestones, nil
+}
+
+// gitlabItem struct for issues and merge requests
+type gitlabItem struct {
+	ID        int        `json:"id"`
+	Iid       int        `json:"iid"`
+	Title     string     `json:"title"`
+	State     string     `json:"state"`
+	WebURL    string     `json:"web_url"`
+	Labels    []string   `json:"labels"`
+	Weight    int        `json:"weight"`
+	CreatedAt *time.Time `json:"created_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+	Author    struct {
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+// Convert a GitLab issue or merge request, kind being either "issue" or "merge_request"
+func (v gitlabItem) issue(kind string) utils.Issue {
+	return utils.Issue{
+		Kind:      kind,
+		Number:    v.Iid,
+		Title:     v.Title,
+		State:     v.State,
+		URL:       v.WebURL,
+		Author:    v.Author.Username,
+		Labels:    v.Labels,
+		Weight:    v.Weight,
+		CreatedAt: v.CreatedAt,
+		ClosedAt:  v.ClosedAt,
+	}
+}
+
+// Get and return issues or merge requests assigned to a milestone, kind being either "issues" or "merge_requests"
+func getItems(ctx context.Context, baseURL string, token string, project string, kind string, title string, state string) ([]gitlabItem, error) {
+	strURL := []string{baseURL, "/projects/", project, "/", kind}
+	URL := strings.Join(strURL, "")
+	u, err := url.Parse(URL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("milestone", title)
+	q.Set("state", state)
+	u.RawQuery = q.Encode()
+	apiData, err := utils.Paginate(ctx, u.String(), "gitlab", token)
+	if err != nil {
+		return nil, err
+	}
+	items := []gitlabItem{}
+	for _, v := range apiData {
+		tmpI := []gitlabItem{}
+		if err := json.Unmarshal(v, &tmpI); err != nil {
+			return nil, err
 		}
-		req.Header.Add("PRIVATE-TOKEN", token)
-		resp, err := client.Do(req)
+		items = append(items, tmpI...)
+	}
+	return items, nil
+}
+
+// Get and return open issues or merge requests assigned to a milestone
+func getOpenItems(ctx context.Context, baseURL string, token string, project string, kind string, title string) ([]gitlabItem, error) {
+	return getItems(ctx, baseURL, token, project, kind, title, "opened")
+}
+
+// Get and return the number of open issues assigned to a milestone
+func getOpenIssueCount(ctx context.Context, baseURL string, token string, project string, title string) (int, error) {
+	issues, err := getOpenItems(ctx, baseURL, token, project, "issues", title)
+	if err != nil {
+		return 0, err
+	}
+	return len(issues), nil
+}
+
+// Send a request with form encoded parameters.
+// The response is decoded into v unless v is nil.
+func sendRequest(ctx context.Context, method string, URL string, token string, params url.Values, v interface{}) error {
+	header := utils.APIHeader("gitlab", token)
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, body, err := utils.DefaultClient.Send(ctx, "gitlab", method, URL, header, []byte(params.Encode()))
+	if err != nil {
+		return err
+	}
+	if err := utils.CheckResponse(method, URL, resp, body); err != nil {
+		return err
+	}
+	if v != nil {
+		return json.Unmarshal(body, v)
+	}
+
+	return nil
+}
+
+// Move an issue or merge request to a milestone, optionally labeling and commenting on it
+func moveItem(ctx context.Context, baseURL string, token string, project string, kind string, iid int, milestoneID string, comment string, label string) error {
+	strURL := []string{baseURL, "/projects/", project, "/", kind, "/", strconv.Itoa(iid)}
+	URL := strings.Join(strURL, "")
+	params := url.Values{}
+	params.Set("milestone_id", milestoneID)
+	if label != "" {
+		params.Set("add_labels", label)
+	}
+	err := sendRequest(ctx, "PUT", URL, token, params, nil)
+	if err != nil {
+		return err
+	}
+	if comment != "" {
+		params = url.Values{}
+		params.Set("body", comment)
+		err = sendRequest(ctx, "POST", URL+"/notes", token, params, nil)
 		if err != nil {
 			return err
 		}
-		defer resp.Body.Close()
 	}
 
 	return nil
 }
 
-// CreateAndDisplayNewMilestones creates and displays new milestones
-func CreateAndDisplayNewMilestones(baseURL string, token string,
-	projectID string, milestoneData map[string]utils.Milestone, logger *log.Logger) error {
-	activeMilestonesAPI, err := getActiveMilestones(baseURL, token, projectID)
+// GetNextMilestone gets the active milestone of milestoneData with the earliest due date that has not passed yet.
+// Milestones without the gomiler marker are not rolled over to.
+func GetNextMilestone(ctx context.Context, baseURL string, token string, projectID string, milestoneData map[string]utils.Milestone) (utils.Milestone, error) {
+	activeMilestonesAPI, err := getActiveMilestones(ctx, baseURL, token, projectID)
 	if err != nil {
-		return err
+		return utils.Milestone{}, err
 	}
 	activeMilestones := createGitlabMilestoneMap(activeMilestonesAPI)
 
-	// copy map of active milestones
-	newMilestones := map[string]utils.Milestone{}
-	for k, v := range milestoneData {
-		newMilestones[k] = v
-	}
+	now := time.Now()
+	var next utils.Milestone
+	var nextDue time.Time
 	for k := range milestoneData {
-		for ok := range activeMilestones {
-			if k == ok {
-				delete(newMilestones, k)
+		m, ok := activeMilestones[k]
+		if !ok || !utils.IsManaged(m.Description) || utils.DueDatePassed(m.DueDate, 0, now) {
+			continue
+		}
+		due, err := utils.ParseDueDate(m.DueDate)
+		if err != nil {
+			continue
+		}
+		if next.Title == "" || due.Before(nextDue) {
+			next = m
+			nextDue = due
+		}
+	}
+	if next.Title == "" {
+		return next, fmt.Errorf("no active milestone to roll over to")
+	}
+
+	return next, nil
+}
+
+// RollOverMilestones moves open issues and merge requests of milestones to the next milestone.
+// Moved items are labeled with label and commented on with comment, if set.
+func RollOverMilestones(
+	ctx context.Context,
+	milestones map[string]utils.Milestone,
+	next utils.Milestone,
+	baseURL string,
+	token string,
+	project string,
+	comment string,
+	label string,
+) ([]utils.RolledOverItem, error) {
+	var keys []string
+	for k := range milestones {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := []utils.RolledOverItem{}
+	for _, key := range keys {
+		m := milestones[key]
+		if m.ID == next.ID {
+			continue
+		}
+		for _, kind := range []string{"issues", "merge_requests"} {
+			openItems, err := getOpenItems(ctx, baseURL, token, project, kind, m.Title)
+			if err != nil {
+				return items, err
+			}
+			for _, item := range openItems {
+				err = moveItem(ctx, baseURL, token, project, kind, item.Iid, next.ID, comment, label)
+				if err != nil {
+					return items, err
+				}
+				items = append(items, utils.RolledOverItem{
+					Kind:   strings.TrimSuffix(kind, "s"),
+					Number: item.Iid,
+					Title:  item.Title,
+					From:   m.Title,
+					To:     next.Title,
+				})
 			}
 		}
 	}
-	if len(newMilestones) == 0 {
-		logger.Println("No milestone creation needed")
-	} else {
-		logger.Println("New milestones:")
-		var keys []string
-		for k := range newMilestones {
-			keys = append(keys, k)
+
+	return items, nil
+}
+
+// GetExpiredMilestones gets active milestones following the naming scheme titleFormat whose due date passed more than grace days ago.
+// Milestones with open issues are only returned if allowOpenIssues is set.
+func GetExpiredMilestones(ctx context.Context, baseURL string, token string, projectID string, titleFormat string, grace int, allowOpenIssues bool) (map[string]utils.Milestone, error) {
+	activeMilestonesAPI, err := getActiveMilestones(ctx, baseURL, token, projectID)
+	if err != nil {
+		return nil, err
+	}
+	activeMilestones := createGitlabMilestoneMap(activeMilestonesAPI)
+
+	now := time.Now()
+	expiredMilestones := map[string]utils.Milestone{}
+	for k, v := range activeMilestones {
+		if utils.IsManaged(v.Description) && utils.IsGeneratedTitle(v.Title, titleFormat) && utils.DueDatePassed(v.DueDate, grace, now) {
+			expiredMilestones[k] = v
 		}
-		sort.Strings(keys)
-		for _, key := range keys {
-			logger.Printf("Title: %s - Due Date: %s", newMilestones[key].Title, newMilestones[key].DueDate)
+	}
+	counted, failed := utils.ForEachMilestone(ctx, expiredMilestones, func(v utils.Milestone) (utils.Milestone, error) {
+		var err error
+		v.OpenIssues, err = getOpenIssueCount(ctx, baseURL, token, projectID, v.Title)
+		return v, err
+	})
+	if err := failed.Err(); err != nil {
+		return nil, err
+	}
+	milestones := map[string]utils.Milestone{}
+	for k, v := range counted {
+		if v.OpenIssues > 0 && !allowOpenIssues {
+			continue
 		}
-		err = createMilestones(baseURL, token, projectID, newMilestones)
+		milestones[k] = v
+	}
+
+	return milestones, nil
+}
+
+// Get and return the number of issues and merge requests in any state assigned to a milestone
+func getItemCount(ctx context.Context, baseURL string, token string, project string, title string) (int, error) {
+	count := 0
+	for _, kind := range []string{"issues", "merge_requests"} {
+		items, err := getItems(ctx, baseURL, token, project, kind, title, "all")
 		if err != nil {
-			return (err)
+			return 0, err
 		}
+		count += len(items)
 	}
-	return nil
+	return count, nil
 }
 
-// GetClosedMilestones gets closed milestones
-func GetClosedMilestones(baseURL string, token string, projectID string, milestoneData map[string]utils.Milestone) (map[string]utils.Milestone, error) {
-	closedMilestonesAPI, err := getInactiveMilestones(baseURL, token, projectID)
+// getItemCounts counts the issues and merge requests of each milestone, running requests concurrently
+func getItemCounts(ctx context.Context, baseURL string, token string, project string, milestones map[string]utils.Milestone) (map[string]int, error) {
+	var keys []string
+	for k := range milestones {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	results := make([]int, len(keys))
+	failed := utils.ForEach(ctx, keys, func(i int, key string) error {
+		var err error
+		results[i], err = getItemCount(ctx, baseURL, token, project, milestones[key].Title)
+		return err
+	})
+	if err := failed.Err(); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(keys))
+	for i, k := range keys {
+		counts[k] = results[i]
+	}
+	return counts, nil
+}
+
+// GetPrunableMilestones gets milestones following the naming scheme titleFormat without any issues or merge requests
+// whose due date passed more than retention days ago
+func GetPrunableMilestones(ctx context.Context, baseURL string, token string, projectID string, titleFormat string, retention int) (map[string]utils.Milestone, error) {
+	milestonesAPI, err := getMilestones(ctx, baseURL, token, projectID, "")
 	if err != nil {
 		return nil, err
 	}
-	closedGitlabMilestones := createGitlabMilestoneMap(closedMilestonesAPI)
+	allMilestones := createGitlabMilestoneMap(milestonesAPI)
 
-	// copy map of closed milestones
+	now := time.Now()
+	retainedMilestones := map[string]utils.Milestone{}
+	for k, v := range allMilestones {
+		if utils.IsManaged(v.Description) && utils.IsGeneratedTitle(v.Title, titleFormat) && utils.DueDatePassed(v.DueDate, retention, now) {
+			retainedMilestones[k] = v
+		}
+	}
+	counts, err := getItemCounts(ctx, baseURL, token, projectID, retainedMilestones)
+	if err != nil {
+		return nil, err
+	}
 	milestones := map[string]utils.Milestone{}
+	for k, v := range retainedMilestones {
+		if counts[k] > 0 {
+			continue
+		}
+		milestones[k] = v
+	}
+
+	return milestones, nil
+}
+
+// DeleteMilestones deletes milestones
+func DeleteMilestones(
+	ctx context.Context,
+	milestones map[string]utils.Milestone,
+	baseURL string,
+	token string,
+	project string,
+) (map[string]utils.Milestone, error) {
+	deletedMilestones, failed := utils.ForEachMilestone(ctx, milestones, func(v utils.Milestone) (utils.Milestone, error) {
+		strURL := []string{baseURL, "/projects/", project, "/milestones/", v.ID}
+		URL := strings.Join(strURL, "")
+		return v, sendRequest(ctx, "DELETE", URL, token, nil, nil)
+	})
+
+	return deletedMilestones, failed.Err()
+}
+
+// GetDriftedMilestones compares existing milestones of milestoneData on due date, start date and description and returns the differences
+func GetDriftedMilestones(ctx context.Context, baseURL string, token string, projectID string, milestoneData map[string]utils.Milestone) ([]utils.Drift, error) {
+	milestonesAPI, err := getMilestones(ctx, baseURL, token, projectID, "")
+	if err != nil {
+		return nil, err
+	}
+	existingMilestones := createGitlabMilestoneMap(milestonesAPI)
+
+	var keys []string
 	for k := range milestoneData {
-		for ek, ev := range closedGitlabMilestones {
-			if k == ek {
-				milestones[ek] = ev
-			}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	drifts := []utils.Drift{}
+	for _, key := range keys {
+		existing, ok := existingMilestones[key]
+		if !ok || !utils.IsManaged(existing.Description) {
+			continue
+		}
+		fields := utils.CompareMilestones(existing, milestoneData[key], true)
+		if len(fields) == 0 {
+			continue
+		}
+		drifts = append(drifts, utils.Drift{Existing: existing, Desired: milestoneData[key], Fields: fields})
+	}
+
+	return drifts, nil
+}
+
+// UpdateDriftedMilestones updates drifted milestones to their desired due date, start date and description.
+// Milestones that could not be updated are returned as MilestoneErrors by title.
+func UpdateDriftedMilestones(ctx context.Context, drifts []utils.Drift, baseURL string, token string, project string) error {
+	byTitle := map[string]utils.Drift{}
+	var titles []string
+	for _, d := range drifts {
+		byTitle[d.Existing.Title] = d
+		titles = append(titles, d.Existing.Title)
+	}
+	failed := utils.ForEach(ctx, titles, func(i int, title string) error {
+		d := byTitle[title]
+		strURL := []string{baseURL, "/projects/", project, "/milestones/", d.Existing.ID}
+		URL := strings.Join(strURL, "")
+		params := url.Values{}
+		params.Set("due_date", d.Desired.DueDate)
+		params.Set("start_date", d.Desired.StartDate)
+		if d.Desired.Description != "" {
+			params.Set("description", utils.AddMarker(d.Desired.Description))
+		}
+		return sendRequest(ctx, "PUT", URL, token, params, nil)
+	})
+	return failed.Err()
+}
+
+// GetAllMilestones gets active and closed milestones
+func GetAllMilestones(ctx context.Context, baseURL string, token string, projectID string) (map[string]utils.Milestone, error) {
+	milestonesAPI, err := getMilestones(ctx, baseURL, token, projectID, "")
+	if err != nil {
+		return nil, err
+	}
+	return createGitlabMilestoneMap(milestonesAPI), nil
+}
+
+// RenameMilestones renames milestones in place, keeping their issues and merge requests.
+// Renames are sent concurrently, the renamed milestones are returned ordered by their old title.
+// Renames that failed or were not started before ctx was done are returned as MilestoneErrors by old title.
+func RenameMilestones(ctx context.Context, renames []utils.Rename, baseURL string, token string, project string) ([]utils.Rename, error) {
+	byTitle := map[string]utils.Rename{}
+	var titles []string
+	for _, r := range renames {
+		byTitle[r.Milestone.Title] = r
+		titles = append(titles, r.Milestone.Title)
+	}
+	failed := utils.ForEach(ctx, titles, func(i int, title string) error {
+		r := byTitle[title]
+		strURL := []string{baseURL, "/projects/", project, "/milestones/", r.Milestone.ID}
+		URL := strings.Join(strURL, "")
+		params := url.Values{}
+		params.Set("title", r.Title)
+		return sendRequest(ctx, "PUT", URL, token, params, nil)
+	})
+
+	sort.Strings(titles)
+	renamed := []utils.Rename{}
+	for _, title := range titles {
+		if _, ok := failed[title]; !ok {
+			renamed = append(renamed, byTitle[title])
+		}
+	}
+	return renamed, failed.Err()
+}
+
+// GetAdoptableMilestones gets milestones following the naming scheme titleFormat that are not managed by gomiler yet
+func GetAdoptableMilestones(ctx context.Context, baseURL string, token string, projectID string, titleFormat string) (map[string]utils.Milestone, error) {
+	allMilestones, err := GetAllMilestones(ctx, baseURL, token, projectID)
+	if err != nil {
+		return nil, err
+	}
+	milestones := map[string]utils.Milestone{}
+	for k, v := range allMilestones {
+		if utils.IsManaged(v.Description) || !utils.IsGeneratedTitle(v.Title, titleFormat) {
+			continue
 		}
+		milestones[k] = v
 	}
+
 	return milestones, nil
 }
+
+// AdoptMilestones marks milestones as managed by gomiler.
+// Milestones that could not be adopted are returned as MilestoneErrors by title.
+func AdoptMilestones(ctx context.Context, milestones map[string]utils.Milestone, baseURL string, token string, project string) (map[string]utils.Milestone, error) {
+	adoptedMilestones, failed := utils.ForEachMilestone(ctx, milestones, func(v utils.Milestone) (utils.Milestone, error) {
+		strURL := []string{baseURL, "/projects/", project, "/milestones/", v.ID}
+		URL := strings.Join(strURL, "")
+		params := url.Values{}
+		params.Set("description", utils.AddMarker(v.Description))
+		v.Description = params.Get("description")
+		return v, sendRequest(ctx, "PUT", URL, token, params, nil)
+	})
+
+	return adoptedMilestones, failed.Err()
+}
+
+// GetMilestoneIssues gets the issues of a milestone
+func GetMilestoneIssues(ctx context.Context, baseURL string, token string, project string, m utils.Milestone) ([]utils.Issue, error) {
+	items, err := getItems(ctx, baseURL, token, project, "issues", m.Title, "all")
+	if err != nil {
+		return nil, err
+	}
+	issues := []utils.Issue{}
+	for _, v := range items {
+		issues = append(issues, v.issue("issue"))
+	}
+
+	return issues, nil
+}
+
+// GetReleaseItems gets the closed issues and merged merge requests of a milestone
+func GetReleaseItems(ctx context.Context, baseURL string, token string, project string, m utils.Milestone) ([]utils.Issue, error) {
+	issues, err := getItems(ctx, baseURL, token, project, "issues", m.Title, "closed")
+	if err != nil {
+		return nil, err
+	}
+	mergeRequests, err := getItems(ctx, baseURL, token, project, "merge_requests", m.Title, "merged")
+	if err != nil {
+		return nil, err
+	}
+	items := []utils.Issue{}
+	for _, v := range issues {
+		items = append(items, v.issue("issue"))
+	}
+	for _, v := range mergeRequests {
+		items = append(items, v.issue("merge_request"))
+	}
+
+	return items, nil
+}
+
+type gitlabRelease struct {
+	TagName string `json:"tag_name"`
+	Links   struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+}
+
+// PublishRelease creates the release of tag or updates its name and notes if it exists.
+// A new tag is created from ref if it does not exist yet. The URL of the release is returned.
+func PublishRelease(ctx context.Context, baseURL string, token string, project string, tag string, ref string, name string, notes string) (string, error) {
+	strURL := []string{baseURL, "/projects/", project, "/releases"}
+	URL := strings.Join(strURL, "")
+	apiData, err := utils.Paginate(ctx, URL, "gitlab", token)
+	if err != nil {
+		return "", err
+	}
+	releases := []gitlabRelease{}
+	for _, v := range apiData {
+		tmpR := []gitlabRelease{}
+		if err := json.Unmarshal(v, &tmpR); err != nil {
+			return "", err
+		}
+		releases = append(releases, tmpR...)
+	}
+	params := url.Values{}
+	params.Set("name", name)
+	params.Set("description", notes)
+	var published gitlabRelease
+	for _, r := range releases {
+		if r.TagName == tag {
+			err = sendRequest(ctx, "PUT", URL+"/"+url.PathEscape(tag), token, params, &published)
+			return published.Links.Self, err
+		}
+	}
+	params.Set("tag_name", tag)
+	if ref != "" {
+		params.Set("ref", ref)
+	}
+	err = sendRequest(ctx, "POST", URL, token, params, &published)
+	return published.Links.Self, err
+}
+
+type gitlabProject struct {
+	ID                int      `json:"id"`
+	Path              string   `json:"path"`
+	PathWithNamespace string   `json:"path_with_namespace"`
+	Archived          bool     `json:"archived"`
+	Topics            []string `json:"topics"`
+	TagList           []string `json:"tag_list"`
+	NameSpace         struct {
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+}
+
+// ListProjects lists the projects of a group including its subgroups or, if there is no group of that path, of a user
+func ListProjects(ctx context.Context, baseURL string, token string, namespace string) ([]utils.Repository, error) {
+	repositories, err := listProjects(ctx, baseURL+"/groups/"+url.PathEscape(namespace)+"/projects?include_subgroups=true&per_page=100", token, namespace)
+	if errors.Is(err, utils.ErrNotFound) {
+		repositories, err = listProjects(ctx, baseURL+"/users/"+url.PathEscape(namespace)+"/projects?per_page=100", token, namespace)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not list projects of %s: %w", namespace, err)
+	}
+	return repositories, nil
+}
+
+func listProjects(ctx context.Context, URL string, token string, namespace string) ([]utils.Repository, error) {
+	apiData, err := utils.Paginate(ctx, URL, "gitlab", token)
+	if err != nil {
+		return nil, err
+	}
+	repositories := []utils.Repository{}
+	for _, v := range apiData {
+		tmpP := []gitlabProject{}
+		if err := json.Unmarshal(v, &tmpP); err != nil {
+			return nil, err
+		}
+		for _, p := range tmpP {
+			topics := p.Topics
+			if len(topics) == 0 {
+				topics = p.TagList
+			}
+			repositories = append(repositories, utils.Repository{
+				Path:      strings.TrimPrefix(p.PathWithNamespace, namespace+"/"),
+				Namespace: p.NameSpace.FullPath,
+				Name:      p.Path,
+				ID:        strconv.Itoa(p.ID),
+				Archived:  p.Archived,
+				Topics:    topics,
+			})
+		}
+	}
+	return repositories, nil
+}
@@ -15,19 +15,17 @@ limitations under the License.
 package gitlab
 
 import (
+	"context"
 	"encoding/json"
-	"log"
-	"os"
+	"errors"
+	"net/http"
 	"testing"
+	"time"
 
 	"go.okkur.org/gomiler/utils"
 	httpmock "gopkg.in/jarcoal/httpmock.v1"
 )
 
-var (
-	logger = log.New(os.Stderr, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-)
-
 func TestGetProjectID(t *testing.T) {
 	httpmock.Activate()
 	defer httpmock.DeactivateAndReset()
@@ -45,7 +43,7 @@ func TestGetProjectID(t *testing.T) {
 
 	httpmock.RegisterResponder("GET", "https://gitlab.com/api/v4/projects/",
 		httpmock.NewStringResponder(200, string(jsonStruct)))
-	res, err := GetProjectID(mockURL, "213123", "test", "test")
+	res, err := GetProjectID(context.Background(), mockURL, "213123", "test", "test")
 
 	if res != "1" && err != nil {
 		t.Errorf("Expected %s, got %s", "1", res)
@@ -58,13 +56,13 @@ func TestGetProjectIDwithNonexistentProject(t *testing.T) {
 	mockURL := "https://" + "gitlab.com" + "/api/v4"
 	httpmock.RegisterResponder("GET", "https://gitlab.com/api/v4/projects/",
 		httpmock.NewStringResponder(404, ""))
-	_, err := GetProjectID(mockURL, "213123", "test", "test")
+	_, err := GetProjectID(context.Background(), mockURL, "213123", "test", "test")
 	if err == nil {
 		t.Errorf("Expected to get an error when project does not exist")
 	}
 }
 
-func TestGitlabCreateAndDisplayNewMilestones(t *testing.T) {
+func TestCreateMilestones(t *testing.T) {
 	milestoneData, err := utils.CreateMilestoneData(10, "daily", nil, "gitlab")
 	if err != nil {
 		t.Error(err)
@@ -74,9 +72,17 @@ func TestGitlabCreateAndDisplayNewMilestones(t *testing.T) {
 	mockURL := "https://" + "gitlab.com" + "/api/v4"
 	MockGitlabAPIGetRequest(mockURL, "active")
 	MockGitlabAPIPostRequest(mockURL, "active")
-	err = CreateAndDisplayNewMilestones(mockURL, "213123", "1", milestoneData, logger)
-	if err != nil {
-		t.Error(err)
+	created, failed := CreateMilestones(context.Background(), mockURL, "213123", "1", milestoneData)
+	if len(failed) != 0 {
+		t.Errorf("Expected no failed milestones, got %v", failed)
+	}
+	if len(created) == 0 {
+		t.Error("Expected created milestones")
+	}
+	for k, m := range created {
+		if m.Title != k || m.DueDate != milestoneData[k].DueDate || m.URL != "https://gitlab.com/namespace/project/-/milestones/1" {
+			t.Errorf("Unexpected created milestone %v", m)
+		}
 	}
 }
 
@@ -85,7 +91,7 @@ func TestGetActiveMilestones(t *testing.T) {
 	defer httpmock.DeactivateAndReset()
 	mockURL := "https://" + "gitlab.com" + "/api/v4"
 	MockGitlabAPIGetRequest(mockURL, "active")
-	activeMilestonesAPI, err := getActiveMilestones(mockURL, "token", "1")
+	activeMilestonesAPI, err := getActiveMilestones(context.Background(), mockURL, "token", "1")
 	if err != nil {
 		t.Error(err)
 	}
@@ -101,7 +107,7 @@ func TestGetInactiveMilestones(t *testing.T) {
 	defer httpmock.DeactivateAndReset()
 	mockURL := "https://" + "gitlab.com" + "/api/v4"
 	MockGitlabAPIGetRequest(mockURL, "closed")
-	inactiveMilestonesAPI, err := getInactiveMilestones(mockURL, "token", "1")
+	inactiveMilestonesAPI, err := getInactiveMilestones(context.Background(), mockURL, "token", "1")
 	if err != nil {
 		t.Error(err)
 	}
@@ -117,7 +123,7 @@ func TestReactivateClosedMilestones(t *testing.T) {
 	defer httpmock.DeactivateAndReset()
 	mockURL := "https://" + "gitlab.com" + "/api/v4"
 	MockGitlabAPIGetRequest(mockURL, "closed")
-	inactiveMilestonesAPI, err := getInactiveMilestones(mockURL, "token", "1")
+	inactiveMilestonesAPI, err := getInactiveMilestones(context.Background(), mockURL, "token", "1")
 	if err != nil {
 		t.Error(err)
 	}
@@ -125,7 +131,7 @@ func TestReactivateClosedMilestones(t *testing.T) {
 	for _, v := range inactiveMilestones {
 		MockGitlabAPIPutRequest(mockURL, "active", v.ID)
 	}
-	reactivatedMilestones, err := ReactivateClosedMilestones(inactiveMilestones, mockURL, "token", "1", logger)
+	reactivatedMilestones, _, err := ReactivateClosedMilestones(context.Background(), inactiveMilestones, mockURL, "token", "1", "always")
 	if err != nil {
 		t.Error(err)
 	}
@@ -135,3 +141,438 @@ func TestReactivateClosedMilestones(t *testing.T) {
 		}
 	}
 }
+
+func TestGetExpiredMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIExpiredGetRequest(mockURL)
+	expiredMilestones, err := GetExpiredMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 0, false)
+	if err != nil {
+		t.Error(err)
+	}
+	yesterday := time.Now().Local().AddDate(0, 0, -1).Format("2006-01-02")
+	if len(expiredMilestones) != 1 {
+		t.Errorf("Expected %d, got %d", 1, len(expiredMilestones))
+	}
+	if _, ok := expiredMilestones[yesterday]; !ok {
+		t.Errorf("Expected milestone %s to be expired", yesterday)
+	}
+	expiredMilestones, err = GetExpiredMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 0, true)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(expiredMilestones) != 2 {
+		t.Errorf("Expected %d, got %d", 2, len(expiredMilestones))
+	}
+}
+
+func TestCloseExpiredMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIExpiredGetRequest(mockURL)
+	expiredMilestones, err := GetExpiredMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 0, true)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, v := range expiredMilestones {
+		MockGitlabAPIPutRequest(mockURL, "closed", v.ID)
+	}
+	closedMilestones, err := CloseExpiredMilestones(context.Background(), expiredMilestones, mockURL, "token", "1")
+	if err != nil {
+		t.Error(err)
+	}
+	for _, v := range closedMilestones {
+		if v.State != "closed" {
+			t.Errorf("Expected %s, got %s", "closed", v.State)
+		}
+	}
+}
+
+func TestGetNextMilestone(t *testing.T) {
+	milestoneData, err := utils.CreateMilestoneData(10, "daily", nil, "gitlab")
+	if err != nil {
+		t.Error(err)
+	}
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIExpiredGetRequest(mockURL)
+	next, err := GetNextMilestone(context.Background(), mockURL, "token", "1", milestoneData)
+	if err != nil {
+		t.Error(err)
+	}
+	today := time.Now().Local().Format("2006-01-02")
+	if next.Title != today {
+		t.Errorf("Expected %s, got %s", today, next.Title)
+	}
+}
+
+func TestGetNextMilestoneSkipsUnmanaged(t *testing.T) {
+	tomorrow := time.Now().Local().AddDate(0, 0, 1).Format("2006-01-02")
+	later := time.Now().Local().AddDate(0, 0, 2).Format("2006-01-02")
+	milestoneData := map[string]utils.Milestone{tomorrow: {Title: tomorrow}, later: {Title: later}}
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	httpmock.RegisterResponder("GET", mockURL+"/projects/1/milestones", func(req *http.Request) (*http.Response, error) {
+		return httpmock.NewJsonResponse(200, []gitlabAPI{
+			// created by hand with a generated title
+			{ID: 1, Title: tomorrow, DueDate: tomorrow, State: "active"},
+			{ID: 2, Title: later, DueDate: later, Description: utils.Marker, State: "active"},
+		})
+	})
+	next, err := GetNextMilestone(context.Background(), mockURL, "token", "1", milestoneData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Title != later {
+		t.Errorf("Expected %s, got %s", later, next.Title)
+	}
+}
+
+func TestRollOverMilestones(t *testing.T) {
+	milestoneData, err := utils.CreateMilestoneData(10, "daily", nil, "gitlab")
+	if err != nil {
+		t.Error(err)
+	}
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIExpiredGetRequest(mockURL)
+	expiredMilestones, err := GetExpiredMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 0, true)
+	if err != nil {
+		t.Error(err)
+	}
+	next, err := GetNextMilestone(context.Background(), mockURL, "token", "1", milestoneData)
+	if err != nil {
+		t.Error(err)
+	}
+	MockGitlabAPIItemsRequest(mockURL)
+	items, err := RollOverMilestones(context.Background(), expiredMilestones, next, mockURL, "token", "1", "Rolled over", "rolled-over")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(items) != 2*len(expiredMilestones) {
+		t.Errorf("Expected %d, got %d", 2*len(expiredMilestones), len(items))
+	}
+	for _, item := range items {
+		if item.To != next.Title {
+			t.Errorf("Expected %s, got %s", next.Title, item.To)
+		}
+	}
+}
+
+func TestGetPrunableMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIExpiredGetRequest(mockURL)
+	prunableMilestones, err := GetPrunableMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 0)
+	if err != nil {
+		t.Error(err)
+	}
+	yesterday := time.Now().Local().AddDate(0, 0, -1).Format("2006-01-02")
+	if len(prunableMilestones) != 1 {
+		t.Errorf("Expected %d, got %d", 1, len(prunableMilestones))
+	}
+	if _, ok := prunableMilestones[yesterday]; !ok {
+		t.Errorf("Expected milestone %s to be prunable", yesterday)
+	}
+	prunableMilestones, err = GetPrunableMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 1)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(prunableMilestones) != 0 {
+		t.Errorf("Expected %d, got %d", 0, len(prunableMilestones))
+	}
+}
+
+func TestDeleteMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIExpiredGetRequest(mockURL)
+	prunableMilestones, err := GetPrunableMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"), 0)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, v := range prunableMilestones {
+		MockGitlabAPIDeleteRequest(mockURL, v.ID)
+	}
+	deletedMilestones, err := DeleteMilestones(context.Background(), prunableMilestones, mockURL, "token", "1")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(deletedMilestones) != len(prunableMilestones) {
+		t.Errorf("Expected %d, got %d", len(prunableMilestones), len(deletedMilestones))
+	}
+}
+
+func TestGetDriftedMilestones(t *testing.T) {
+	milestoneData, err := utils.CreateMilestoneData(10, "daily", nil, "gitlab")
+	if err != nil {
+		t.Error(err)
+	}
+	today := time.Now().Local().Format("2006-01-02")
+	m := milestoneData[today]
+	m.Description = "daily sprint"
+	milestoneData[today] = m
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIExpiredGetRequest(mockURL)
+	drifts, err := GetDriftedMilestones(context.Background(), mockURL, "token", "1", milestoneData)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(drifts) != 1 {
+		t.Fatalf("Expected %d, got %d", 1, len(drifts))
+	}
+	if drifts[0].Existing.Title != today {
+		t.Errorf("Expected %s, got %s", today, drifts[0].Existing.Title)
+	}
+	found := false
+	for _, field := range drifts[0].Fields {
+		if field == "description" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected description drift, got %v", drifts[0].Fields)
+	}
+}
+
+func TestUpdateDriftedMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIPutRequest(mockURL, "open", "1")
+	drifts := []utils.Drift{
+		{
+			Existing: utils.Milestone{ID: "1", Number: 1, Title: "2017-03", DueDate: "2017-03-30"},
+			Desired:  utils.Milestone{Title: "2017-03", DueDate: "2017-03-31"},
+			Fields:   []string{"due date"},
+		},
+	}
+	err := UpdateDriftedMilestones(context.Background(), drifts, mockURL, "token", "1")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRenameMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIPutRequest(mockURL, "open", "1")
+	renames := []utils.Rename{
+		{Milestone: utils.Milestone{ID: "1", Number: 1, Title: "2017-w9"}, Title: "Sprint 2017-9"},
+	}
+	renamed, err := RenameMilestones(context.Background(), renames, mockURL, "token", "1")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(renamed) != len(renames) {
+		t.Errorf("Expected %d, got %d", len(renames), len(renamed))
+	}
+}
+
+func TestRenameMilestonesPartialFailure(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIPutRequest(mockURL, "open", "1")
+	httpmock.RegisterResponder("PUT", mockURL+"/projects/1/milestones/2", httpmock.NewStringResponder(404, `{"message": "404 Not found"}`))
+	renames := []utils.Rename{
+		{Milestone: utils.Milestone{ID: "1", Number: 1, Title: "2017-w9"}, Title: "Sprint 2017-9"},
+		{Milestone: utils.Milestone{ID: "2", Number: 2, Title: "2017-w10"}, Title: "Sprint 2017-10"},
+	}
+	renamed, err := RenameMilestones(context.Background(), renames, mockURL, "token", "1")
+	failed, ok := err.(utils.MilestoneErrors)
+	if !ok || len(failed) != 1 || failed["2017-w10"] == nil || !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("Expected the error of the failed rename by old title, got %v", err)
+	}
+	if len(renamed) != 1 || renamed[0].Milestone.Title != "2017-w9" {
+		t.Errorf("Expected the other milestone to be renamed, got %v", renamed)
+	}
+}
+
+func TestReactivateClosedMilestonesWithPolicy(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	yesterday := time.Now().Local().AddDate(0, 0, -1).Format("2006-01-02")
+	tomorrow := time.Now().Local().AddDate(0, 0, 1).Format("2006-01-02")
+	inactiveMilestones := map[string]utils.Milestone{
+		yesterday: {ID: "1", Title: yesterday, DueDate: yesterday, State: "closed"},
+		tomorrow:  {ID: "2", Title: tomorrow, DueDate: tomorrow, State: "closed"},
+	}
+	MockGitlabAPIPutRequest(mockURL, "active", "2")
+	reactivatedMilestones, skippedMilestones, err := ReactivateClosedMilestones(context.Background(), inactiveMilestones, mockURL, "token", "1", "only-if-future-due")
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := reactivatedMilestones[tomorrow]; !ok || len(reactivatedMilestones) != 1 {
+		t.Errorf("Expected only %s to be reactivated, got %v", tomorrow, reactivatedMilestones)
+	}
+	if _, ok := skippedMilestones[yesterday]; !ok {
+		t.Errorf("Expected %s to be skipped", yesterday)
+	}
+}
+
+func TestReactivateClosedMilestonesFailsOnErrorStatus(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	inactiveMilestones := map[string]utils.Milestone{
+		"2017-03-04": {ID: "1", Title: "2017-03-04", State: "closed"},
+	}
+	httpmock.RegisterResponder("PUT", mockURL+"/projects/1/milestones/1",
+		httpmock.NewStringResponder(403, `{"message":"403 Forbidden"}`))
+	reactivatedMilestones, _, err := ReactivateClosedMilestones(context.Background(), inactiveMilestones, mockURL, "token", "1", "always")
+	if err == nil {
+		t.Errorf("Expected to get an error when the API rejects the update")
+	}
+	if len(reactivatedMilestones) != 0 {
+		t.Errorf("Expected %d, got %d", 0, len(reactivatedMilestones))
+	}
+}
+
+func TestAdoptMilestones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIExpiredGetRequest(mockURL)
+	adoptableMilestones, err := GetAdoptableMilestones(context.Background(), mockURL, "token", "1", utils.DefaultTitleFormat("daily"))
+	if err != nil {
+		t.Error(err)
+	}
+	unmanaged := time.Now().Local().AddDate(0, 0, -4).Format("2006-01-02")
+	if _, ok := adoptableMilestones[unmanaged]; !ok || len(adoptableMilestones) != 1 {
+		t.Errorf("Expected only %s to be adoptable, got %v", unmanaged, adoptableMilestones)
+	}
+	for _, v := range adoptableMilestones {
+		MockGitlabAPIPutRequest(mockURL, "open", v.ID)
+	}
+	adoptedMilestones, err := AdoptMilestones(context.Background(), adoptableMilestones, mockURL, "token", "1")
+	if err != nil {
+		t.Error(err)
+	}
+	for _, v := range adoptedMilestones {
+		if !utils.IsManaged(v.Description) {
+			t.Errorf("Expected %s to be managed", v.Title)
+		}
+	}
+}
+
+func TestAdoptMilestonesPartialFailure(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIPutRequest(mockURL, "open", "1")
+	httpmock.RegisterResponder("PUT", mockURL+"/projects/1/milestones/2", httpmock.NewStringResponder(404, `{"message": "404 Not found"}`))
+	milestones := map[string]utils.Milestone{
+		"2018-01-01": {Title: "2018-01-01", ID: "1"},
+		"2018-01-02": {Title: "2018-01-02", ID: "2"},
+	}
+	adoptedMilestones, err := AdoptMilestones(context.Background(), milestones, mockURL, "token", "1")
+	failed, ok := err.(utils.MilestoneErrors)
+	if !ok || len(failed) != 1 || failed["2018-01-02"] == nil || !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("Expected the error of the failed milestone, got %v", err)
+	}
+	if _, ok := adoptedMilestones["2018-01-01"]; !ok || len(adoptedMilestones) != 1 {
+		t.Errorf("Expected the other milestone to be adopted, got %v", adoptedMilestones)
+	}
+}
+
+func TestGetMilestoneIssues(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIItemsRequest(mockURL)
+	issues, err := GetMilestoneIssues(context.Background(), mockURL, "token", "1", utils.Milestone{ID: "1", Title: "2017-w9"})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(issues) != 1 {
+		t.Errorf("Expected %d, got %d", 1, len(issues))
+	}
+}
+
+func TestGetReleaseItems(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIClosedItemsRequest(mockURL)
+	items, err := GetReleaseItems(context.Background(), mockURL, "token", "1", utils.Milestone{ID: "1", Title: "2017-w9"})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected %d, got %d", 2, len(items))
+	}
+	if items[0].Kind != "issue" || items[0].Reference() != "#1" || items[0].URL == "" {
+		t.Errorf("Unexpected issue %v", items[0])
+	}
+	if items[1].Kind != "merge_request" || items[1].Reference() != "!2" || items[1].Author != "tanuki" {
+		t.Errorf("Unexpected merge request %v", items[1])
+	}
+}
+
+func TestPublishRelease(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIReleaseRequest(mockURL)
+	cases := map[string]string{
+		"new":      "https://gitlab.com/namespace/project/-/releases/new",
+		"existing": "https://gitlab.com/namespace/project/-/releases/existing",
+	}
+	for tag, expected := range cases {
+		releaseURL, err := PublishRelease(context.Background(), mockURL, "token", "1", tag, "master", tag, "notes")
+		if err != nil {
+			t.Error(err)
+		}
+		if releaseURL != expected {
+			t.Errorf("Expected %s, got %s", expected, releaseURL)
+		}
+	}
+}
+
+func TestListProjects(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	MockGitlabAPIProjectsRequest(mockURL, "group")
+	repositories, err := ListProjects(context.Background(), mockURL, "token", "group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repositories) != 3 {
+		t.Fatalf("Expected %d, got %d", 3, len(repositories))
+	}
+	r := repositories[1]
+	if r.Path != "sub/lib" || r.ID != "2" || r.Namespace != "group/sub" || r.Name != "lib" || r.Topics[0] != "gomiler" {
+		t.Errorf("Unexpected project %v", r)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "gitlab.com" + "/api/v4"
+	httpmock.RegisterResponder("GET", mockURL+"/projects/", httpmock.NewStringResponder(200, `[]`))
+	httpmock.RegisterResponder("PUT", mockURL+"/projects/1/milestones/1", httpmock.NewStringResponder(404, `{"message": "404 Not found"}`))
+
+	_, err := GetProjectID(context.Background(), mockURL, "token", "app", "team")
+	if !errors.Is(err, utils.ErrNotFound) || err.Error() != "project app not found" {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+	_, err = CloseExpiredMilestones(context.Background(), map[string]utils.Milestone{"2018-01-01": {Title: "2018-01-01", ID: "1"}}, mockURL, "token", "1")
+	var apiErr *utils.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 404 || !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("Expected a not found API error, got %v", err)
+	}
+}
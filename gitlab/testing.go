@@ -20,6 +20,7 @@ import (
 	"strings"
 	"time"
 
+	"go.okkur.org/gomiler/utils"
 	httpmock "gopkg.in/jarcoal/httpmock.v1"
 )
 
@@ -55,6 +56,69 @@ func MockGitlabAPI(state string) []gitlabAPI {
 	return gitlabAPImock
 }
 
+// MockGitlabAPIExpired populates a []gitlabAPI with active daily milestones that are past due, due today, not generated or not managed by gomiler
+func MockGitlabAPIExpired() []gitlabAPI {
+	now := time.Now().Local()
+	gitlabAPImock := []gitlabAPI{}
+	for i := 0; i < 5; i++ {
+		mock := gitlabAPI{}
+		date := now.AddDate(0, 0, -i)
+		mock.ID = i
+		mock.Iid = i
+		mock.ProjectID = 1
+		mock.Title = date.Format("2006-01-02")
+		mock.DueDate = date.Format("2006-01-02")
+		mock.Description = utils.Marker
+		mock.State = "active"
+		gitlabAPImock = append(gitlabAPImock, mock)
+	}
+	// milestone not following the naming scheme
+	gitlabAPImock[2].Title = "Release " + gitlabAPImock[2].Title
+	// milestone not managed by gomiler
+	gitlabAPImock[4].Description = ""
+
+	return gitlabAPImock
+}
+
+// MockGitlabAPIExpiredGetRequest creates a mock responder for the milestone endpoint and sends back expired milestones.
+// The milestone due three days ago has open issues and merge requests.
+func MockGitlabAPIExpiredGetRequest(URL string) {
+	json := MockGitlabAPIExpired()
+	var strURL []string
+	strURL = []string{URL, "/projects/", "1", "/milestones"}
+	newURL := strings.Join(strURL, "")
+	httpmock.RegisterResponder("GET", newURL,
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(200, json)
+			if err != nil {
+				return httpmock.NewStringResponse(500, ""), nil
+			}
+			return resp, nil
+		},
+	)
+	withIssues := json[3].Title
+	for _, kind := range []string{"issues", "merge_requests"} {
+		strURL = []string{URL, "/projects/", "1", "/", kind}
+		newURL = strings.Join(strURL, "")
+		httpmock.RegisterResponder("GET", newURL,
+			func(req *http.Request) (*http.Response, error) {
+				if req.URL.Query().Get("milestone") == withIssues {
+					return httpmock.NewStringResponse(200, `[{"id":1},{"id":2}]`), nil
+				}
+				return httpmock.NewStringResponse(200, "[]"), nil
+			},
+		)
+	}
+}
+
+// MockGitlabAPIDeleteRequest creates a mock responder for deleting a specific milestone
+func MockGitlabAPIDeleteRequest(URL string, id string) {
+	var strURL []string
+	strURL = []string{URL, "/projects/", "1", "/milestones/", id}
+	newURL := strings.Join(strURL, "")
+	httpmock.RegisterResponder("DELETE", newURL, httpmock.NewStringResponder(204, ""))
+}
+
 // MockGitlabAPIGetRequest creates a mock responder for a specific milestone endpoint and sends back mock JSON data
 func MockGitlabAPIGetRequest(URL string, state string) {
 	json := MockGitlabAPI(state)
@@ -72,15 +136,27 @@ func MockGitlabAPIGetRequest(URL string, state string) {
 	)
 }
 
-// MockGitlabAPIPostRequest creates a mock responder for a specific milestone endpoint and sends back mock JSON data
+// MockGitlabAPIPostRequest creates a mock responder for a specific milestone endpoint and sends back the created milestone
 func MockGitlabAPIPostRequest(URL string, state string) {
-	json := MockGitlabAPI(state)
 	var strURL []string
 	strURL = []string{URL, "/projects/", "1", "/milestones"}
 	newURL := strings.Join(strURL, "")
 	httpmock.RegisterResponder("POST", newURL,
 		func(req *http.Request) (*http.Response, error) {
-			resp, err := httpmock.NewJsonResponse(200, json)
+			err := req.ParseForm()
+			if err != nil {
+				return httpmock.NewStringResponse(400, ""), nil
+			}
+			created := gitlabAPI{
+				ID:          1,
+				Title:       req.PostForm.Get("title"),
+				DueDate:     req.PostForm.Get("due_date"),
+				StartDate:   req.PostForm.Get("start_date"),
+				Description: req.PostForm.Get("description"),
+				State:       state,
+				WebURL:      "https://gitlab.com/namespace/project/-/milestones/1",
+			}
+			resp, err := httpmock.NewJsonResponse(201, created)
 			if err != nil {
 				return httpmock.NewStringResponse(500, ""), nil
 			}
@@ -105,3 +181,73 @@ func MockGitlabAPIPutRequest(URL string, state string, id string) {
 		},
 	)
 }
+
+// MockGitlabAPIItemsRequest creates mock responders for listing, updating and commenting on an issue and a merge request
+func MockGitlabAPIItemsRequest(URL string) {
+	json := []gitlabItem{
+		{ID: 10, Iid: 1, Title: "item", State: "opened"},
+	}
+	for _, kind := range []string{"issues", "merge_requests"} {
+		var strURL []string
+		strURL = []string{URL, "/projects/", "1", "/", kind}
+		newURL := strings.Join(strURL, "")
+		httpmock.RegisterResponder("GET", newURL,
+			func(req *http.Request) (*http.Response, error) {
+				resp, err := httpmock.NewJsonResponse(200, json)
+				if err != nil {
+					return httpmock.NewStringResponse(500, ""), nil
+				}
+				return resp, nil
+			},
+		)
+		itemURL := newURL + "/" + strconv.Itoa(json[0].Iid)
+		httpmock.RegisterResponder("PUT", itemURL, httpmock.NewStringResponder(200, "{}"))
+		httpmock.RegisterResponder("POST", itemURL+"/notes", httpmock.NewStringResponder(201, "{}"))
+	}
+}
+
+// MockGitlabAPIClosedItemsRequest creates mock responders for the closed issues and merged merge requests of a milestone
+func MockGitlabAPIClosedItemsRequest(URL string) {
+	issues := []gitlabItem{
+		{ID: 10, Iid: 1, Title: "fixed bug", State: "closed", Labels: []string{"bug"}, WebURL: "https://gitlab.com/namespace/project/-/issues/1"},
+	}
+	mergeRequests := []gitlabItem{
+		{ID: 20, Iid: 2, Title: "merged merge request", State: "merged", Labels: []string{"feature"}},
+	}
+	mergeRequests[0].Author.Username = "tanuki"
+	for kind, json := range map[string][]gitlabItem{"issues": issues, "merge_requests": mergeRequests} {
+		var strURL []string
+		strURL = []string{URL, "/projects/", "1", "/", kind}
+		newURL := strings.Join(strURL, "")
+		items := json
+		httpmock.RegisterResponder("GET", newURL,
+			func(req *http.Request) (*http.Response, error) {
+				resp, err := httpmock.NewJsonResponse(200, items)
+				if err != nil {
+					return httpmock.NewStringResponse(500, ""), nil
+				}
+				return resp, nil
+			},
+		)
+	}
+}
+
+// MockGitlabAPIReleaseRequest creates mock responders for listing, creating and updating releases.
+// A release with tag "existing" exists already.
+func MockGitlabAPIReleaseRequest(URL string) {
+	var strURL []string
+	strURL = []string{URL, "/projects/", "1", "/releases"}
+	newURL := strings.Join(strURL, "")
+	httpmock.RegisterResponder("GET", newURL, httpmock.NewStringResponder(200, `[{"tag_name": "existing"}]`))
+	httpmock.RegisterResponder("POST", newURL, httpmock.NewStringResponder(201, `{"tag_name": "new", "_links": {"self": "https://gitlab.com/namespace/project/-/releases/new"}}`))
+	httpmock.RegisterResponder("PUT", newURL+"/existing", httpmock.NewStringResponder(200, `{"tag_name": "existing", "_links": {"self": "https://gitlab.com/namespace/project/-/releases/existing"}}`))
+}
+
+// MockGitlabAPIProjectsRequest creates a mock responder listing the projects of a group and its subgroups
+func MockGitlabAPIProjectsRequest(URL string, group string) {
+	httpmock.RegisterResponder("GET", URL+"/groups/"+group+"/projects", httpmock.NewStringResponder(200, `[
+		{"id": 1, "path": "app", "path_with_namespace": "`+group+`/app", "archived": false, "topics": ["gomiler"], "namespace": {"full_path": "`+group+`"}},
+		{"id": 2, "path": "lib", "path_with_namespace": "`+group+`/sub/lib", "archived": false, "tag_list": ["gomiler"], "namespace": {"full_path": "`+group+`/sub"}},
+		{"id": 3, "path": "old", "path_with_namespace": "`+group+`/old", "archived": true, "topics": [], "namespace": {"full_path": "`+group+`"}}
+	]`))
+}
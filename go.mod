@@ -5,4 +5,5 @@ go 1.13
 require (
 	github.com/peterhellberg/link v1.1.0
 	gopkg.in/jarcoal/httpmock.v1 v1.0.0-20170412085702-cf52904a3cf0
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,173 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"flag"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.okkur.org/gomiler/utils"
+)
+
+// icalFeed renders the milestones of a project or its generated schedule as iCalendar
+type icalFeed struct {
+	o options
+	// Project the milestones are read from, resolved from the options if nil
+	t           *target
+	titleFormat string
+	schedule    bool
+	advance     int
+}
+
+// milestones returns the schedule or gets the milestones of the project
+func (f icalFeed) milestones(ctx context.Context) (map[string]utils.Milestone, error) {
+	ctx = f.o.apiContext(ctx)
+	if f.schedule {
+		// date-only due dates, the schedule does not depend on the API
+		return utils.CreateMilestoneDataWithFormat(f.advance, f.o.interval, f.titleFormat, logger, "gitlab")
+	}
+	if f.t != nil {
+		return getAllMilestones(ctx, *f.t, f.o.token)
+	}
+	t, err := connect(ctx, f.o)
+	if err != nil {
+		return nil, err
+	}
+	return getAllMilestones(ctx, t, f.o.token)
+}
+
+// write writes the calendar to buf
+func (f icalFeed) write(ctx context.Context, buf *bytes.Buffer) error {
+	milestones, err := f.milestones(ctx)
+	if err != nil {
+		return err
+	}
+	return utils.WriteICal(buf, f.o.namespace+"/"+f.o.project, milestones, time.Now())
+}
+
+// ServeHTTP serves the calendar, getting the milestones on every request
+func (f icalFeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	err := f.write(r.Context(), &buf)
+	if err != nil {
+		logger.Println(err)
+		http.Error(w, "could not get milestones", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", utils.ICalContentType)
+	w.Write(buf.Bytes())
+}
+
+// calendarSuffix ends the path of the calendar feed of a project below /calendars/
+const calendarSuffix = "/milestones.ics"
+
+// feedAuthorized only passes requests with the calendar token in the token query parameter to next, once the daemon is ready.
+// Calendar apps subscribe to a URL and cannot send an Authorization header.
+func (d *daemon) feedAuthorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(d.calendarToken)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		if !d.isReady() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method != "GET" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// calendar serves the milestones of the project in the path, e.g. /calendars/team/app/milestones.ics, as iCalendar feed
+func (d *daemon) calendar(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/calendars/")
+	if !strings.HasSuffix(name, calendarSuffix) {
+		http.NotFound(w, r)
+		return
+	}
+	ctx := r.Context()
+	p, status, err := d.findRun(ctx, d.configSnapshot(), strings.TrimSuffix(name, calendarSuffix))
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	// Projects listed from a namespace are resolved already, subgroup projects cannot be looked up by name
+	t, err := p.target(ctx)
+	if err != nil {
+		logger.Println(err)
+		http.Error(w, "could not resolve project", apiStatus(err))
+		return
+	}
+	icalFeed{o: p.o, t: &t}.ServeHTTP(w, r)
+}
+
+// runICal exports milestone due dates as iCalendar file or serves them as feed
+func runICal(args []string) {
+	var f icalFeed
+	var file, listen string
+	fs := flag.NewFlagSet("ical", flag.ExitOnError)
+	f.o.registerFlags(fs)
+	fs.BoolVar(&f.schedule, "schedule", false, "Export the generated schedule instead of the milestones of the project")
+	fs.IntVar(&f.advance, "advance", 30, "Define timeframe of the exported schedule")
+	fs.StringVar(&file, "file", "", "Write the calendar to this .ics file instead of stdout")
+	fs.StringVar(&listen, "listen", "", "Serve the calendar as feed on this address, e.g. :8080")
+	fs.Parse(args)
+
+	titleFormat, err := f.o.format()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	f.titleFormat = titleFormat
+	if err := f.o.resolveToken(); err != nil {
+		logger.Fatal(err)
+	}
+
+	if listen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/milestones.ics", f)
+		logger.Printf("Serving calendar on %s/milestones.ics", listen)
+		logger.Fatal(http.ListenAndServe(listen, mux))
+	}
+
+	ctx, cancel := f.o.runContext()
+	defer cancel()
+	var buf bytes.Buffer
+	err = f.write(ctx, &buf)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	out := os.Stdout
+	if file != "" {
+		out, err = os.Create(file)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		defer out.Close()
+	}
+	_, err = buf.WriteTo(out)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
@@ -15,7 +15,7 @@ limitations under the License.
 package main
 
 import (
-	"errors"
+	"context"
 	"flag"
 	"fmt"
 	"io"
@@ -23,7 +23,11 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	github "go.okkur.org/gomiler/github"
 	gitlab "go.okkur.org/gomiler/gitlab"
@@ -38,130 +42,475 @@ func LoggerSetup(info io.Writer) {
 	logger = log.New(info, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
 }
 
-func checkAPI(baseURL string, token string, namespace string, project string) (string, error) {
+func checkAPI(ctx context.Context, baseURL string, token string, namespace string, project string) (string, error) {
 	gitlabURL := baseURL + "/api/v4/version"
 	githubURL := baseURL + "/repos/" + namespace + "/" + project
-	m := map[string]string{
-		"gitlab": gitlabURL,
-		"github": githubURL,
+	if project == "" {
+		githubURL = baseURL + "/users/" + namespace
+	}
+	// Probe in a fixed order, GitLab's version page first
+	probes := []struct{ api, URL string }{
+		{"gitlab", gitlabURL},
+		{"github", githubURL},
 	}
 	var resp *http.Response
-	var client http.Client
-	for k, v := range m {
-		req, err := http.NewRequest("GET", v, nil)
-		if err != nil {
-			return "", err
-		}
-		switch k {
-		case "gitlab":
-			req.Header.Add("PRIVATE-TOKEN", token)
-		case "github":
-			req.Header.Add("Accept", "application/vnd.github.v3+json")
-			req.Header.Add("Authorization", "token "+token)
-		}
-		resp, err = client.Do(req)
+	var err error
+	for _, p := range probes {
+		resp, _, err = utils.DefaultClient.Send(ctx, p.api, "GET", p.URL, utils.APIHeader(p.api, token), nil)
 		if err != nil {
 			return "", err
 		}
-		defer resp.Body.Close()
 		if resp.StatusCode == 200 {
-			return k, nil
+			return p.api, nil
 		}
-		if resp.StatusCode == 403 {
-			return "", errors.New("Provided token is invalid. Access Denied.")
+		if resp.StatusCode == 401 || resp.StatusCode == 403 {
+			return "", fmt.Errorf("Provided token is invalid. Access Denied: %w", utils.ErrUnauthorized)
 		}
 	}
 	// Check for 404 error returned from GitHub API if project not found.
 	// GitLab uses the API version page as a check, so a project not found error is returned in GetProjectID instead.
 	if resp.StatusCode == 404 {
-		return "", fmt.Errorf("project %s not found", project)
+		if project == "" {
+			return "", fmt.Errorf("namespace %s %w", namespace, utils.ErrNotFound)
+		}
+		return "", fmt.Errorf("project %s %w", project, utils.ErrNotFound)
 	}
 	return "", fmt.Errorf("Error: could not access GitLab or GitHub APIs")
 }
 
-func validateBaseURLScheme(baseURL string) (string, error) {
+// validateBaseURLScheme sets the scheme of baseURL to https, keeping http only if allowHTTP is set
+func validateBaseURLScheme(baseURL string, allowHTTP bool) (string, error) {
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		return "", err
 	}
+	if u.Scheme == "http" && allowHTTP {
+		return u.String(), nil
+	}
 	u.Scheme = "https"
 	q := u.Query()
 	u.RawQuery = q.Encode()
 	return u.String(), nil
 }
 
-func main() {
-	// Declaring variables for flags
-	var token, baseURL, namespace, project, interval string
-	var advance int
-	// Command Line Parsing Starts
-	flag.StringVar(&token, "token", "jGWPwqQUuf37b", "GitLab or GitHub API key/token")
-	flag.StringVar(&interval, "interval", "daily", "Set milestone to daily, weekly or monthly")
-	flag.StringVar(&baseURL, "url", "dev.example.com", "GitLab or GitHub API base URL")
-	flag.StringVar(&namespace, "namespace", "someNamespace", "Namespace to use in GitLab or GitHub")
-	flag.StringVar(&project, "project", "someProject", "Project to use in GitLab or GitHub")
-	flag.IntVar(&advance, "advance", 30, "Define timeframe to generate milestones in advance")
-	flag.Parse() //Command Line Parsing Ends
+// options shared by all commands
+type options struct {
+	token       string
+	baseURL     string
+	namespace   string
+	project     string
+	interval    string
+	titleFormat string
+	output      string
+	// Limit of the whole command, none if zero
+	timeout time.Duration
+	// Number of API requests sent at once
+	concurrency int
+	// Limit of a single attempt of an API request, none if zero
+	requestTimeout time.Duration
+	// File the token is read from unless it is given
+	tokenFile string
+	debug     bool
+	// GitHub App authenticating with installation tokens instead of the token
+	appID  int64
+	appKey string
+	// Keep an http:// base URL instead of switching it to https
+	allowHTTP bool
+	transport utils.TransportOptions
+}
 
-	// Initializing logger
-	LoggerSetup(os.Stdout)
+func (o *options) registerFlags(fs *flag.FlagSet) {
+	o.concurrency = utils.DefaultConcurrency
+	o.requestTimeout = utils.DefaultRequestTimeout
+	fs.StringVar(&o.token, "token", "", "GitLab or GitHub API key/token, looked up in $GOMILER_TOKEN, $GITHUB_TOKEN, $GITLAB_TOKEN, ~/.netrc and git credentials if not set")
+	fs.StringVar(&o.tokenFile, "token-file", "", "File to read the GitLab or GitHub API token from")
+	fs.Int64Var(&o.appID, "github-app-id", 0, "ID of a GitHub App to authenticate as with installation tokens instead of -token, requires -github-app-key")
+	fs.StringVar(&o.appKey, "github-app-key", "", "PEM file of the private key of the GitHub App")
+	fs.BoolVar(&o.debug, "debug", false, "Log debug messages, e.g. where the token was read from")
+	fs.StringVar(&o.interval, "interval", "daily", "Set milestone to daily, weekly or monthly")
+	fs.StringVar(&o.baseURL, "url", "", "GitLab or GitHub API base URL, e.g. gitlab.com or api.github.com")
+	fs.StringVar(&o.namespace, "namespace", "", "Namespace to use in GitLab or GitHub")
+	fs.StringVar(&o.project, "project", "", "Project to use in GitLab or GitHub")
+	fs.StringVar(&o.titleFormat, "title-format", "", "Milestone title format using {year}, {month}, {day} and {week}, defaults to the format of the interval")
+	fs.StringVar(&o.output, "output", "text", "Set output to text, json, yaml or table")
+	fs.Var(concurrencyValue{&o.concurrency}, "concurrency", "Number of API requests sent at once")
+	fs.DurationVar(&o.timeout, "timeout", 0, "Cancel the command, or each pass of serve, after this duration, e.g. 10m, 0 for no limit")
+	fs.BoolVar(&o.allowHTTP, "allow-http", false, "Allow an http:// base URL, e.g. of a local test instance, instead of switching it to https")
+	fs.StringVar(&o.transport.CACert, "ca-cert", "", "PEM file of CA certificates to trust in addition to the system ones")
+	fs.StringVar(&o.transport.ClientCert, "client-cert", "", "PEM file of a client certificate for mutual TLS, requires -client-key")
+	fs.StringVar(&o.transport.ClientKey, "client-key", "", "PEM file of the key of the client certificate")
+	fs.BoolVar(&o.transport.InsecureSkipVerify, "insecure-skip-verify", false, "Do not verify the TLS certificate of the API, only meant for test instances")
+	fs.StringVar(&o.transport.Proxy, "proxy", "", "HTTP(S) or socks5 proxy URL, defaults to the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables")
+	fs.Var(requestTimeoutValue{&o.requestTimeout}, "request-timeout", "Cancel a single attempt of an API request after this duration, 0 for no limit")
+}
 
-	// Validate baseURL scheme
-	URL, err := validateBaseURLScheme(baseURL)
+// apiContext returns ctx sending the API requests with the concurrency and request timeout of the options
+func (o options) apiContext(ctx context.Context) context.Context {
+	ctx = utils.WithConcurrency(ctx, o.concurrency)
+	return utils.WithRequestTimeout(ctx, o.requestTimeout)
+}
+
+// runContext returns the context of a command, cancelled by an interrupt or termination signal or after the timeout
+func (o options) runContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := signalContext(o.apiContext(context.Background()))
+	if o.timeout <= 0 {
+		return ctx, cancel
+	}
+	ctx, cancelTimeout := context.WithTimeout(ctx, o.timeout)
+	return ctx, func() {
+		cancelTimeout()
+		cancel()
+	}
+}
+
+// signalContext returns a context cancelled by the first interrupt or termination signal.
+// Further signals are no longer caught, so a second Ctrl-C exits at once.
+func signalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case s := <-signals:
+			logger.Printf("Received %s, cancelling, send it again to exit at once", s)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
+}
+
+// requestTimeoutValue is a flag setting the timeout of single API requests of a run
+type requestTimeoutValue struct{ d *time.Duration }
+
+func (v requestTimeoutValue) String() string {
+	if v.d == nil {
+		return ""
+	}
+	return v.d.String()
+}
+
+func (v requestTimeoutValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
 	if err != nil {
-		logger.Println(err)
+		return err
 	}
+	if d < 0 {
+		return fmt.Errorf("Error: Invalid request timeout %s", s)
+	}
+	*v.d = d
+	return nil
+}
 
-	// Check which API to use
-	api, err := checkAPI(URL, token, namespace, project)
+// concurrencyValue is a flag setting the number of concurrent API requests of a run
+type concurrencyValue struct{ n *int }
+
+func (v concurrencyValue) String() string {
+	if v.n == nil {
+		return ""
+	}
+	return strconv.Itoa(*v.n)
+}
+
+func (v concurrencyValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
 	if err != nil {
-		logger.Fatal(err)
+		return err
 	}
+	if err := utils.ValidateConcurrency(n); err != nil {
+		return err
+	}
+	*v.n = n
+	return nil
+}
 
-	// Calling getProjectID
-	var newBaseURL, projectID string
+// format returns the validated title format, falling back to the default format of the interval
+func (o *options) format() (string, error) {
+	o.interval = strings.ToLower(o.interval)
+	if o.titleFormat == "" {
+		o.titleFormat = utils.DefaultTitleFormat(o.interval)
+	}
+	return o.titleFormat, utils.ValidateTitleFormat(o.titleFormat, o.interval)
+}
 
-	interval = strings.ToLower(interval)
-	switch api {
-	case "gitlab":
-		milestoneData, err := utils.CreateMilestoneData(advance, interval, logger, api)
-		if err != nil {
-			logger.Fatal(err)
+// debugf logs a debug message if -debug is set
+func (o options) debugf(format string, v ...interface{}) {
+	if o.debug {
+		logger.Output(2, "DEBUG: "+fmt.Sprintf(format, v...))
+	}
+}
+
+// resolveToken reads the token from -token-file or, unless -token is set, looks it up for the host of the base URL.
+// The token stays empty if no source has one or a GitHub App is used.
+func (o *options) resolveToken() error {
+	switch {
+	case o.appID != 0 || o.appKey != "":
+		if o.appID == 0 || o.appKey == "" {
+			return fmt.Errorf("Error: -github-app-id and -github-app-key are required together")
 		}
-		newBaseURL = URL + "/api/v4"
-		projectID, err = gitlab.GetProjectID(newBaseURL, token, project, namespace)
-		if err != nil {
-			logger.Fatal(err)
+		if o.token != "" || o.tokenFile != "" {
+			return fmt.Errorf("Error: -token and -token-file cannot be used with a GitHub App")
 		}
-		err = gitlab.CreateAndDisplayNewMilestones(newBaseURL, token, projectID, milestoneData, logger)
+		o.debugf("Using the installation tokens of GitHub App %d", o.appID)
+	case o.token != "" && o.tokenFile != "":
+		return fmt.Errorf("Error: -token and -token-file cannot be used together")
+	case o.token != "":
+		o.debugf("Using the token of -token")
+	case o.tokenFile != "":
+		token, err := utils.ReadTokenFile(o.tokenFile)
 		if err != nil {
-			logger.Println(err)
+			return err
 		}
-		closedMilestones, err := gitlab.GetClosedMilestones(newBaseURL, token, projectID, milestoneData)
+		o.token = token
+		o.debugf("Using the token of %s", o.tokenFile)
+	case o.baseURL != "":
+		URL, err := validateBaseURLScheme(o.baseURL, o.allowHTTP)
 		if err != nil {
-			logger.Println(err)
+			return err
 		}
-		_, err = gitlab.ReactivateClosedMilestones(closedMilestones, newBaseURL, token, projectID, logger)
+		u, err := url.Parse(URL)
 		if err != nil {
-			logger.Println(err)
+			return err
 		}
-	case "github":
-		milestoneData, err := utils.CreateMilestoneData(advance, interval, logger, api)
+		c, ok, err := utils.LookupToken(context.Background(), u.Host)
 		if err != nil {
-			logger.Fatal(err)
+			return err
 		}
-		newBaseURL = URL + "/repos/" + namespace + "/"
-		err = github.CreateAndDisplayNewMilestones(newBaseURL, token, project, milestoneData, logger)
+		if !ok {
+			o.debugf("No token found for %s", u.Host)
+			return nil
+		}
+		o.token = c.Token
+		o.debugf("Using the token of %s for %s", c.Source, u.Host)
+	}
+	return nil
+}
+
+// credential returns what authenticates the requests, the token or the GitHub App
+func (o options) credential() string {
+	if o.appID != 0 {
+		return fmt.Sprintf("GitHub App %d", o.appID)
+	}
+	return o.token
+}
+
+// apiURL returns the base URL with its scheme and applies the transport options to the requests to it.
+// With a GitHub App the requests to the project, or the namespace if no project is set, use its installation tokens.
+func (o options) apiURL() (string, error) {
+	URL, err := validateBaseURLScheme(o.baseURL, o.allowHTTP)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(URL)
+	if err != nil {
+		return "", err
+	}
+	changed, err := utils.DefaultClient.Configure(u.Host, o.transport)
+	if err != nil {
+		return "", err
+	}
+	if changed && o.transport.InsecureSkipVerify {
+		logger.Printf("Warning: TLS certificates of %s are not verified, connections can be intercepted", u.Host)
+	}
+	if o.appID != 0 {
+		app, err := github.LoadApp(URL, o.appID, o.appKey)
 		if err != nil {
-			logger.Println(err)
+			return "", err
 		}
-		closedMilestones, err := github.GetClosedMilestones(newBaseURL, token, project, milestoneData)
+		github.Authorize(utils.DefaultClient, app, o.namespace, o.project)
+	}
+	return URL, nil
+}
+
+// target is a GitLab project or GitHub repository resolved from the options
+type target struct {
+	api     string
+	baseURL string
+	// GitLab project ID or GitHub repository name
+	project string
+}
+
+// connect checks which API to use and resolves the project
+func connect(ctx context.Context, o options) (target, error) {
+	var missing []string
+	for _, f := range []struct{ name, value string }{
+		{"-url", o.baseURL},
+		{"-token", o.credential()},
+		{"-namespace", o.namespace},
+		{"-project", o.project},
+	} {
+		if f.value == "" {
+			missing = append(missing, f.name)
+		}
+	}
+	if len(missing) > 0 {
+		return target{}, fmt.Errorf("Error: %s required", strings.Join(missing, ", "))
+	}
+
+	// Validate baseURL scheme
+	URL, err := o.apiURL()
+	if err != nil {
+		return target{}, err
+	}
+
+	// Check which API to use
+	api, err := checkAPI(ctx, URL, o.token, o.namespace, o.project)
+	if err != nil {
+		return target{}, err
+	}
+
+	t := target{api: api}
+	switch api {
+	case "gitlab":
+		t.baseURL = URL + "/api/v4"
+		t.project, err = gitlab.GetProjectID(ctx, t.baseURL, o.token, o.project, o.namespace)
 		if err != nil {
-			logger.Println(err)
+			return target{}, err
+		}
+	case "github":
+		t.baseURL = URL + "/repos/" + o.namespace + "/"
+		t.project = o.project
+	}
+	return t, nil
+}
+
+// setDescription sets the description of all milestones
+func setDescription(milestones map[string]utils.Milestone, description string) {
+	for k, v := range milestones {
+		v.Description = description
+		milestones[k] = v
+	}
+}
+
+// driftResults returns the results of drifted milestones with their changed fields
+func driftResults(drifts []utils.Drift) []utils.MilestoneResult {
+	results := []utils.MilestoneResult{}
+	for _, d := range drifts {
+		results = append(results, utils.NewMilestoneResult(d.Existing, d.Detail()))
+	}
+	return results
+}
+
+// writeResult writes the result of a command to stdout
+func writeResult(result utils.Result, output string) {
+	if result.Empty() && output == "text" {
+		logger.Println("No milestone changes needed")
+		if result.Throttled != nil {
+			logger.Printf("Throttled: %s", result.Throttled)
+		}
+		return
+	}
+	err := utils.WriteOutput(os.Stdout, result, output)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// openMilestones returns the milestones that are not closed yet
+func openMilestones(milestones map[string]utils.Milestone) map[string]utils.Milestone {
+	open := map[string]utils.Milestone{}
+	for k, v := range milestones {
+		if v.State != "closed" {
+			open[k] = v
+		}
+	}
+	return open
+}
+
+func main() {
+	// Initializing logger
+	LoggerSetup(os.Stderr)
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "adopt":
+			runAdopt(os.Args[2:])
+			return
+		case "report":
+			runReport(os.Args[2:])
+			return
+		case "velocity":
+			runVelocity(os.Args[2:])
+			return
+		case "notes":
+			runNotes(os.Args[2:])
+			return
+		case "ical":
+			runICal(os.Args[2:])
+			return
+		case "plan":
+			runPlan(os.Args[2:])
+			return
+		case "apply":
+			runApply(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
 		}
-		_, err = github.ReactivateClosedMilestones(closedMilestones, newBaseURL, token, project)
+	}
+
+	// Declaring variables for flags
+	var o options
+	var r runOptions
+	var configFile string
+	// Command Line Parsing Starts
+	newRunFlagSet(os.Args[0], &o, &r, &configFile, flag.ExitOnError).Parse(os.Args[1:]) //Command Line Parsing Ends
+
+	if err := utils.ValidateOutputFormat(o.output); err != nil {
+		logger.Fatal(err)
+	}
+	ctx, cancel := o.runContext()
+	defer cancel()
+	if configFile != "" {
+		runConfig(ctx, configFile, os.Args[1:], o.output)
+		return
+	}
+	if err := o.resolveToken(); err != nil {
+		logger.Fatal(err)
+	}
+	if o.project == "" && o.namespace != "" {
+		runs, err := expandProjects(ctx, o, r)
 		if err != nil {
-			logger.Println(err)
+			logger.Fatal(err)
 		}
+		runProjects(ctx, runs, o.output)
+		return
+	}
+
+	start := utils.DefaultClient.Stats()
+	result, err := run(ctx, o, r)
+	result.Throttled = utils.DefaultClient.Stats().Since(start)
+	writeResult(result, o.output)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// run plans and applies the schedule of a project
+func run(ctx context.Context, o options, r runOptions) (utils.Result, error) {
+	if err := r.validate(); err != nil {
+		return utils.Result{}, err
+	}
+	if _, err := o.format(); err != nil {
+		return utils.Result{}, err
+	}
+	t, err := connect(ctx, o)
+	if err != nil {
+		return utils.Result{}, err
+	}
+	return runTarget(ctx, t, o, r)
+}
+
+// runTarget plans and applies the schedule of a resolved project
+func runTarget(ctx context.Context, t target, o options, r runOptions) (utils.Result, error) {
+	plan, err := makePlan(ctx, t, o, r)
+	if err != nil {
+		return utils.Result{}, err
 	}
+	return applyPlan(ctx, t, o.token, plan)
 }
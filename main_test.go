@@ -15,18 +15,43 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	github "go.okkur.org/gomiler/github"
+	gitlab "go.okkur.org/gomiler/gitlab"
+	"go.okkur.org/gomiler/utils"
 	httpmock "gopkg.in/jarcoal/httpmock.v1"
 )
 
+func TestMain(m *testing.M) {
+	LoggerSetup(ioutil.Discard)
+	utils.DefaultClient.GithubWriteInterval = 0
+	utils.DefaultClient.Backoff = 0
+	os.Exit(m.Run())
+}
+
 func TestGithubCheckAPI(t *testing.T) {
 	httpmock.Activate()
 	defer httpmock.DeactivateAndReset()
 	mockURL := "https://" + "api.github.com"
 	httpmock.RegisterResponder("GET", "https://api.github.com/repos/test/test",
 		httpmock.NewStringResponder(200, ""))
-	res, err := checkAPI(mockURL, "token", "test", "test")
+	res, err := checkAPI(context.Background(), mockURL, "token", "test", "test")
 	if res != "github" && res != "" && err != nil {
 		t.Errorf("Expected %s, got %s", "github", res)
 	}
@@ -38,7 +63,7 @@ func TestGitlabCheckAPI(t *testing.T) {
 	mockURL := "https://" + "gitlab.com"
 	httpmock.RegisterResponder("GET", "https://gitlab.com/api/v4/version",
 		httpmock.NewStringResponder(200, ""))
-	res, err := checkAPI(mockURL, "token", "test", "test")
+	res, err := checkAPI(context.Background(), mockURL, "token", "test", "test")
 	if res != "gitlab" && res != "" && err != nil {
 		t.Errorf("Expected %s, got %s", "gitlab", res)
 	}
@@ -50,7 +75,7 @@ func TestGithubCheckAPIwithInvalidToken(t *testing.T) {
 	mockURL := "https://" + "api.github.com"
 	httpmock.RegisterResponder("GET", "https://api.github.com/repos/test/test",
 		httpmock.NewStringResponder(403, ""))
-	_, err := checkAPI(mockURL, "token", "test", "test")
+	_, err := checkAPI(context.Background(), mockURL, "token", "test", "test")
 	if err == nil {
 		t.Errorf("Expected to get an error when token is invalid")
 	}
@@ -62,7 +87,7 @@ func TestGitlabCheckAPIwithInvalidToken(t *testing.T) {
 	mockURL := "https://" + "gitlab.com"
 	httpmock.RegisterResponder("GET", "https://gitlab.com/api/v4/version",
 		httpmock.NewStringResponder(403, ""))
-	_, err := checkAPI(mockURL, "token", "test", "test")
+	_, err := checkAPI(context.Background(), mockURL, "token", "test", "test")
 	if err == nil {
 		t.Errorf("Expected to get an error when token is invalid")
 	}
@@ -70,7 +95,7 @@ func TestGitlabCheckAPIwithInvalidToken(t *testing.T) {
 
 func TestValidateBaseURLScheme(t *testing.T) {
 	URL := "example.com"
-	baseURL, err := validateBaseURLScheme(URL)
+	baseURL, err := validateBaseURLScheme(URL, false)
 	if baseURL != "https:/example.com" && err != nil {
 		t.Errorf("Expected %s, got %s", "https://example.com", baseURL)
 	}
@@ -78,8 +103,534 @@ func TestValidateBaseURLScheme(t *testing.T) {
 
 func TestValidateBaseURLSchemeWhenSchemeAlreadyExists(t *testing.T) {
 	URL := "https://example.com"
-	baseURL, err := validateBaseURLScheme(URL)
+	baseURL, err := validateBaseURLScheme(URL, false)
 	if baseURL != "https://example.com" && err != nil {
 		t.Errorf("Expected %s, got %s", "https://example.com", baseURL)
 	}
 }
+
+func TestValidateBaseURLSchemeAllowHTTP(t *testing.T) {
+	baseURL, err := validateBaseURLScheme("http://localhost:8080", true)
+	if err != nil || baseURL != "http://localhost:8080" {
+		t.Errorf("Expected %s, got %s, %v", "http://localhost:8080", baseURL, err)
+	}
+	baseURL, err = validateBaseURLScheme("http://localhost:8080", false)
+	if err != nil || baseURL != "https://localhost:8080" {
+		t.Errorf("Expected %s, got %s, %v", "https://localhost:8080", baseURL, err)
+	}
+}
+
+func TestResolveToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomiler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(path, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	o := options{tokenFile: path, baseURL: "gitlab.com"}
+	if err := o.resolveToken(); err != nil || o.token != "file-token" {
+		t.Errorf("Expected the token of the file, got %q, %v", o.token, err)
+	}
+	o = options{token: "flag-token", tokenFile: path}
+	if err := o.resolveToken(); err == nil {
+		t.Errorf("Expected an error for -token with -token-file")
+	}
+
+	previous, ok := os.LookupEnv("GOMILER_TOKEN")
+	os.Setenv("GOMILER_TOKEN", "env-token")
+	defer func() {
+		if ok {
+			os.Setenv("GOMILER_TOKEN", previous)
+		} else {
+			os.Unsetenv("GOMILER_TOKEN")
+		}
+	}()
+	o = options{token: "flag-token", baseURL: "gitlab.com"}
+	if err := o.resolveToken(); err != nil || o.token != "flag-token" {
+		t.Errorf("Expected the token of -token, got %q, %v", o.token, err)
+	}
+	o = options{baseURL: "gitlab.com"}
+	if err := o.resolveToken(); err != nil || o.token != "env-token" {
+		t.Errorf("Expected the token of $GOMILER_TOKEN, got %q, %v", o.token, err)
+	}
+}
+
+func TestGithubApp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomiler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "app.pem")
+	content := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(keyFile, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range []options{
+		{appID: 42},
+		{appID: 42, appKey: keyFile, token: "token"},
+	} {
+		if err := o.resolveToken(); err == nil {
+			t.Errorf("Expected an error for %+v", o)
+		}
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	URL := "https://api.github.com"
+	issued := github.MockGithubAppRequests(URL, "namespace", time.Hour)
+	httpmock.RegisterResponder("GET", URL+"/api/v4/version", httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
+	var authorization string
+	httpmock.RegisterResponder("GET", URL+"/repos/namespace/app", func(req *http.Request) (*http.Response, error) {
+		authorization = req.Header.Get("Authorization")
+		return httpmock.NewStringResponse(200, `{"name": "app"}`), nil
+	})
+	defer utils.DefaultClient.Authorize(URL+"/repos/namespace/app", nil)
+
+	o := options{baseURL: "api.github.com", namespace: "namespace", project: "app", appID: 42, appKey: keyFile}
+	if err := o.resolveToken(); err != nil || o.token != "" {
+		t.Fatalf("Expected no token with a GitHub App, got %q, %v", o.token, err)
+	}
+	tgt, err := connect(context.Background(), o)
+	if err != nil || tgt.api != "github" {
+		t.Fatalf("Expected the GitHub repository, got %v, %v", tgt, err)
+	}
+	if authorization != "token ghs-1" || *issued != 1 {
+		t.Errorf("Expected the installation token, got %q after %d tokens", authorization, *issued)
+	}
+}
+
+func TestICalFeedSchedule(t *testing.T) {
+	f := icalFeed{
+		o:           options{namespace: "test", project: "test", interval: "weekly"},
+		titleFormat: utils.DefaultTitleFormat("weekly"),
+		schedule:    true,
+		advance:     4,
+	}
+	rec := httptest.NewRecorder()
+	f.ServeHTTP(rec, httptest.NewRequest("GET", "/milestones.ics", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec.Header().Get("Content-Type") != utils.ICalContentType {
+		t.Errorf("Expected %s, got %s", utils.ICalContentType, rec.Header().Get("Content-Type"))
+	}
+	if strings.Count(rec.Body.String(), "BEGIN:VEVENT") != 4 {
+		t.Errorf("Expected %d events, got %s", 4, rec.Body.String())
+	}
+}
+
+func TestPlanAndApply(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://" + "api.github.com"
+	github.MockGithubAPIExpiredGetRequest(mockURL)
+	github.MockGithubAPIPostRequest(mockURL, "open")
+	github.MockGithubAPIPatchRequest(mockURL, "open", "0")
+	github.MockGithubAPIPatchRequest(mockURL, "closed", "1")
+	tgt := target{api: "github", baseURL: mockURL, project: "1"}
+	o := options{token: "token", interval: "daily", titleFormat: utils.DefaultTitleFormat("daily")}
+	r := runOptions{advance: 3, drift: "fix", reactivate: "always", closeExpired: true, pruneMode: "delete"}
+
+	plan, err := makePlan(context.Background(), tgt, o, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int{utils.ActionCreate: 2, utils.ActionReopen: 1, utils.ActionClose: 1, utils.ActionDelete: 0}
+	for kind, n := range expected {
+		if len(plan.Milestones(kind)) != n {
+			t.Errorf("Expected %d to %s, got %v", n, kind, plan.Milestones(kind))
+		}
+	}
+	allMilestones, err := getAllMilestones(context.Background(), tgt, o.token)
+	if err != nil {
+		t.Error(err)
+	}
+	err = plan.Check(tgt.api, tgt.baseURL, tgt.project, allMilestones)
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := applyPlan(context.Background(), tgt, o.token, plan)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(result.Created) != 2 || len(result.Failed) != 0 || len(result.Reactivated) != 1 || len(result.Closed) != 1 {
+		t.Errorf("Unexpected result %v", result)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err = applyPlan(ctx, tgt, o.token, plan)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the apply to be cancelled, got %v", err)
+	}
+	if len(result.Created) != 0 || len(result.Failed) != 0 || len(result.Pending) != len(plan.Actions) {
+		t.Errorf("Expected all actions to be pending, got %v", result)
+	}
+}
+
+func TestNextMilestoneTitleSkipsUnmanaged(t *testing.T) {
+	tomorrow := time.Now().Local().AddDate(0, 0, 1).Format("2006-01-02")
+	later := time.Now().Local().AddDate(0, 0, 2).Format("2006-01-02")
+	milestoneData := map[string]utils.Milestone{tomorrow: {Title: tomorrow, DueDate: tomorrow}, later: {Title: later, DueDate: later}}
+	allMilestones := map[string]utils.Milestone{
+		// created by hand with a generated title
+		tomorrow: {Title: tomorrow, DueDate: tomorrow, State: "active"},
+		later:    {Title: later, DueDate: later, State: "active", Description: utils.Marker},
+	}
+	if next := nextMilestoneTitle(milestoneData, allMilestones, nil, nil); next != later {
+		t.Errorf("Expected %s, got %s", later, next)
+	}
+}
+
+func TestApplyPlanFailures(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://api.github.com/repos/namespace/"
+	httpmock.RegisterResponder("PATCH", mockURL+"1/milestones/1", httpmock.NewStringResponder(404, `{"message": "Not Found"}`))
+	httpmock.RegisterResponder("PATCH", mockURL+"1/milestones/2", httpmock.NewStringResponder(422, `{"message": "Validation Failed"}`))
+	httpmock.RegisterResponder("DELETE", mockURL+"1/milestones/3", httpmock.NewStringResponder(204, ""))
+	tgt := target{api: "github", baseURL: mockURL, project: "1"}
+	plan := utils.Plan{Actions: []utils.Action{
+		{Kind: utils.ActionClose, Milestone: utils.Milestone{Title: "2018-01-01", Number: 1}},
+		{Kind: utils.ActionUpdate, Milestone: utils.Milestone{Title: "2018-01-02", Number: 2, DueDate: "2018-01-02"},
+			Desired: &utils.Milestone{Title: "2018-01-02", DueDate: "2018-01-03"}, Fields: []string{"due_date"}},
+		{Kind: utils.ActionDelete, Milestone: utils.Milestone{Title: "2018-01-03", Number: 3}},
+	}}
+
+	result, err := applyPlan(context.Background(), tgt, "token", plan)
+	if !errors.Is(err, utils.ErrValidation) {
+		t.Errorf("Expected the first failed action to be returned, got %v", err)
+	}
+	if len(result.Failed) != 2 || result.Failed[0].Title != "2018-01-02" || result.Failed[1].Title != "2018-01-01" {
+		t.Errorf("Expected the updated and closed milestones to fail, got %v", result.Failed)
+	}
+	if len(result.Drifted) != 0 || len(result.Closed) != 0 || len(result.Pruned) != 1 {
+		t.Errorf("Expected only the deleted milestone to be applied, got %v", result)
+	}
+}
+
+func TestExpandProjects(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", "https://gitlab.com/api/v4/version",
+		httpmock.NewStringResponder(200, ""))
+	gitlab.MockGitlabAPIProjectsRequest("https://gitlab.com/api/v4", "group")
+
+	o := options{baseURL: "gitlab.com", token: "token", namespace: "group", interval: "daily"}
+	r := runOptions{drift: "fix", reactivate: "always", pruneMode: "delete", selection: selection{exclude: "app"}}
+	runs, err := expandProjects(context.Background(), o, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].name != "group/sub/lib" || runs[0].t.project != "2" || runs[0].t.baseURL != "https://gitlab.com/api/v4" {
+		t.Errorf("Expected the unarchived project not excluded, got %v", runs)
+	}
+
+	o.project = "app"
+	runs, err = expandProjects(context.Background(), o, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].name != "group/app" || runs[0].t != nil {
+		t.Errorf("Expected the run of the project, got %v", runs)
+	}
+}
+
+func TestProjectOptions(t *testing.T) {
+	c := utils.Config{
+		Defaults:  map[string]string{"interval": "weekly", "close-expired": "true", "advance": "8"},
+		Providers: []utils.ProviderConfig{{Name: "gitlab", URL: "gitlab.com", Token: "token"}},
+		Projects: []utils.ProjectConfig{
+			{Provider: "gitlab", Namespace: "team", Project: "app", Settings: map[string]string{"advance": "12"}},
+		},
+	}
+	o, r, err := projectOptions(c, c.Projects[0], []string{"-config", "gomiler.yaml", "-interval", "monthly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.baseURL != "gitlab.com" || o.token != "token" || o.namespace != "team" || o.project != "app" {
+		t.Errorf("Expected provider and project settings, got %v", o)
+	}
+	if o.interval != "monthly" || !r.closeExpired || r.advance != 12 {
+		t.Errorf("Expected flags to override project settings overriding defaults, got %v %v", o, r)
+	}
+
+	c.Projects[0].Settings = map[string]string{"concurrency": "2", "request-timeout": "5s"}
+	o, _, err = projectOptions(c, c.Projects[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := o.apiContext(context.Background())
+	if o.concurrency != 2 || o.requestTimeout != 5*time.Second || utils.Concurrency(ctx) != 2 {
+		t.Errorf("Expected the request settings of the project, got %v", o)
+	}
+	if utils.Concurrency(context.Background()) != utils.DefaultConcurrency || utils.DefaultClient.Timeout != utils.DefaultRequestTimeout {
+		t.Errorf("Expected the request settings of the project not to apply to others")
+	}
+
+	c.Projects[0].Settings = map[string]string{"advance": "soon"}
+	_, _, err = projectOptions(c, c.Projects[0], nil)
+	if err == nil || !strings.Contains(err.Error(), "advance") {
+		t.Errorf("Expected an error naming the invalid setting, got %v", err)
+	}
+	c.Projects[0].Settings = map[string]string{"sprint": "2"}
+	_, _, err = projectOptions(c, c.Projects[0], nil)
+	if err == nil || !strings.Contains(err.Error(), "unknown setting sprint") {
+		t.Errorf("Expected an error naming the unknown setting, got %v", err)
+	}
+}
+
+func TestDaemon(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	dir, err := ioutil.TempDir("", "gomiler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	d := &daemon{configFile: filepath.Join(dir, "gomiler.yaml"), args: []string{"-interval=weekly"}, output: "json"}
+	config := "providers:\n  - {name: gitlab, url: gitlab.com, token: token}\nprojects:\n  - {provider: gitlab, namespace: team, project: app}\n"
+	ioutil.WriteFile(d.configFile, []byte(config), 0600)
+	if err := d.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string]int{"/healthz": http.StatusOK, "/readyz": http.StatusServiceUnavailable} {
+		rec := httptest.NewRecorder()
+		d.handler().ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != expected {
+			t.Errorf("%s: expected %d, got %d", path, expected, rec.Code)
+		}
+	}
+	// the project fails without API responders, the pass still completes
+	d.pass(context.Background())
+	rec := httptest.NewRecorder()
+	d.handler().ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected to be ready after the first pass, got %d", rec.Code)
+	}
+
+	ioutil.WriteFile(d.configFile, []byte(strings.Replace(config, "project: app}", "project: app, sprint: 2}", 1)), 0600)
+	if err := d.load(); err == nil {
+		t.Errorf("Expected to get an error reloading an invalid configuration")
+	}
+	if len(d.config.Projects) != 1 || d.config.Projects[0].Settings["sprint"] != "" {
+		t.Errorf("Expected the previous configuration to be kept, got %v", d.config)
+	}
+}
+
+func TestDaemonReadyAfterFailedPass(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomiler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	ioutil.WriteFile(tokenFile, []byte("token"), 0600)
+	d := &daemon{configFile: filepath.Join(dir, "gomiler.yaml"), output: "json"}
+	config := "providers:\n  - {name: gitlab, url: gitlab.com, token_file: " + tokenFile + "}\nprojects:\n  - {provider: gitlab, namespace: team, project: app}\n"
+	ioutil.WriteFile(d.configFile, []byte(config), 0600)
+	if err := d.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the token can no longer be read, so the projects of the pass cannot be resolved
+	os.Remove(tokenFile)
+	d.pass(context.Background())
+	rec := httptest.NewRecorder()
+	d.handler().ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "last pass failed") {
+		t.Errorf("Expected to be ready reporting the failed pass, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	ioutil.WriteFile(tokenFile, []byte("token"), 0600)
+	d.config.Projects = nil
+	d.pass(context.Background())
+	rec = httptest.NewRecorder()
+	d.handler().ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if strings.TrimSpace(rec.Body.String()) != "ok" {
+		t.Errorf("Expected the error to be cleared by the next pass, got %q", rec.Body.String())
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	c := utils.Config{
+		Providers: []utils.ProviderConfig{{Name: "gitlab", URL: "gitlab.com", Token: "token", WebhookSecret: "secret"}},
+		Projects:  []utils.ProjectConfig{{Provider: "gitlab", Namespace: "team", Project: "app"}},
+	}
+	d := &daemon{config: c, output: "json", ctx: context.Background()}
+	closed := `{"object_kind": "milestone", "action": "close", "project": {"path_with_namespace": "other/app"}, "object_attributes": {"title": "2018-01-01"}}`
+	cases := []struct {
+		method, token, event string
+		expected             int
+	}{
+		{"GET", "secret", "Milestone Hook", http.StatusMethodNotAllowed},
+		{"POST", "wrong", "Milestone Hook", http.StatusUnauthorized},
+		{"POST", "secret", "Push Hook", http.StatusOK},
+		{"POST", "secret", "Milestone Hook", http.StatusAccepted},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/webhooks/gitlab", strings.NewReader(closed))
+		req.Header.Set("X-Gitlab-Token", c.token)
+		req.Header.Set("X-Gitlab-Event", c.event)
+		rec := httptest.NewRecorder()
+		d.handler().ServeHTTP(rec, req)
+		if rec.Code != c.expected {
+			t.Errorf("%s %s %s: expected %d, got %d", c.method, c.token, c.event, c.expected, rec.Code)
+		}
+	}
+
+	// events arriving while a reconciliation of the project waits for the running pass are coalesced
+	d = &daemon{config: c, output: "json", ctx: context.Background()}
+	d.passMu.Lock()
+	for i, expected := range []string{"accepted", "already queued", "already queued"} {
+		req := httptest.NewRequest("POST", "/webhooks/gitlab", strings.NewReader(closed))
+		req.Header.Set("X-Gitlab-Token", "secret")
+		req.Header.Set("X-Gitlab-Event", "Milestone Hook")
+		rec := httptest.NewRecorder()
+		d.handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusAccepted || strings.TrimSpace(rec.Body.String()) != expected {
+			t.Errorf("Event %d: expected %q, got %d %q", i, expected, rec.Code, rec.Body.String())
+		}
+	}
+	d.passMu.Unlock()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		d.mu.Lock()
+		pending := len(d.queued)
+		d.mu.Unlock()
+		if pending == 0 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("Expected the queued reconciliation to start")
+		}
+	}
+
+	runs, err := projectRuns(context.Background(), c, nil, map[string]bool{"gitlab": true}, "team/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].name != "team/app" {
+		t.Errorf("Expected the run of the configured project, got %v", runs)
+	}
+	runs, _ = projectRuns(context.Background(), c, nil, map[string]bool{"github": true}, "team/app")
+	if len(runs) != 0 {
+		t.Errorf("Expected no runs of projects of other providers, got %v", runs)
+	}
+}
+
+func TestCalendarFeed(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockURL := "https://gitlab.com/api/v4"
+	httpmock.RegisterResponder("GET", mockURL+"/version", httpmock.NewStringResponder(200, "{}"))
+	gitlab.MockGitlabAPIProjectsRequest(mockURL, "group")
+	milestones, _ := json.Marshal(gitlab.MockGitlabAPIExpired())
+	httpmock.RegisterResponder("GET", mockURL+"/projects/2/milestones", httpmock.NewBytesResponder(200, milestones))
+	d := &daemon{calendarToken: "feed-token", ctx: context.Background(), ready: true, config: utils.Config{
+		Providers: []utils.ProviderConfig{{Name: "gitlab", URL: "gitlab.com", Token: "token"}},
+		Projects:  []utils.ProjectConfig{{Provider: "gitlab", Namespace: "group"}},
+	}}
+	request := func(path string, header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if header != "" {
+			req.Header.Set("Authorization", "Bearer "+header)
+		}
+		rec := httptest.NewRecorder()
+		d.handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the subgroup project listed from the namespace is not looked up again by name
+	rec := request("/calendars/group/sub/lib/milestones.ics?token=feed-token", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != utils.ICalContentType || !strings.Contains(rec.Body.String(), "BEGIN:VEVENT") {
+		t.Errorf("Unexpected calendar %d %s", rec.Code, rec.Body.String())
+	}
+	for path, status := range map[string]int{
+		"/calendars/group/sub/lib/milestones.ics":                http.StatusUnauthorized,
+		"/calendars/group/sub/lib/milestones.ics?token=wrong":    http.StatusUnauthorized,
+		"/calendars/group/other/milestones.ics?token=feed-token": http.StatusNotFound,
+		"/calendars/group/sub/lib/calendar?token=feed-token":     http.StatusNotFound,
+	} {
+		if rec := request(path, "feed-token"); rec.Code != status {
+			t.Errorf("%s: expected %d, got %d", path, status, rec.Code)
+		}
+	}
+	d.calendarToken = ""
+	if rec := request("/calendars/group/sub/lib/milestones.ics?token=", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected the feeds to be disabled without a token, got %d", rec.Code)
+	}
+}
+
+func TestAPI(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", "https://api.github.com/api/v4/version", httpmock.NewStringResponder(404, ""))
+	httpmock.RegisterResponder("GET", "https://api.github.com/repos/namespace/1", httpmock.NewStringResponder(200, "{}"))
+	mockURL := "https://api.github.com/repos/namespace/"
+	github.MockGithubAPIExpiredGetRequest(mockURL)
+	github.MockGithubAPIPostRequest(mockURL, "open")
+	github.MockGithubAPIPatchRequest(mockURL, "open", "0")
+	github.MockGithubAPIPatchRequest(mockURL, "closed", "1")
+	d := &daemon{apiToken: "api-token", ctx: context.Background(), config: utils.Config{
+		Defaults:  map[string]string{"advance": "3", "close-expired": "true"},
+		Providers: []utils.ProviderConfig{{Name: "github", URL: "api.github.com", Token: "token"}},
+		Projects:  []utils.ProjectConfig{{Provider: "github", Namespace: "namespace", Project: "1"}},
+	}}
+	request := func(method string, path string, token string, body interface{}, v interface{}) int {
+		var buf bytes.Buffer
+		json.NewEncoder(&buf).Encode(body)
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		d.handler().ServeHTTP(rec, req)
+		if v != nil {
+			json.Unmarshal(rec.Body.Bytes(), v)
+		}
+		return rec.Code
+	}
+
+	if code := request("GET", "/api/v1/projects", "wrong", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected %d without the bearer token, got %d", http.StatusUnauthorized, code)
+	}
+	if code := request("GET", "/api/v1/projects", "api-token", nil, nil); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected %d before the first pass, got %d", http.StatusServiceUnavailable, code)
+	}
+	d.ready = true
+	var projects []apiProject
+	if code := request("GET", "/api/v1/projects", "api-token", nil, &projects); code != http.StatusOK || len(projects) != 1 || projects[0].Name != "namespace/1" {
+		t.Errorf("Unexpected projects %d %v", code, projects)
+	}
+	if code := request("GET", "/api/v1/plan?project=namespace/2", "api-token", nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected %d for an unknown project, got %d", http.StatusNotFound, code)
+	}
+	var plan utils.Plan
+	if code := request("GET", "/api/v1/plan?project=namespace/1", "api-token", nil, &plan); code != http.StatusOK || len(plan.Milestones(utils.ActionCreate)) != 2 {
+		t.Fatalf("Unexpected plan %d %v", code, plan)
+	}
+
+	stale := plan
+	stale.Fingerprint = "stale"
+	if code := request("POST", "/api/v1/apply", "api-token", applyRequest{"namespace/1", stale}, nil); code != http.StatusConflict {
+		t.Errorf("Expected %d for a stale plan, got %d", http.StatusConflict, code)
+	}
+	var result utils.Result
+	if code := request("POST", "/api/v1/apply", "api-token", applyRequest{"namespace/1", plan}, &result); code != http.StatusOK || len(result.Created) != 2 || result.Project != "namespace/1" {
+		t.Errorf("Unexpected result %d %v", code, result)
+	}
+	var runs []runRecord
+	if code := request("GET", "/api/v1/runs?project=namespace/1", "api-token", nil, &runs); code != http.StatusOK || len(runs) != 1 || runs[0].Trigger != "api" {
+		t.Errorf("Unexpected runs %d %v", code, runs)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	github "go.okkur.org/gomiler/github"
+	gitlab "go.okkur.org/gomiler/gitlab"
+	"go.okkur.org/gomiler/utils"
+)
+
+// runMigrate renames milestones following an old title format to the current title format
+func runMigrate(args []string) {
+	var o options
+	var fromFormat string
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	o.registerFlags(fs)
+	fs.StringVar(&fromFormat, "from-format", "", "Title format to migrate from, defaults to the format of the interval")
+	fs.Parse(args)
+
+	if o.titleFormat == "" {
+		logger.Fatal("Error: -title-format is required to migrate milestones")
+	}
+	if err := utils.ValidateOutputFormat(o.output); err != nil {
+		logger.Fatal(err)
+	}
+	toFormat, err := o.format()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if fromFormat == "" {
+		fromFormat = utils.DefaultTitleFormat(o.interval)
+	}
+	err = utils.ValidateTitleFormat(fromFormat, o.interval)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if fromFormat == toFormat {
+		logger.Fatal(fmt.Errorf("Error: title format %s is already in use", toFormat))
+	}
+
+	if err := o.resolveToken(); err != nil {
+		logger.Fatal(err)
+	}
+	ctx, cancel := o.runContext()
+	defer cancel()
+	t, err := connect(ctx, o)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	var milestones map[string]utils.Milestone
+	switch t.api {
+	case "gitlab":
+		milestones, err = gitlab.GetAllMilestones(ctx, t.baseURL, o.token, t.project)
+	case "github":
+		milestones, err = github.GetAllMilestones(ctx, t.baseURL, o.token, t.project)
+	}
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	renames, unmatched := utils.PlanRenames(milestones, fromFormat, toFormat)
+	var renamed []utils.Rename
+	switch t.api {
+	case "gitlab":
+		renamed, err = gitlab.RenameMilestones(ctx, renames, t.baseURL, o.token, t.project)
+	case "github":
+		renamed, err = github.RenameMilestones(ctx, renames, t.baseURL, o.token, t.project)
+	}
+	var result utils.Result
+	result.Renamed = renamedResults(renamed)
+	result.Unmatched = utils.ReasonResults(unmatched, milestones)
+	if err != nil {
+		renaming := map[string]utils.Milestone{}
+		for _, r := range renames {
+			renaming[r.Milestone.Title] = r.Milestone
+		}
+		reasons := failureReasons(err, renaming)
+		failed := withoutCancelled(ctx, reasons)
+		result.Failed = utils.ReasonResults(failed, renaming)
+		// Renames not started before the command was cancelled
+		pending := map[string]string{}
+		for k := range reasons {
+			if _, ok := failed[k]; !ok {
+				pending[k] = "rename"
+			}
+		}
+		result.Pending = utils.ReasonResults(pending, renaming)
+	}
+	writeResult(result, o.output)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// renamedResults returns the results of renamed milestones
+func renamedResults(renamed []utils.Rename) []utils.MilestoneResult {
+	results := []utils.MilestoneResult{}
+	for _, r := range renamed {
+		result := utils.NewMilestoneResult(r.Milestone, "")
+		result.NewTitle = r.Title
+		results = append(results, result)
+	}
+	return results
+}
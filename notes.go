@@ -0,0 +1,120 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	github "go.okkur.org/gomiler/github"
+	gitlab "go.okkur.org/gomiler/gitlab"
+	"go.okkur.org/gomiler/utils"
+)
+
+// runNotes renders release notes from the closed issues and merged pull/merge requests of a milestone
+func runNotes(args []string) {
+	var o options
+	var milestone, sections, templateFile, tag, ref, name string
+	var publish bool
+	fs := flag.NewFlagSet("notes", flag.ExitOnError)
+	o.registerFlags(fs)
+	fs.StringVar(&milestone, "milestone", "", "Title of the milestone to write release notes for")
+	fs.StringVar(&sections, "sections", utils.DefaultNoteSections, "Sections grouping items by label as Title=label,label;Title=label")
+	fs.StringVar(&templateFile, "template", "", "Go text/template file to render the release notes with, defaults to a Markdown list per section")
+	fs.BoolVar(&publish, "publish", false, "Publish the release notes as GitHub or GitLab release")
+	fs.StringVar(&tag, "tag", "", "Tag of the published release, defaults to the milestone title")
+	fs.StringVar(&ref, "ref", "", "Branch or commit to create the tag of the published release from if it does not exist")
+	fs.StringVar(&name, "release-name", "", "Name of the published release, defaults to the milestone title")
+	fs.Parse(args)
+
+	if milestone == "" {
+		logger.Fatal("Error: -milestone is required to write release notes")
+	}
+	if err := utils.ValidateOutputFormat(o.output); err != nil {
+		logger.Fatal(err)
+	}
+	noteSections, err := utils.ParseNoteSections(sections)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	tmpl := utils.DefaultNotesTemplate
+	if templateFile != "" {
+		content, err := ioutil.ReadFile(templateFile)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		tmpl = string(content)
+	}
+	if tag == "" {
+		tag = milestone
+	}
+	if name == "" {
+		name = milestone
+	}
+
+	if err := o.resolveToken(); err != nil {
+		logger.Fatal(err)
+	}
+	ctx, cancel := o.runContext()
+	defer cancel()
+	t, err := connect(ctx, o)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	milestones, err := getAllMilestones(ctx, t, o.token)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	m, ok := milestones[milestone]
+	if !ok {
+		logger.Fatal(fmt.Errorf("Error: milestone %s not found", milestone))
+	}
+
+	var items []utils.Issue
+	switch t.api {
+	case "gitlab":
+		items, err = gitlab.GetReleaseItems(ctx, t.baseURL, o.token, t.project, m)
+	case "github":
+		items, err = github.GetReleaseItems(ctx, t.baseURL, o.token, t.project, m)
+	}
+	if err != nil {
+		logger.Fatal(err)
+	}
+	notes := utils.NewNotes(m, items, noteSections)
+	err = utils.RenderNotes(&notes, tmpl)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if publish {
+		switch t.api {
+		case "gitlab":
+			notes.ReleaseURL, err = gitlab.PublishRelease(ctx, t.baseURL, o.token, t.project, tag, ref, name, notes.Markdown)
+		case "github":
+			notes.ReleaseURL, err = github.PublishRelease(ctx, t.baseURL, o.token, t.project, tag, ref, name, notes.Markdown)
+		}
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Printf("Published release %s: %s", tag, notes.ReleaseURL)
+	}
+
+	err = utils.WriteNotes(os.Stdout, notes, o.output)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
@@ -0,0 +1,299 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	github "go.okkur.org/gomiler/github"
+	gitlab "go.okkur.org/gomiler/gitlab"
+	"go.okkur.org/gomiler/utils"
+)
+
+// runOptions of the milestone schedule and its maintenance, used by the default run and plan
+type runOptions struct {
+	advance             int
+	description         string
+	drift               string
+	reactivate          string
+	closeExpired        bool
+	closeGrace          int
+	rollover            bool
+	rolloverComment     string
+	rolloverLabel       string
+	retention           int
+	pruneMode           string
+	closeWithOpenIssues bool
+	// Only registered by the default run
+	selection selection
+}
+
+func (r *runOptions) registerFlags(fs *flag.FlagSet) {
+	fs.IntVar(&r.advance, "advance", 30, "Define timeframe to generate milestones in advance")
+	fs.StringVar(&r.description, "description", "", "Description of generated milestones")
+	fs.StringVar(&r.drift, "drift", "fix", "Set handling of milestones whose dates or description differ from the schedule to fix, report or ignore")
+	fs.StringVar(&r.reactivate, "reactivate", "always", "Set reactivation of closed milestones to always, never, only-if-future-due or only-if-empty")
+	fs.BoolVar(&r.closeExpired, "close-expired", false, "Close generated milestones whose due date has passed")
+	fs.IntVar(&r.closeGrace, "close-grace", 0, "Days to wait after the due date before closing or rolling over a milestone")
+	fs.BoolVar(&r.rollover, "rollover", false, "Move open issues and merge/pull requests of expired milestones to the next milestone")
+	fs.StringVar(&r.rolloverComment, "rollover-comment", "", "Comment to add to rolled over issues and merge/pull requests")
+	fs.StringVar(&r.rolloverLabel, "rollover-label", "", "Label to add to rolled over issues and merge/pull requests")
+	fs.IntVar(&r.retention, "retention", 0, "Prune empty generated milestones whose due date passed more than this many days ago, 0 disables pruning")
+	fs.StringVar(&r.pruneMode, "prune-mode", "delete", "Set pruning to delete or close milestones")
+	fs.BoolVar(&r.closeWithOpenIssues, "close-with-open-issues", false, "Close expired milestones even if they still have open issues")
+}
+
+// validate checks the modes and policies of the options
+func (r runOptions) validate() error {
+	if r.pruneMode != "delete" && r.pruneMode != "close" {
+		return fmt.Errorf("Error: Invalid prune mode %s", r.pruneMode)
+	}
+	if r.drift != "fix" && r.drift != "report" && r.drift != "ignore" {
+		return fmt.Errorf("Error: Invalid drift mode %s", r.drift)
+	}
+	return utils.ValidateReactivationPolicy(r.reactivate)
+}
+
+// runPlan prints the actions a run would apply and optionally saves them to apply later
+func runPlan(args []string) {
+	var o options
+	var r runOptions
+	var out string
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	o.registerFlags(fs)
+	r.registerFlags(fs)
+	fs.StringVar(&out, "out", "", "Save the plan to this file to apply it later")
+	fs.Parse(args)
+
+	if err := r.validate(); err != nil {
+		logger.Fatal(err)
+	}
+	if err := utils.ValidateOutputFormat(o.output); err != nil {
+		logger.Fatal(err)
+	}
+	if _, err := o.format(); err != nil {
+		logger.Fatal(err)
+	}
+	if err := o.resolveToken(); err != nil {
+		logger.Fatal(err)
+	}
+	ctx, cancel := o.runContext()
+	defer cancel()
+	t, err := connect(ctx, o)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	plan, err := makePlan(ctx, t, o, r)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		defer f.Close()
+		err = utils.SavePlan(f, plan)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Printf("Plan saved to %s", out)
+	}
+	err = utils.WritePlan(os.Stdout, plan, o.output)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// makePlan computes the actions to bring the milestones of the target in line with the schedule without writing
+func makePlan(ctx context.Context, t target, o options, r runOptions) (utils.Plan, error) {
+	allMilestones, err := getAllMilestones(ctx, t, o.token)
+	if err != nil {
+		return utils.Plan{}, err
+	}
+	plan := utils.NewPlan(t.api, t.baseURL, t.project, allMilestones, time.Now())
+
+	milestoneData, err := utils.CreateMilestoneDataWithFormat(r.advance, o.interval, o.titleFormat, logger, t.api)
+	if err != nil {
+		return plan, err
+	}
+	setDescription(milestoneData, r.description)
+
+	newMilestones := map[string]utils.Milestone{}
+	for k, v := range milestoneData {
+		if _, ok := allMilestones[k]; !ok {
+			newMilestones[k] = v
+		}
+	}
+	plan.Add(utils.ActionCreate, newMilestones)
+
+	if r.drift != "ignore" {
+		drifts, err := getDriftedMilestones(ctx, t, o.token, milestoneData)
+		if err != nil {
+			return plan, err
+		}
+		if r.drift == "fix" {
+			for _, d := range drifts {
+				desired := d.Desired
+				plan.Actions = append(plan.Actions, utils.Action{Kind: utils.ActionUpdate, Milestone: d.Existing, Desired: &desired, Fields: d.Fields})
+			}
+		} else {
+			plan.Drifted = driftResults(drifts)
+		}
+	}
+
+	closedMilestones, err := getClosedMilestones(ctx, t, o.token, milestoneData)
+	if err != nil {
+		return plan, err
+	}
+	reactivatableMilestones, skippedMilestones, err := getReactivatableMilestones(ctx, t, o.token, closedMilestones, r.reactivate)
+	if err != nil {
+		return plan, err
+	}
+	plan.Add(utils.ActionReopen, reactivatableMilestones)
+	plan.Skipped = utils.ReasonResults(skippedMilestones, closedMilestones)
+
+	closing := map[string]utils.Milestone{}
+	if r.closeExpired || r.rollover {
+		expiredMilestones, err := getExpiredMilestones(ctx, t, o.token, o.titleFormat, r.closeGrace, r.closeWithOpenIssues || r.rollover)
+		if err != nil {
+			return plan, err
+		}
+		if r.rollover && len(expiredMilestones) > 0 {
+			next := nextMilestoneTitle(milestoneData, allMilestones, newMilestones, reactivatableMilestones)
+			if next == "" {
+				return plan, fmt.Errorf("no active milestone to roll over to")
+			}
+			for _, m := range utils.MilestoneResults(expiredMilestones) {
+				plan.Actions = append(plan.Actions, utils.Action{
+					Kind:      utils.ActionRollover,
+					Milestone: expiredMilestones[m.Title],
+					Target:    next,
+					Comment:   r.rolloverComment,
+					Label:     r.rolloverLabel,
+				})
+			}
+		}
+		if r.closeExpired {
+			closing = expiredMilestones
+		}
+	}
+
+	deleting := map[string]utils.Milestone{}
+	if r.retention > 0 {
+		prunableMilestones, err := getPrunableMilestones(ctx, t, o.token, o.titleFormat, r.retention)
+		if err != nil {
+			return plan, err
+		}
+		if r.pruneMode == "close" {
+			for k, v := range openMilestones(prunableMilestones) {
+				closing[k] = v
+			}
+		} else {
+			deleting = prunableMilestones
+		}
+	}
+	plan.Add(utils.ActionClose, closing)
+	plan.Add(utils.ActionDelete, deleting)
+
+	return plan, nil
+}
+
+// nextMilestoneTitle returns the title of the earliest scheduled milestone not due yet that is open after applying,
+// being open already and managed by gomiler, created or reopened
+func nextMilestoneTitle(milestoneData, allMilestones, created, reopened map[string]utils.Milestone) string {
+	now := time.Now()
+	var keys []string
+	for k, v := range milestoneData {
+		if !utils.DueDatePassed(v.DueDate, 0, now) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, _ := utils.ParseDueDate(milestoneData[keys[i]].DueDate)
+		b, _ := utils.ParseDueDate(milestoneData[keys[j]].DueDate)
+		return a.Before(b)
+	})
+	for _, k := range keys {
+		m, exists := allMilestones[k]
+		_, isCreated := created[k]
+		_, isReopened := reopened[k]
+		if (exists && m.State != "closed" && utils.IsManaged(m.Description)) || isCreated || isReopened {
+			return k
+		}
+	}
+	return ""
+}
+
+// getDriftedMilestones gets the managed milestones of the target differing from the schedule
+func getDriftedMilestones(ctx context.Context, t target, token string, milestoneData map[string]utils.Milestone) ([]utils.Drift, error) {
+	switch t.api {
+	case "gitlab":
+		return gitlab.GetDriftedMilestones(ctx, t.baseURL, token, t.project, milestoneData)
+	case "github":
+		return github.GetDriftedMilestones(ctx, t.baseURL, token, t.project, milestoneData)
+	}
+	return nil, nil
+}
+
+// getClosedMilestones gets the closed managed milestones of the target that are in the schedule
+func getClosedMilestones(ctx context.Context, t target, token string, milestoneData map[string]utils.Milestone) (map[string]utils.Milestone, error) {
+	switch t.api {
+	case "gitlab":
+		return gitlab.GetClosedMilestones(ctx, t.baseURL, token, t.project, milestoneData)
+	case "github":
+		return github.GetClosedMilestones(ctx, t.baseURL, token, t.project, milestoneData)
+	}
+	return nil, nil
+}
+
+// getReactivatableMilestones splits closed milestones of the target by reactivation policy
+func getReactivatableMilestones(ctx context.Context, t target, token string, milestones map[string]utils.Milestone, policy string) (map[string]utils.Milestone, map[string]string, error) {
+	switch t.api {
+	case "gitlab":
+		return gitlab.GetReactivatableMilestones(ctx, milestones, t.baseURL, token, t.project, policy)
+	case "github":
+		return github.GetReactivatableMilestones(milestones, policy)
+	}
+	return nil, nil, nil
+}
+
+// getExpiredMilestones gets the managed milestones of the target whose due date has passed
+func getExpiredMilestones(ctx context.Context, t target, token string, titleFormat string, grace int, allowOpenIssues bool) (map[string]utils.Milestone, error) {
+	switch t.api {
+	case "gitlab":
+		return gitlab.GetExpiredMilestones(ctx, t.baseURL, token, t.project, titleFormat, grace, allowOpenIssues)
+	case "github":
+		return github.GetExpiredMilestones(ctx, t.baseURL, token, t.project, titleFormat, grace, allowOpenIssues)
+	}
+	return nil, nil
+}
+
+// getPrunableMilestones gets the empty managed milestones of the target past retention
+func getPrunableMilestones(ctx context.Context, t target, token string, titleFormat string, retention int) (map[string]utils.Milestone, error) {
+	switch t.api {
+	case "gitlab":
+		return gitlab.GetPrunableMilestones(ctx, t.baseURL, token, t.project, titleFormat, retention)
+	case "github":
+		return github.GetPrunableMilestones(ctx, t.baseURL, token, t.project, titleFormat, retention)
+	}
+	return nil, nil
+}
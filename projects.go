@@ -0,0 +1,196 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	github "go.okkur.org/gomiler/github"
+	gitlab "go.okkur.org/gomiler/gitlab"
+	"go.okkur.org/gomiler/utils"
+)
+
+// selection of the projects of a namespace a run without -project applies to
+type selection struct {
+	include  string
+	exclude  string
+	topics   string
+	archived bool
+}
+
+func (s *selection) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.include, "include", "", "Comma separated globs of projects to run against when no project is set, e.g. subgroup/*")
+	fs.StringVar(&s.exclude, "exclude", "", "Comma separated globs of projects to skip when no project is set")
+	fs.StringVar(&s.topics, "topic", "", "Comma separated topics, projects need one of them when no project is set")
+	fs.BoolVar(&s.archived, "archived", false, "Include archived projects when no project is set")
+}
+
+// filter returns the validated repository filter of the selection
+func (s selection) filter() (utils.RepositoryFilter, error) {
+	f := utils.RepositoryFilter{
+		Include:  utils.ParseList(s.include),
+		Exclude:  utils.ParseList(s.exclude),
+		Topics:   utils.ParseList(s.topics),
+		Archived: s.archived,
+	}
+	return f, f.Validate()
+}
+
+// projectRun is the run of a single project.
+// Its target is resolved when running unless it was listed from a namespace.
+type projectRun struct {
+	name string
+	o    options
+	r    runOptions
+	t    *target
+	// Error of listing the projects of a namespace, failing the run
+	err error
+}
+
+func (p projectRun) run(ctx context.Context) (utils.Result, error) {
+	if p.err != nil {
+		return utils.Result{}, p.err
+	}
+	ctx = p.o.apiContext(ctx)
+	if p.t == nil {
+		return run(ctx, p.o, p.r)
+	}
+	return runTarget(ctx, *p.t, p.o, p.r)
+}
+
+// target returns the target listed from the namespace or resolves the project
+func (p projectRun) target(ctx context.Context) (target, error) {
+	if p.err != nil {
+		return target{}, p.err
+	}
+	if p.t == nil {
+		return connect(p.o.apiContext(ctx), p.o)
+	}
+	return *p.t, nil
+}
+
+// expandProjects returns the run of the project of the options or,
+// if no project is set, the runs of all selected projects of the namespace
+func expandProjects(ctx context.Context, o options, r runOptions) ([]projectRun, error) {
+	if o.project != "" {
+		return []projectRun{{name: o.namespace + "/" + o.project, o: o, r: r}}, nil
+	}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := o.format(); err != nil {
+		return nil, err
+	}
+	f, err := r.selection.filter()
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, required := range []struct{ name, value string }{
+		{"-url", o.baseURL},
+		{"-token", o.credential()},
+		{"-namespace", o.namespace},
+	} {
+		if required.value == "" {
+			missing = append(missing, required.name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("Error: %s required", strings.Join(missing, ", "))
+	}
+
+	URL, err := o.apiURL()
+	if err != nil {
+		return nil, err
+	}
+	ctx = o.apiContext(ctx)
+	api, err := checkAPI(ctx, URL, o.token, o.namespace, "")
+	if err != nil {
+		return nil, err
+	}
+	var repositories []utils.Repository
+	switch api {
+	case "gitlab":
+		repositories, err = gitlab.ListProjects(ctx, URL+"/api/v4", o.token, o.namespace)
+	case "github":
+		repositories, err = github.ListRepositories(ctx, URL, o.token, o.namespace)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []projectRun{}
+	for _, repository := range utils.FilterRepositories(repositories, f) {
+		t := target{api: api, project: repository.ID}
+		switch api {
+		case "gitlab":
+			t.baseURL = URL + "/api/v4"
+		case "github":
+			t.baseURL = URL + "/repos/" + o.namespace + "/"
+		}
+		p := projectRun{name: o.namespace + "/" + repository.Path, o: o, r: r, t: &t}
+		p.o.project = repository.Path
+		runs = append(runs, p)
+	}
+	if len(runs) == 0 {
+		logger.Printf("No projects of %s selected", o.namespace)
+	}
+	return runs, nil
+}
+
+// reconcile runs every project, a failing project is reported and does not stop the others.
+// Once ctx is done the remaining projects are reported as not started.
+// It returns the summary of each project and the number of failed projects.
+func reconcile(ctx context.Context, runs []projectRun) ([]utils.Result, int) {
+	var results []utils.Result
+	failed := 0
+	for _, p := range runs {
+		if err := ctx.Err(); err != nil {
+			results = append(results, utils.Result{Project: p.name, Error: fmt.Sprintf("not started: %v", err)})
+			failed++
+			continue
+		}
+		start := utils.DefaultClient.Stats()
+		result, err := p.run(ctx)
+		result.Project = p.name
+		result.Throttled = utils.DefaultClient.Stats().Since(start)
+		if err != nil {
+			logger.Printf("project %s: %v", result.Project, err)
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+	return results, failed
+}
+
+// runProjects runs every project and writes a summary of each, exiting with an error if any project failed
+func runProjects(ctx context.Context, runs []projectRun, output string) {
+	results, failed := reconcile(ctx, runs)
+	err := utils.WriteResults(os.Stdout, results, output)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if err := ctx.Err(); err != nil {
+		logger.Fatalf("Error: %v, %d of %d projects did not complete", err, failed, len(runs))
+	}
+	if failed > 0 {
+		logger.Fatalf("Error: %d of %d projects failed", failed, len(runs))
+	}
+}
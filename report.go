@@ -0,0 +1,137 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"time"
+
+	github "go.okkur.org/gomiler/github"
+	gitlab "go.okkur.org/gomiler/gitlab"
+	"go.okkur.org/gomiler/utils"
+)
+
+// runReport prints the progress of the current and recent milestones and renders a burndown chart
+func runReport(args []string) {
+	var o options
+	var recent int
+	var burndown string
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	o.registerFlags(fs)
+	fs.IntVar(&recent, "recent", 3, "Number of past milestones to report besides the current one")
+	fs.StringVar(&burndown, "burndown", "", "Write a burndown chart of the current milestone to this SVG file")
+	fs.Parse(args)
+
+	if err := utils.ValidateOutputFormat(o.output); err != nil {
+		logger.Fatal(err)
+	}
+	titleFormat, err := o.format()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if err := o.resolveToken(); err != nil {
+		logger.Fatal(err)
+	}
+	ctx, cancel := o.runContext()
+	defer cancel()
+	t, err := connect(ctx, o)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	milestones, err := getReportMilestones(ctx, t, o.token, titleFormat, recent)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	now := time.Now()
+	if len(milestones) == 0 {
+		logger.Println("No milestones to report")
+	} else {
+		writeResult(utils.Result{Progress: progress(milestones, now)}, o.output)
+	}
+
+	if burndown != "" && len(milestones) > 0 {
+		f, err := os.Create(burndown)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		defer f.Close()
+		current := milestones[len(milestones)-1]
+		err = utils.RenderBurndown(f, current, now)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Printf("Burndown of %s written to %s", current.Title, burndown)
+	}
+}
+
+// getReportMilestones gets the current and recent milestones with their issues
+func getReportMilestones(ctx context.Context, t target, token string, titleFormat string, recent int) ([]utils.Milestone, error) {
+	allMilestones, err := getAllMilestones(ctx, t, token)
+	if err != nil {
+		return nil, err
+	}
+	milestones := utils.SelectReportMilestones(allMilestones, titleFormat, recent, time.Now())
+	return milestones, setMilestoneIssues(ctx, t, token, milestones)
+}
+
+// getAllMilestones gets all milestones of the target
+func getAllMilestones(ctx context.Context, t target, token string) (map[string]utils.Milestone, error) {
+	switch t.api {
+	case "gitlab":
+		return gitlab.GetAllMilestones(ctx, t.baseURL, token, t.project)
+	case "github":
+		return github.GetAllMilestones(ctx, t.baseURL, token, t.project)
+	}
+	return nil, nil
+}
+
+// setMilestoneIssues gets and sets the issues of milestones
+func setMilestoneIssues(ctx context.Context, t target, token string, milestones []utils.Milestone) error {
+	for i, m := range milestones {
+		var issues []utils.Issue
+		var err error
+		switch t.api {
+		case "gitlab":
+			issues, err = gitlab.GetMilestoneIssues(ctx, t.baseURL, token, t.project, m)
+		case "github":
+			issues, err = github.GetMilestoneIssues(ctx, t.baseURL, token, t.project, m)
+		}
+		if err != nil {
+			return err
+		}
+		milestones[i].SetIssues(issues)
+	}
+	return nil
+}
+
+// progress returns the progress of milestones
+func progress(milestones []utils.Milestone, now time.Time) []utils.Progress {
+	progress := []utils.Progress{}
+	for _, m := range milestones {
+		progress = append(progress, utils.Progress{
+			Title:           m.Title,
+			URL:             m.URL,
+			DueDate:         m.DueDate,
+			OpenIssues:      m.OpenIssues,
+			ClosedIssues:    m.ClosedIssues,
+			PercentComplete: m.PercentComplete(),
+			DaysRemaining:   m.DaysRemaining(now),
+		})
+	}
+	return progress
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.okkur.org/gomiler/utils"
+)
+
+// daemon reconciles the projects of a configuration file on a schedule
+type daemon struct {
+	configFile string
+	// Flags given on the command line, overriding the configured settings
+	args   []string
+	output string
+	// Bearer token of the REST API, which is disabled without it
+	apiToken string
+	// Token of the calendar feeds in their URLs, which are disabled without it
+	calendarToken string
+	// Cancelled on shutdown, stopping passes and reconciliations in the background
+	ctx context.Context
+	// Limit of a pass or reconciliation, none if zero
+	timeout time.Duration
+
+	mu     sync.Mutex
+	config utils.Config
+	// Ready once the configuration is loaded and the first pass was attempted
+	ready bool
+	// Error of the last pass that could not reconcile the projects, nil if it could
+	passErr error
+	// Projects with a webhook reconciliation waiting for the running pass
+	queued map[string]bool
+	// Serializes passes
+	passMu sync.Mutex
+	// Most recent runs, oldest first
+	history []runRecord
+}
+
+// maxHistory is the number of runs kept in the history
+const maxHistory = 100
+
+// runRecord is the result of reconciling a project
+type runRecord struct {
+	Time time.Time `json:"time"`
+	// What started the run, schedule, webhook or api
+	Trigger string       `json:"trigger"`
+	Result  utils.Result `json:"result"`
+}
+
+// record adds the results of a run to the history
+func (d *daemon) record(trigger string, results []utils.Result) {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, result := range results {
+		d.history = append(d.history, runRecord{Time: now, Trigger: trigger, Result: result})
+	}
+	if len(d.history) > maxHistory {
+		d.history = append([]runRecord{}, d.history[len(d.history)-maxHistory:]...)
+	}
+}
+
+// load reads the configuration file and checks the settings of all projects.
+// An invalid configuration is an error and keeps the previous one.
+func (d *daemon) load() error {
+	c, err := utils.LoadConfigFile(d.configFile)
+	if err != nil {
+		return err
+	}
+	for _, p := range c.Projects {
+		if _, _, err := projectOptions(c, p, d.args); err != nil {
+			return err
+		}
+	}
+	d.mu.Lock()
+	d.config = c
+	d.mu.Unlock()
+	return nil
+}
+
+// passContext returns the context of a pass or reconciliation, limited by the timeout of the daemon
+func (d *daemon) passContext() (context.Context, context.CancelFunc) {
+	if d.timeout > 0 {
+		return context.WithTimeout(d.ctx, d.timeout)
+	}
+	return context.WithCancel(d.ctx)
+}
+
+// pass reconciles all configured projects and writes their summaries
+func (d *daemon) pass(ctx context.Context) {
+	d.passMu.Lock()
+	defer d.passMu.Unlock()
+	d.mu.Lock()
+	c := d.config
+	d.mu.Unlock()
+
+	runs, err := configRuns(ctx, c, d.args)
+	d.mu.Lock()
+	d.ready = true
+	d.passErr = err
+	d.mu.Unlock()
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	results, failed := reconcile(ctx, runs)
+	d.record("schedule", results)
+	err = utils.WriteResults(os.Stdout, results, d.output)
+	if err != nil {
+		logger.Println(err)
+	}
+	logger.Printf("Reconciled %d projects, %d failed", len(runs), failed)
+}
+
+func (d *daemon) healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// isReady reports whether the configuration is loaded and the first pass was attempted
+func (d *daemon) isReady() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.ready
+}
+
+func (d *daemon) readyz(w http.ResponseWriter, r *http.Request) {
+	if !d.isReady() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	d.mu.Lock()
+	err := d.passErr
+	d.mu.Unlock()
+	if err != nil {
+		fmt.Fprintf(w, "ok, last pass failed: %v\n", err)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// projectRuns returns the runs of the configured projects of the providers with the path.
+// Namespaces are only listed if the path is below them.
+func projectRuns(ctx context.Context, c utils.Config, args []string, providers map[string]bool, path string) ([]projectRun, error) {
+	var runs []projectRun
+	for _, p := range c.Projects {
+		if !providers[p.Provider] {
+			continue
+		}
+		if p.Project != "" && p.Name() != path {
+			continue
+		}
+		if p.Project == "" && !strings.HasPrefix(path, p.Namespace+"/") {
+			continue
+		}
+		o, r, err := projectOptions(c, p, args)
+		if err != nil {
+			return nil, err
+		}
+		expanded, err := expandProjects(ctx, o, r)
+		if err != nil {
+			return nil, fmt.Errorf("project %s: %w", p.Name(), err)
+		}
+		for _, run := range expanded {
+			if run.name == path {
+				runs = append(runs, run)
+			}
+		}
+	}
+	return runs, nil
+}
+
+// handler returns the HTTP endpoints of the daemon
+func (d *daemon) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", d.healthz)
+	mux.HandleFunc("/readyz", d.readyz)
+	mux.HandleFunc("/webhooks/github", d.webhook("github"))
+	mux.HandleFunc("/webhooks/gitlab", d.webhook("gitlab"))
+	if d.apiToken != "" {
+		d.registerAPI(mux)
+	}
+	if d.calendarToken != "" {
+		mux.HandleFunc("/calendars/", d.feedAuthorized(d.calendar))
+	}
+	return mux
+}
+
+// runServe reconciles the configured projects on a schedule until terminated.
+// SIGHUP reloads the configuration, SIGTERM and SIGINT cancel the running pass and stop once it returned.
+func runServe(args []string) {
+	var o options
+	var r runOptions
+	d := &daemon{}
+	var spec, listen string
+	fs := newRunFlagSet("serve", &o, &r, &d.configFile, flag.ExitOnError)
+	fs.StringVar(&spec, "schedule", "@every 1h", "Interval like @every 1h or cron spec like 0 6 * * 1-5 of reconciliation passes")
+	fs.StringVar(&listen, "listen", ":8080", "Address serving /healthz, /readyz and the webhooks /webhooks/github and /webhooks/gitlab")
+	fs.StringVar(&d.apiToken, "api-token", "", "Bearer token of the REST API under /api/v1, defaults to $GOMILER_API_TOKEN, the API is disabled without it")
+	fs.StringVar(&d.calendarToken, "calendar-token", "", "Token of the calendar feeds under /calendars, passed as ?token= by calendar apps, defaults to $GOMILER_CALENDAR_TOKEN, the feeds are disabled without it")
+	fs.Parse(args)
+
+	if d.configFile == "" {
+		logger.Fatal("Error: -config is required to serve")
+	}
+	if d.apiToken == "" {
+		d.apiToken = os.Getenv("GOMILER_API_TOKEN")
+	}
+	if d.calendarToken == "" {
+		d.calendarToken = os.Getenv("GOMILER_CALENDAR_TOKEN")
+	}
+	if err := utils.ValidateOutputFormat(o.output); err != nil {
+		logger.Fatal(err)
+	}
+	d.output = o.output
+	d.timeout = o.timeout
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "config", "schedule", "listen", "api-token", "calendar-token":
+		default:
+			d.args = append(d.args, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	schedule, err := utils.ParseSchedule(spec)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if err := d.load(); err != nil {
+		logger.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d.ctx = ctx
+	server := &http.Server{Addr: listen, Handler: d.handler()}
+	go func() {
+		logger.Printf("Serving health checks and webhooks on %s", listen)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal(err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM, os.Interrupt)
+	passed := make(chan struct{}, 1)
+	timer := time.NewTimer(0)
+	for {
+		select {
+		case <-timer.C:
+			go func() {
+				ctx, cancel := d.passContext()
+				defer cancel()
+				d.pass(ctx)
+				passed <- struct{}{}
+			}()
+		case <-passed:
+			next := schedule.Next(time.Now())
+			if next.IsZero() {
+				logger.Fatalf("Error: schedule %q has no next run", spec)
+			}
+			logger.Printf("Next pass at %s", next.Format(time.RFC3339))
+			timer.Reset(time.Until(next))
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				if err := d.load(); err != nil {
+					logger.Printf("Keeping the previous configuration: %v", err)
+					continue
+				}
+				logger.Printf("Reloaded %s", d.configFile)
+				continue
+			}
+			// A second signal exits at once
+			signal.Stop(signals)
+			logger.Printf("Received %s, cancelling running reconciliations and shutting down", sig)
+			cancel()
+			shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancelShutdown()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				logger.Println(err)
+			}
+			// Wait for the cancelled pass to write its summary
+			d.passMu.Lock()
+			return
+		}
+	}
+}
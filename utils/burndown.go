@@ -0,0 +1,140 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+// Burndown chart dimensions in pixels
+const (
+	burndownWidth  = 640
+	burndownHeight = 320
+	burndownMargin = 40
+)
+
+// Burndown returns the first day of the milestone and the number of open issues at the end of each day
+// from that day until the due date or today, whichever comes first
+func Burndown(m Milestone, now time.Time) (time.Time, []int, error) {
+	due, err := ParseDueDate(m.DueDate)
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("milestone %s has no valid due date", m.Title)
+	}
+	start, err := ParseDueDate(m.StartDate)
+	if err != nil {
+		// Fall back to the creation of the first issue or a week before the due date
+		start = due.AddDate(0, 0, -6)
+		for _, issue := range m.Issues {
+			if issue.CreatedAt == nil {
+				continue
+			}
+			created := time.Date(issue.CreatedAt.Year(), issue.CreatedAt.Month(), issue.CreatedAt.Day(), 0, 0, 0, 0, time.Local)
+			if created.Before(start) {
+				start = created
+			}
+		}
+	}
+	if start.After(due) {
+		start = due
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	open := []int{}
+	for day := start; !day.After(due) && !day.After(today); day = day.AddDate(0, 0, 1) {
+		endOfDay := day.AddDate(0, 0, 1)
+		count := 0
+		for _, issue := range m.Issues {
+			if issue.CreatedAt != nil && !issue.CreatedAt.Before(endOfDay) {
+				continue
+			}
+			if issue.ClosedAt != nil && issue.ClosedAt.Before(endOfDay) {
+				continue
+			}
+			count++
+		}
+		open = append(open, count)
+	}
+	return start, open, nil
+}
+
+// RenderBurndown writes an SVG burndown chart of the open issues of a milestone
+func RenderBurndown(w io.Writer, m Milestone, now time.Time) error {
+	start, open, err := Burndown(m, now)
+	if err != nil {
+		return err
+	}
+	due, _ := ParseDueDate(m.DueDate)
+	days := int(due.Sub(start).Hours()/24) + 1
+
+	max := len(m.Issues)
+	for _, v := range open {
+		if v > max {
+			max = v
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+	plotWidth := float64(burndownWidth - 2*burndownMargin)
+	plotHeight := float64(burndownHeight - 2*burndownMargin)
+	x := func(day int) float64 {
+		if days < 2 {
+			return burndownMargin
+		}
+		return burndownMargin + float64(day)*plotWidth/float64(days-1)
+	}
+	y := func(value int) float64 {
+		return burndownMargin + plotHeight - float64(value)*plotHeight/float64(max)
+	}
+
+	var points []string
+	for day, value := range open {
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x(day), y(value)))
+	}
+	ideal := 0
+	if len(open) > 0 {
+		ideal = open[0]
+	}
+
+	_, err = fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">
+<rect width="100%%" height="100%%" fill="white"/>
+<text x="%d" y="%d" font-family="sans-serif" font-size="14">Burndown %s</text>
+<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="black"/>
+<line x1="%d" y1="%d" x2="%d" y2="%.1f" stroke="black"/>
+<text x="%d" y="%.1f" font-family="sans-serif" font-size="10" text-anchor="end">%d</text>
+<text x="%d" y="%.1f" font-family="sans-serif" font-size="10" text-anchor="end">0</text>
+<text x="%d" y="%d" font-family="sans-serif" font-size="10">%s</text>
+<text x="%.1f" y="%d" font-family="sans-serif" font-size="10" text-anchor="end">%s</text>
+<polyline points="%.1f,%.1f %.1f,%.1f" fill="none" stroke="gray" stroke-dasharray="4"/>
+<polyline points="%s" fill="none" stroke="steelblue" stroke-width="2"/>
+</svg>
+`,
+		burndownWidth, burndownHeight, burndownWidth, burndownHeight,
+		burndownMargin, burndownMargin/2, html.EscapeString(m.Title),
+		burndownMargin, y(0), x(days-1), y(0),
+		burndownMargin, burndownMargin, burndownMargin, y(0),
+		burndownMargin-4, y(max)+4, max,
+		burndownMargin-4, y(0)+4,
+		burndownMargin, burndownHeight-burndownMargin/2, start.Format("2006-01-02"),
+		x(days-1), burndownHeight-burndownMargin/2, due.Format("2006-01-02"),
+		x(0), y(ideal), x(days-1), y(0),
+		strings.Join(points, " "),
+	)
+	return err
+}
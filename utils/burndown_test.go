@@ -0,0 +1,79 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mockBurndownMilestone(now time.Time) Milestone {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -4)
+	created := start.Add(time.Hour)
+	closedFirst := start.AddDate(0, 0, 1).Add(time.Hour)
+	closedSecond := start.AddDate(0, 0, 3).Add(time.Hour)
+	var m Milestone
+	m.Title = "2017-w9"
+	m.StartDate = start.Format("2006-01-02")
+	m.DueDate = start.AddDate(0, 0, 6).Format("2006-01-02")
+	m.SetIssues([]Issue{
+		{Number: 1, State: "closed", CreatedAt: &created, ClosedAt: &closedFirst},
+		{Number: 2, State: "closed", CreatedAt: &created, ClosedAt: &closedSecond},
+		{Number: 3, State: "open", CreatedAt: &created},
+	})
+	return m
+}
+
+func TestBurndown(t *testing.T) {
+	now := time.Now().Local()
+	m := mockBurndownMilestone(now)
+	_, open, err := Burndown(m, now)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []int{3, 2, 2, 1, 1}
+	if len(open) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, open)
+	}
+	for i := range expected {
+		if open[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, open)
+			break
+		}
+	}
+}
+
+func TestRenderBurndown(t *testing.T) {
+	now := time.Now().Local()
+	m := mockBurndownMilestone(now)
+	var buf bytes.Buffer
+	err := RenderBurndown(&buf, m, now)
+	if err != nil {
+		t.Error(err)
+	}
+	if !strings.HasPrefix(buf.String(), "<svg") || !strings.Contains(buf.String(), "polyline") {
+		t.Errorf("Expected an SVG burndown chart, got %s", buf.String())
+	}
+}
+
+func TestRenderBurndownWithoutDueDate(t *testing.T) {
+	var buf bytes.Buffer
+	err := RenderBurndown(&buf, Milestone{Title: "backlog"}, time.Now())
+	if err == nil {
+		t.Errorf("Expected to get an error when due date is missing")
+	}
+}
@@ -0,0 +1,356 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client sends the requests to the GitLab and GitHub APIs.
+// It waits for exhausted rate limit windows and retries failed requests with jittered exponential backoff.
+type Client struct {
+	HTTPClient *http.Client
+	// Retries of a request after the first attempt
+	MaxRetries int
+	// Delay before the first retry, doubled with every further retry
+	Backoff time.Duration
+	// Longest wait for a rate limit window, a request limited for longer fails
+	MaxWait time.Duration
+	// Timeout of a single attempt of a request, without limit if zero
+	Timeout time.Duration
+	// Pause between writes to GitHub, which are sent one at a time as GitHub asks to avoid secondary rate limits
+	GithubWriteInterval time.Duration
+
+	sleep func(ctx context.Context, d time.Duration) error
+	mu    sync.Mutex
+	rng   *rand.Rand
+	stats ClientStats
+	// End of the exhausted rate limit window by host
+	resets map[string]time.Time
+	// Clients of the hosts with custom transport options
+	transports map[string]hostTransport
+	// Sources of the tokens of requests by URL prefix
+	tokens    map[string]TokenSource
+	writeMu   sync.Mutex
+	lastWrite time.Time
+}
+
+// ClientStats counts the throttling of requests
+type ClientStats struct {
+	Retries     int
+	RateLimited int
+	Waited      time.Duration
+}
+
+// Throttling is the throttling of the requests of a run
+type Throttling struct {
+	Retries     int    `json:"retries,omitempty" yaml:"retries,omitempty"`
+	RateLimited int    `json:"rate_limited,omitempty" yaml:"rate_limited,omitempty"`
+	Waited      string `json:"waited,omitempty" yaml:"waited,omitempty"`
+}
+
+func (t Throttling) String() string {
+	return fmt.Sprintf("%d retries, %d rate limit waits, waited %s", t.Retries, t.RateLimited, t.Waited)
+}
+
+// Since returns the throttling after prev, nil if there was none
+func (s ClientStats) Since(prev ClientStats) *Throttling {
+	if s == prev {
+		return nil
+	}
+	return &Throttling{
+		Retries:     s.Retries - prev.Retries,
+		RateLimited: s.RateLimited - prev.RateLimited,
+		Waited:      (s.Waited - prev.Waited).Round(time.Second).String(),
+	}
+}
+
+// DefaultRequestTimeout is the timeout of a single attempt of a request unless the context sets another
+const DefaultRequestTimeout = 30 * time.Second
+
+type requestTimeoutKey struct{}
+
+// WithRequestTimeout returns a context limiting each attempt of a request to d instead of the timeout of the client,
+// without limit if zero
+func WithRequestTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, requestTimeoutKey{}, d)
+}
+
+// NewClient returns a client with the default limits
+func NewClient() *Client {
+	return &Client{
+		HTTPClient:          &http.Client{},
+		MaxRetries:          3,
+		Backoff:             time.Second,
+		MaxWait:             15 * time.Minute,
+		Timeout:             DefaultRequestTimeout,
+		GithubWriteInterval: time.Second,
+		sleep:               sleep,
+		rng:                 rand.New(rand.NewSource(time.Now().UnixNano())),
+		resets:              map[string]time.Time{},
+		transports:          map[string]hostTransport{},
+		tokens:              map[string]TokenSource{},
+	}
+}
+
+// DefaultClient is used for all API requests
+var DefaultClient = NewClient()
+
+// sleep waits for d unless ctx is done first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the throttling of all requests so far
+func (c *Client) Stats() ClientStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// APIHeader returns the headers authenticating a request to the API of api with the token
+func APIHeader(api string, token string) http.Header {
+	header := http.Header{}
+	if api == "github" {
+		header.Set("Accept", "application/vnd.github.v3+json")
+	}
+	setToken(header, api, token)
+	return header
+}
+
+// setToken authenticates the request with token, the request stays unauthenticated if token is empty
+func setToken(header http.Header, api string, token string) {
+	if token == "" {
+		return
+	}
+	switch api {
+	case "gitlab":
+		header.Set("PRIVATE-TOKEN", token)
+	case "github":
+		header.Set("Authorization", "token "+token)
+	}
+}
+
+// idempotent reports whether a request of the method can be repeated without changing its effect
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// Send sends a request to the API of api, which is gitlab or github, and returns the response with its body read.
+// Requests authenticating a GitHub App use github-app, they are not serialized with the writes to github.
+// Requests rejected by a rate limit are retried after the window, idempotent requests also after network errors and 5xx responses.
+// Every attempt is limited by the timeout of the client, waiting and retrying stop once ctx is done.
+func (c *Client) Send(ctx context.Context, api string, method string, URL string, header http.Header, body []byte) (*http.Response, []byte, error) {
+	host := URL
+	if u, err := url.Parse(URL); err == nil {
+		host = u.Host
+	}
+	if api == "github" && method != "GET" && method != "HEAD" {
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		if wait := time.Until(c.lastWrite.Add(c.GithubWriteInterval)); wait > 0 {
+			if err := c.sleep(ctx, wait); err != nil {
+				return nil, nil, err
+			}
+		}
+		defer func() { c.lastWrite = time.Now() }()
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if err := c.waitForReset(ctx, host); err != nil {
+			return nil, nil, err
+		}
+		attemptHeader, err := c.authorize(ctx, api, URL, header)
+		if err != nil {
+			return nil, nil, err
+		}
+		retry := idempotent(method) && attempt < c.MaxRetries
+		resp, respBody, err := c.do(ctx, method, URL, attemptHeader, body)
+		if err != nil {
+			// A request cancelled by ctx is not retried, one that timed out on its own is
+			if retry && ctx.Err() == nil {
+				if err := c.backoff(ctx, attempt); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			return nil, nil, err
+		}
+		c.observe(host, resp.Header)
+
+		// A rate limited request was not processed and can be sent again
+		if wait, limited := rateLimitWait(resp, respBody, time.Now()); limited {
+			if attempt < c.MaxRetries && wait <= c.MaxWait {
+				c.mu.Lock()
+				c.stats.RateLimited++
+				c.stats.Waited += wait
+				c.mu.Unlock()
+				if err := c.sleep(ctx, wait); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			return resp, respBody, nil
+		}
+		if resp.StatusCode >= 500 && retry {
+			if err := c.backoff(ctx, attempt); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		return resp, respBody, nil
+	}
+}
+
+// do sends a single attempt of a request limited by the request timeout of ctx or the client and reads the response
+func (c *Client) do(ctx context.Context, method string, URL string, header http.Header, body []byte) (*http.Response, []byte, error) {
+	timeout := c.Timeout
+	if d, ok := ctx.Value(requestTimeoutKey{}).(time.Duration); ok {
+		timeout = d
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, URL, bodyReader)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := c.httpClient(req.URL.Host).Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, respBody, nil
+}
+
+// backoff waits before a retry, between half and the full exponential delay of the attempt
+func (c *Client) backoff(ctx context.Context, attempt int) error {
+	d := c.Backoff << uint(attempt)
+	c.mu.Lock()
+	if d > 1 {
+		d = d/2 + time.Duration(c.rng.Int63n(int64(d/2)))
+	}
+	c.stats.Retries++
+	c.stats.Waited += d
+	c.mu.Unlock()
+	return c.sleep(ctx, d)
+}
+
+// observe remembers the end of the rate limit window of a host once it is exhausted
+func (c *Client) observe(host string, header http.Header) {
+	remaining := header.Get("X-RateLimit-Remaining")
+	if remaining == "" {
+		remaining = header.Get("RateLimit-Remaining")
+	}
+	if remaining != "0" {
+		return
+	}
+	if reset, ok := resetTime(header); ok {
+		c.mu.Lock()
+		c.resets[host] = reset
+		c.mu.Unlock()
+	}
+}
+
+// waitForReset waits for the rate limit window of the host if it is exhausted and ends soon enough
+func (c *Client) waitForReset(ctx context.Context, host string) error {
+	c.mu.Lock()
+	wait := time.Until(c.resets[host])
+	if wait <= 0 || wait > c.MaxWait {
+		c.mu.Unlock()
+		return nil
+	}
+	c.stats.RateLimited++
+	c.stats.Waited += wait
+	c.mu.Unlock()
+	return c.sleep(ctx, wait)
+}
+
+// resetTime returns the end of the rate limit window from GitHub's X-RateLimit-Reset or GitLab's RateLimit-Reset header
+func resetTime(header http.Header) (time.Time, bool) {
+	reset := header.Get("X-RateLimit-Reset")
+	if reset == "" {
+		reset = header.Get("RateLimit-Reset")
+	}
+	seconds, err := strconv.ParseInt(reset, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// rateLimitWait reports whether a response was rejected by a rate limit and how long to wait before sending it again.
+// Retry-After is preferred over the end of the window, a secondary rate limit of GitHub without either waits a minute.
+func rateLimitWait(resp *http.Response, body []byte, now time.Time) (time.Duration, bool) {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+	case http.StatusForbidden:
+		if resp.Header.Get("X-RateLimit-Remaining") != "0" && !strings.Contains(strings.ToLower(string(body)), "rate limit") {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if t, err := http.ParseTime(retryAfter); err == nil {
+			return t.Sub(now), true
+		}
+	}
+	if reset, ok := resetTime(resp.Header); ok && reset.After(now) {
+		return reset.Sub(now) + time.Second, true
+	}
+	return time.Minute, true
+}
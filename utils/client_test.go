@@ -0,0 +1,280 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+func TestMain(m *testing.M) {
+	DefaultClient.Backoff = 0
+	os.Exit(m.Run())
+}
+
+// testClient returns a client recording its waits instead of sleeping
+func testClient(waits *[]time.Duration) *Client {
+	c := NewClient()
+	c.GithubWriteInterval = 0
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return ctx.Err()
+	}
+	return c
+}
+
+// mockSequence responds with the statuses in order, repeating the last one
+func mockSequence(method string, URL string, responses ...*http.Response) *int {
+	calls := 0
+	httpmock.RegisterResponder(method, URL, func(req *http.Request) (*http.Response, error) {
+		resp := responses[len(responses)-1]
+		if calls < len(responses) {
+			resp = responses[calls]
+		}
+		calls++
+		return resp, nil
+	})
+	return &calls
+}
+
+func TestClientRetriesIdempotentRequests(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	URL := "https://api.github.com/repos/namespace/project/milestones"
+	calls := mockSequence("GET", URL,
+		httpmock.NewStringResponse(503, "unavailable"),
+		httpmock.NewStringResponse(502, "bad gateway"),
+		httpmock.NewStringResponse(200, "[]"))
+
+	var waits []time.Duration
+	c := testClient(&waits)
+	resp, body, err := c.Send(context.Background(), "github", "GET", URL, APIHeader("github", "token"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || string(body) != "[]" || *calls != 3 {
+		t.Errorf("Expected 200 after 3 calls, got %d after %d", resp.StatusCode, *calls)
+	}
+	if len(waits) != 2 {
+		t.Fatalf("Expected 2 backoffs, got %v", waits)
+	}
+	if waits[0] < time.Second/2 || waits[0] > time.Second || waits[1] < time.Second || waits[1] > 2*time.Second {
+		t.Errorf("Expected jittered exponential backoff, got %v", waits)
+	}
+	if stats := c.Stats(); stats.Retries != 2 || stats.RateLimited != 0 {
+		t.Errorf("Expected 2 retries, got %+v", stats)
+	}
+}
+
+func TestClientDoesNotRetryPost(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	URL := "https://gitlab.com/api/v4/projects/1/milestones"
+	calls := mockSequence("POST", URL, httpmock.NewStringResponse(500, "error"))
+
+	var waits []time.Duration
+	c := testClient(&waits)
+	resp, _, err := c.Send(context.Background(), "gitlab", "POST", URL, APIHeader("gitlab", "token"), []byte("title=a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 500 || *calls != 1 || len(waits) != 0 {
+		t.Errorf("Expected a single call returning 500, got %d after %d calls", resp.StatusCode, *calls)
+	}
+}
+
+func TestClientGivesUpAfterMaxRetries(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	URL := "https://gitlab.com/api/v4/version"
+	calls := mockSequence("GET", URL, httpmock.NewStringResponse(503, "unavailable"))
+
+	var waits []time.Duration
+	c := testClient(&waits)
+	resp, body, err := c.Send(context.Background(), "gitlab", "GET", URL, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *calls != 4 || resp.StatusCode != 503 {
+		t.Errorf("Expected 4 calls returning 503, got %d calls", *calls)
+	}
+	if err := CheckResponse("GET", URL, resp, body); err == nil {
+		t.Error("Expected an error")
+	}
+}
+
+func TestClientWaitsForRateLimit(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	URL := "https://api.github.com/repos/namespace/project/milestones"
+	limited := httpmock.NewStringResponse(403, `{"message": "You have exceeded a secondary rate limit."}`)
+	limited.Header.Set("Retry-After", "30")
+	calls := mockSequence("POST", URL, limited, httpmock.NewStringResponse(201, "{}"))
+
+	var waits []time.Duration
+	c := testClient(&waits)
+	resp, _, err := c.Send(context.Background(), "github", "POST", URL, APIHeader("github", "token"), []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 201 || *calls != 2 {
+		t.Errorf("Expected 201 after 2 calls, got %d after %d", resp.StatusCode, *calls)
+	}
+	if len(waits) != 1 || waits[0] != 30*time.Second {
+		t.Errorf("Expected to wait 30s, got %v", waits)
+	}
+	throttled := c.Stats().Since(ClientStats{})
+	if throttled == nil || throttled.RateLimited != 1 || throttled.Waited != "30s" {
+		t.Errorf("Expected a rate limit wait of 30s, got %+v", throttled)
+	}
+}
+
+func TestClientWaitsForExhaustedWindow(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	URL := "https://gitlab.com/api/v4/projects/1/milestones"
+	reset := time.Now().Add(time.Minute)
+	last := httpmock.NewStringResponse(200, "[]")
+	last.Header.Set("RateLimit-Remaining", "0")
+	last.Header.Set("RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	mockSequence("GET", URL, last, httpmock.NewStringResponse(200, "[]"))
+
+	var waits []time.Duration
+	c := testClient(&waits)
+	for i := 0; i < 2; i++ {
+		_, _, err := c.Send(context.Background(), "gitlab", "GET", URL, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(waits) != 1 || waits[0] <= 50*time.Second || waits[0] > time.Minute {
+		t.Errorf("Expected to wait for the window to reset, got %v", waits)
+	}
+}
+
+func TestClientRateLimitExceedsMaxWait(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	URL := "https://api.github.com/repos/namespace/project/milestones"
+	limited := httpmock.NewStringResponse(403, `{"message": "API rate limit exceeded"}`)
+	limited.Header.Set("X-RateLimit-Remaining", "0")
+	limited.Header.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	calls := mockSequence("GET", URL, limited)
+
+	var waits []time.Duration
+	c := testClient(&waits)
+	resp, body, err := c.Send(context.Background(), "github", "GET", URL, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *calls != 1 || len(waits) != 0 {
+		t.Errorf("Expected no wait beyond MaxWait, got %d calls and waits %v", *calls, waits)
+	}
+	err = CheckResponse("GET", URL, resp, body)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestClientCancelled(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	URL := "https://gitlab.com/api/v4/version"
+	calls := mockSequence("GET", URL, httpmock.NewStringResponse(503, "unavailable"))
+
+	var waits []time.Duration
+	c := testClient(&waits)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.sleep = func(context.Context, time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+	_, _, err := c.Send(ctx, "gitlab", "GET", URL, nil, nil)
+	if !errors.Is(err, context.Canceled) || *calls != 1 {
+		t.Errorf("Expected to stop retrying once cancelled, got %v after %d calls", err, *calls)
+	}
+
+	_, _, err = c.Send(ctx, "gitlab", "GET", URL, nil, nil)
+	if !errors.Is(err, context.Canceled) || *calls != 1 {
+		t.Errorf("Expected no request once cancelled, got %v after %d calls", err, *calls)
+	}
+}
+
+func TestClientRequestTimeout(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	URL := "https://gitlab.com/api/v4/version"
+	httpmock.RegisterResponder("GET", URL, func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+
+	var waits []time.Duration
+	c := testClient(&waits)
+	c.MaxRetries = 0
+	ctx := WithRequestTimeout(context.Background(), 10*time.Millisecond)
+	_, _, err := c.Send(ctx, "gitlab", "GET", URL, nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the attempt to time out after the timeout of the context, got %v", err)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleep(ctx, time.Hour); err != context.Canceled {
+		t.Errorf("Expected the sleep to be cancelled, got %v", err)
+	}
+	if err := sleep(context.Background(), time.Millisecond); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		status  int
+		header  map[string]string
+		body    string
+		wait    time.Duration
+		limited bool
+	}{
+		{"ok", 200, nil, "", 0, false},
+		{"forbidden", 403, nil, `{"message": "Must have admin rights"}`, 0, false},
+		{"retry after seconds", 429, map[string]string{"Retry-After": "5"}, "", 5 * time.Second, true},
+		{"retry after date", 429, map[string]string{"Retry-After": now.Add(time.Minute).Format(http.TimeFormat)}, "", time.Minute, true},
+		{"github reset", 403, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": strconv.FormatInt(now.Add(time.Minute).Unix(), 10)}, "", time.Minute + time.Second, true},
+		{"gitlab reset", 429, map[string]string{"RateLimit-Reset": strconv.FormatInt(now.Add(time.Minute).Unix(), 10)}, "", time.Minute + time.Second, true},
+		{"secondary", 403, nil, `{"message": "You have exceeded a secondary rate limit"}`, time.Minute, true},
+	}
+	for _, test := range tests {
+		resp := httpmock.NewStringResponse(test.status, test.body)
+		for k, v := range test.header {
+			resp.Header.Set(k, v)
+		}
+		wait, limited := rateLimitWait(resp, []byte(test.body), now)
+		if wait != test.wait || limited != test.limited {
+			t.Errorf("%s: expected %v %t, got %v %t", test.name, test.wait, test.limited, wait, limited)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultConcurrency is the number of API requests sent at once unless the context sets another
+const DefaultConcurrency = 4
+
+type concurrencyKey struct{}
+
+// WithConcurrency returns a context sending n API requests at once with ForEach
+func WithConcurrency(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, concurrencyKey{}, n)
+}
+
+// ValidateConcurrency checks that at least one API request is sent at once
+func ValidateConcurrency(n int) error {
+	if n < 1 {
+		return fmt.Errorf("Error: Invalid concurrency %d, at least 1 request is needed", n)
+	}
+	return nil
+}
+
+// Concurrency returns the number of API requests sent at once with ctx
+func Concurrency(ctx context.Context) int {
+	if n, ok := ctx.Value(concurrencyKey{}).(int); ok && n > 0 {
+		return n
+	}
+	return DefaultConcurrency
+}
+
+// MilestoneErrors are the errors of milestones by title
+type MilestoneErrors map[string]error
+
+// Error lists the errors ordered by title
+func (e MilestoneErrors) Error() string {
+	var keys []string
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var errs []string
+	for _, k := range keys {
+		errs = append(errs, fmt.Sprintf("%s: %v", k, e[k]))
+	}
+	return strings.Join(errs, "; ")
+}
+
+// Is reports whether the error of any milestone matches target
+func (e MilestoneErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of the milestones ordered by title that matches target
+func (e MilestoneErrors) As(target interface{}) bool {
+	var keys []string
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if errors.As(e[k], target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reasons returns the error messages by title
+func (e MilestoneErrors) Reasons() map[string]string {
+	reasons := make(map[string]string, len(e))
+	for k, err := range e {
+		reasons[k] = err.Error()
+	}
+	return reasons
+}
+
+// Err returns the errors as an error, nil if there are none
+func (e MilestoneErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ForEach calls fn for every key in sorted order, with at most the concurrency of ctx calls running at once.
+// The index passed to fn is the position of the key in that order, so results can be stored without locking.
+// The errors are returned by key, keys not started before ctx is done fail with the error of ctx.
+func ForEach(ctx context.Context, keys []string, fn func(i int, key string) error) MilestoneErrors {
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	errs := make([]error, len(sorted))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, Concurrency(ctx))
+	for i, key := range sorted {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			continue
+		}
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(i, key)
+		}(i, key)
+	}
+	wg.Wait()
+
+	failed := MilestoneErrors{}
+	for i, err := range errs {
+		if err != nil {
+			failed[sorted[i]] = err
+		}
+	}
+	return failed
+}
+
+// ForEachMilestone calls fn for every milestone as ForEach does.
+// The milestones returned by fn are collected by title for those that succeeded.
+func ForEachMilestone(ctx context.Context, milestones map[string]Milestone, fn func(m Milestone) (Milestone, error)) (map[string]Milestone, MilestoneErrors) {
+	var keys []string
+	for k := range milestones {
+		keys = append(keys, k)
+	}
+	results := make([]Milestone, len(keys))
+	failed := ForEach(ctx, keys, func(i int, key string) error {
+		var err error
+		results[i], err = fn(milestones[key])
+		return err
+	})
+
+	sort.Strings(keys)
+	done := make(map[string]Milestone, len(keys))
+	for i, k := range keys {
+		if _, ok := failed[k]; !ok {
+			done[k] = results[i]
+		}
+	}
+	return done, failed
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForEachConcurrencyLimit(t *testing.T) {
+	var mu sync.Mutex
+	running, max := 0, 0
+	keys := []string{"e", "d", "c", "b", "a"}
+	seen := make([]string, len(keys))
+	failed := ForEach(WithConcurrency(context.Background(), 2), keys, func(i int, key string) error {
+		mu.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		seen[i] = key
+		return nil
+	})
+	if len(failed) != 0 {
+		t.Errorf("Unexpected errors %v", failed)
+	}
+	if max > 2 {
+		t.Errorf("Expected at most %d calls at once, got %d", 2, max)
+	}
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		if seen[i] != key {
+			t.Errorf("Expected %s at %d, got %s", key, i, seen[i])
+		}
+	}
+}
+
+func TestForEachCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	failed := ForEach(ctx, []string{"a", "b"}, func(i int, key string) error {
+		calls++
+		return nil
+	})
+	if calls != 0 || len(failed) != 2 || !errors.Is(failed, context.Canceled) {
+		t.Errorf("Expected no calls and all keys cancelled, got %d calls and %v", calls, failed)
+	}
+}
+
+func TestForEachMilestone(t *testing.T) {
+	milestones := map[string]Milestone{
+		"2018-01-01": {Title: "2018-01-01"},
+		"2018-01-02": {Title: "2018-01-02"},
+		"2018-01-03": {Title: "2018-01-03"},
+	}
+	done, failed := ForEachMilestone(context.Background(), milestones, func(m Milestone) (Milestone, error) {
+		if m.Title == "2018-01-02" {
+			return m, errors.New("conflict")
+		}
+		m.State = "closed"
+		return m, nil
+	})
+	if len(done) != 2 || done["2018-01-01"].State != "closed" || done["2018-01-03"].State != "closed" {
+		t.Errorf("Unexpected milestones %v", done)
+	}
+	if failed.Error() != "2018-01-02: conflict" || failed.Reasons()["2018-01-02"] != "conflict" {
+		t.Errorf("Unexpected errors %v", failed)
+	}
+	var apiErr *APIError
+	failed = MilestoneErrors{"a": errors.New("a"), "b": &APIError{StatusCode: 404, kind: ErrNotFound}}
+	if !errors.Is(failed.Err(), ErrNotFound) || !errors.As(failed.Err(), &apiErr) || apiErr.StatusCode != 404 {
+		t.Errorf("Expected the errors of milestones to match, got %v", failed)
+	}
+	if (MilestoneErrors{}).Err() != nil {
+		t.Errorf("Expected no error without failed milestones")
+	}
+	if ValidateConcurrency(0) == nil {
+		t.Errorf("Expected to get an error for a concurrency of 0")
+	}
+	if Concurrency(context.Background()) != DefaultConcurrency {
+		t.Errorf("Expected the default concurrency without a configured one")
+	}
+}
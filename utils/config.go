@@ -0,0 +1,188 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config describes providers and the projects whose milestones are managed
+type Config struct {
+	// Settings applied to every project, named like the command line flags
+	Defaults  map[string]string `yaml:"defaults"`
+	Providers []ProviderConfig  `yaml:"providers"`
+	Projects  []ProjectConfig   `yaml:"projects"`
+}
+
+// ProviderConfig is a GitLab or GitHub instance with the source of its token
+type ProviderConfig struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// At most one of the token sources is set, without one the token is looked up like on the command line
+	Token     string `yaml:"token"`
+	TokenEnv  string `yaml:"token_env"`
+	TokenFile string `yaml:"token_file"`
+	// GitHub App authenticating with installation tokens instead of a token
+	GithubAppID      int64  `yaml:"github_app_id"`
+	GithubAppKeyFile string `yaml:"github_app_key_file"`
+	// Secret of webhooks received by serve, at most one of the sources is set
+	WebhookSecret    string `yaml:"webhook_secret"`
+	WebhookSecretEnv string `yaml:"webhook_secret_env"`
+}
+
+// ProjectConfig is a project of a provider with its settings overriding the defaults
+type ProjectConfig struct {
+	Provider  string `yaml:"provider"`
+	Namespace string `yaml:"namespace"`
+	// An empty project selects all projects of the namespace
+	Project string `yaml:"project"`
+	// Settings named like the command line flags, e.g. interval or close-expired
+	Settings map[string]string `yaml:",inline"`
+}
+
+// Name returns the namespace and project, or the namespace alone if all of its projects are selected
+func (p ProjectConfig) Name() string {
+	if p.Project == "" {
+		return p.Namespace
+	}
+	return p.Namespace + "/" + p.Project
+}
+
+// LoadConfig reads and validates a configuration.
+// Unknown fields are an error, settings are checked by the caller.
+func LoadConfig(r io.Reader) (Config, error) {
+	var c Config
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return c, err
+	}
+	err = yaml.UnmarshalStrict(data, &c)
+	if err != nil {
+		return c, fmt.Errorf("Error: invalid configuration: %v", err)
+	}
+	return c, c.Validate()
+}
+
+// LoadConfigFile reads and validates a configuration file
+func LoadConfigFile(path string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+	defer f.Close()
+	c, err := LoadConfig(f)
+	if err != nil {
+		return c, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Validate checks that providers are complete and unique and that projects refer to one of them
+func (c Config) Validate() error {
+	var errs []string
+	providers := map[string]bool{}
+	for i, p := range c.Providers {
+		name := p.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+			errs = append(errs, fmt.Sprintf("provider %s has no name", name))
+		} else if providers[name] {
+			errs = append(errs, fmt.Sprintf("provider %s is defined more than once", name))
+		}
+		providers[p.Name] = true
+		if p.URL == "" {
+			errs = append(errs, fmt.Sprintf("provider %s has no url", name))
+		}
+		sources := 0
+		for _, s := range []string{p.Token, p.TokenEnv, p.TokenFile} {
+			if s != "" {
+				sources++
+			}
+		}
+		if sources > 1 {
+			errs = append(errs, fmt.Sprintf("provider %s needs at most one of token, token_env and token_file", name))
+		}
+		if (p.GithubAppID == 0) != (p.GithubAppKeyFile == "") {
+			errs = append(errs, fmt.Sprintf("provider %s needs both github_app_id and github_app_key_file", name))
+		}
+		if p.GithubAppID != 0 && sources > 0 {
+			errs = append(errs, fmt.Sprintf("provider %s cannot use a token with a GitHub App", name))
+		}
+		if p.WebhookSecret != "" && p.WebhookSecretEnv != "" {
+			errs = append(errs, fmt.Sprintf("provider %s needs at most one of webhook_secret and webhook_secret_env", name))
+		}
+	}
+	if len(c.Projects) == 0 {
+		errs = append(errs, "no projects configured")
+	}
+	for i, p := range c.Projects {
+		name := fmt.Sprintf("#%d", i+1)
+		if p.Namespace == "" {
+			errs = append(errs, fmt.Sprintf("project %s needs a namespace", name))
+		} else {
+			name = p.Name()
+		}
+		if !providers[p.Provider] {
+			errs = append(errs, fmt.Sprintf("project %s refers to unknown provider %q", name, p.Provider))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Error: invalid configuration:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// Provider returns the provider of a project
+func (c Config) Provider(p ProjectConfig) ProviderConfig {
+	for _, provider := range c.Providers {
+		if provider.Name == p.Provider {
+			return provider
+		}
+	}
+	return ProviderConfig{}
+}
+
+// ResolveToken returns the token of the provider from its source
+func (p ProviderConfig) ResolveToken() (string, error) {
+	switch {
+	case p.TokenEnv != "":
+		token := os.Getenv(p.TokenEnv)
+		if token == "" {
+			return "", fmt.Errorf("Error: environment variable %s of provider %s is empty", p.TokenEnv, p.Name)
+		}
+		return token, nil
+	case p.TokenFile != "":
+		return ReadTokenFile(p.TokenFile)
+	}
+	return p.Token, nil
+}
+
+// ResolveWebhookSecret returns the webhook secret of the provider, empty if webhooks are not configured
+func (p ProviderConfig) ResolveWebhookSecret() (string, error) {
+	if p.WebhookSecretEnv != "" {
+		secret := os.Getenv(p.WebhookSecretEnv)
+		if secret == "" {
+			return "", fmt.Errorf("Error: environment variable %s of provider %s is empty", p.WebhookSecretEnv, p.Name)
+		}
+		return secret, nil
+	}
+	return p.WebhookSecret, nil
+}
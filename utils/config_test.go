@@ -0,0 +1,120 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const mockConfig = `
+defaults:
+  interval: weekly
+  close-expired: true
+providers:
+  - name: gitlab
+    url: gitlab.com
+    token_env: GOMILER_TEST_TOKEN
+projects:
+  - provider: gitlab
+    namespace: team
+    project: app
+    advance: 12
+`
+
+func TestLoadConfig(t *testing.T) {
+	c, err := LoadConfig(strings.NewReader(mockConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Defaults["interval"] != "weekly" || c.Defaults["close-expired"] != "true" {
+		t.Errorf("Unexpected defaults %v", c.Defaults)
+	}
+	if len(c.Projects) != 1 || c.Projects[0].Name() != "team/app" || c.Projects[0].Settings["advance"] != "12" {
+		t.Errorf("Unexpected projects %v", c.Projects)
+	}
+	if c.Provider(c.Projects[0]).URL != "gitlab.com" {
+		t.Errorf("Expected provider of the project, got %v", c.Provider(c.Projects[0]))
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	cases := map[string]string{
+		"unknown field":    "provider:\n  - name: gitlab\n",
+		"no projects":      "providers:\n  - name: gitlab\n    url: gitlab.com\n    token: x\n",
+		"unknown provider": "projects:\n  - provider: gitlab\n    namespace: team\n    project: app\n",
+		"duplicate provider": "providers:\n  - {name: a, url: gitlab.com, token: x}\n  - {name: a, url: gitlab.com, token: x}\n" +
+			"projects:\n  - {provider: a, namespace: team, project: app}\n",
+		"two token sources": "providers:\n  - {name: a, url: gitlab.com, token: x, token_env: X}\n" +
+			"projects:\n  - {provider: a, namespace: team, project: app}\n",
+		"two webhook secrets": "providers:\n  - {name: a, url: gitlab.com, token: x, webhook_secret: s, webhook_secret_env: S}\n" +
+			"projects:\n  - {provider: a, namespace: team, project: app}\n",
+		"github app without key": "providers:\n  - {name: a, url: api.github.com, github_app_id: 1}\n" +
+			"projects:\n  - {provider: a, namespace: team, project: app}\n",
+		"github app with token": "providers:\n  - {name: a, url: api.github.com, token: x, github_app_id: 1, github_app_key_file: app.pem}\n" +
+			"projects:\n  - {provider: a, namespace: team, project: app}\n",
+		"missing namespace": "providers:\n  - {name: a, url: gitlab.com, token: x}\n" +
+			"projects:\n  - {provider: a, project: app}\n",
+	}
+	for name, config := range cases {
+		_, err := LoadConfig(strings.NewReader(config))
+		if err == nil {
+			t.Errorf("Expected to get an error for %s", name)
+		}
+	}
+}
+
+func TestResolveToken(t *testing.T) {
+	os.Setenv("GOMILER_TEST_TOKEN", "from-env")
+	defer os.Unsetenv("GOMILER_TEST_TOKEN")
+	dir, err := ioutil.TempDir("", "gomiler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "token")
+	ioutil.WriteFile(file, []byte("from-file\n"), 0600)
+
+	cases := []struct {
+		provider ProviderConfig
+		expected string
+	}{
+		{ProviderConfig{Token: "literal"}, "literal"},
+		{ProviderConfig{TokenEnv: "GOMILER_TEST_TOKEN"}, "from-env"},
+		{ProviderConfig{TokenFile: file}, "from-file"},
+	}
+	for _, c := range cases {
+		token, err := c.provider.ResolveToken()
+		if err != nil {
+			t.Error(err)
+		}
+		if token != c.expected {
+			t.Errorf("Expected %s, got %s", c.expected, token)
+		}
+	}
+	_, err = ProviderConfig{TokenEnv: "GOMILER_TEST_UNSET"}.ResolveToken()
+	if err == nil {
+		t.Errorf("Expected to get an error when the token variable is empty")
+	}
+	empty := filepath.Join(dir, "empty")
+	ioutil.WriteFile(empty, []byte(" \n"), 0600)
+	_, err = ProviderConfig{TokenFile: empty}.ResolveToken()
+	if err == nil {
+		t.Errorf("Expected to get an error when the token file is empty")
+	}
+}
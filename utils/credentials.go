@@ -0,0 +1,261 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Credential is an API token with the source it was read from
+type Credential struct {
+	Token string
+	// Source of the token, e.g. $GITLAB_TOKEN or ~/.netrc, never the token itself
+	Source string
+}
+
+// gitCredentialTimeout limits the time a git credential helper may take
+const gitCredentialTimeout = 10 * time.Second
+
+// gitCredentialFill runs git credential fill with the input and returns its output, replaced in tests
+var gitCredentialFill = func(ctx context.Context, input string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(input)
+	// Fail instead of prompting for credentials that are not stored
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never")
+	return cmd.Output()
+}
+
+// ReadTokenFile reads a token from a file, ignoring surrounding whitespace
+func ReadTokenFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("Error: token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// LookupToken returns the token for the API at host from the first source that has one:
+// $GOMILER_TOKEN, $GITHUB_TOKEN or $GITLAB_TOKEN, ~/.netrc and the git credential helper.
+// It reports false if none of the sources has a token for the host.
+func LookupToken(ctx context.Context, host string) (Credential, bool, error) {
+	if c, ok := envToken(host); ok {
+		return c, true, nil
+	}
+	hosts := credentialHosts(host)
+	c, ok, err := netrcToken(hosts)
+	if err != nil || ok {
+		return c, ok, err
+	}
+	return gitCredentialToken(ctx, hosts)
+}
+
+// envToken returns the token of $GOMILER_TOKEN, otherwise $GITHUB_TOKEN or $GITLAB_TOKEN as the host suggests.
+// For a host that suggests neither, e.g. a self-hosted instance, the one of both that is set is used.
+func envToken(host string) (Credential, bool) {
+	if token := os.Getenv("GOMILER_TOKEN"); token != "" {
+		return Credential{token, "$GOMILER_TOKEN"}, true
+	}
+	github, gitlab := os.Getenv("GITHUB_TOKEN"), os.Getenv("GITLAB_TOKEN")
+	switch {
+	case strings.Contains(host, "github"):
+		gitlab = ""
+	case strings.Contains(host, "gitlab"):
+		github = ""
+	case github != "" && gitlab != "":
+		return Credential{}, false
+	}
+	if github != "" {
+		return Credential{github, "$GITHUB_TOKEN"}, true
+	}
+	if gitlab != "" {
+		return Credential{gitlab, "$GITLAB_TOKEN"}, true
+	}
+	return Credential{}, false
+}
+
+// credentialHosts returns the hosts credentials of an API host are stored for,
+// the host itself, without port and without the api. prefix of GitHub's API, e.g. github.com for api.github.com
+func credentialHosts(host string) []string {
+	hosts := []string{host}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hosts = append(hosts, h)
+	}
+	for _, h := range hosts {
+		if strings.HasPrefix(h, "api.") {
+			hosts = append(hosts, strings.TrimPrefix(h, "api."))
+		}
+	}
+	return hosts
+}
+
+// netrcPath returns the path of the netrc file, $NETRC or ~/.netrc
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// netrcToken returns the password of the first machine of the hosts in the netrc file, or of its default entry
+func netrcToken(hosts []string) (Credential, bool, error) {
+	path := netrcPath()
+	if path == "" {
+		return Credential{}, false, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Credential{}, false, nil
+	}
+	if err != nil {
+		return Credential{}, false, err
+	}
+	passwords := parseNetrc(content)
+	for _, h := range append(hosts, "") {
+		if password, ok := passwords[h]; ok {
+			return Credential{password, path}, true, nil
+		}
+	}
+	return Credential{}, false, nil
+}
+
+// parseNetrc returns the passwords by machine, the default entry under an empty machine
+func parseNetrc(content []byte) map[string]string {
+	passwords := map[string]string{}
+	var machine string
+	var inMachine bool
+	fields := strings.Fields(string(content))
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			if i+1 < len(fields) {
+				i++
+				machine, inMachine = fields[i], true
+			}
+		case "default":
+			machine, inMachine = "", true
+		case "password":
+			if i+1 < len(fields) {
+				i++
+				if _, ok := passwords[machine]; inMachine && !ok {
+					passwords[machine] = fields[i]
+				}
+			}
+		case "login", "account":
+			i++
+		case "macdef":
+			// Macros run until an empty line, which fields do not keep, so stop at the first macro
+			return passwords
+		}
+	}
+	return passwords
+}
+
+// gitCredentialToken asks git credential fill for the password of the hosts.
+// A missing git or credential is not an error, git is not allowed to prompt.
+func gitCredentialToken(ctx context.Context, hosts []string) (Credential, bool, error) {
+	for _, h := range hosts {
+		ctx, cancel := context.WithTimeout(ctx, gitCredentialTimeout)
+		output, err := gitCredentialFill(ctx, fmt.Sprintf("protocol=https\nhost=%s\n\n", h))
+		cancel()
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(output))
+		for scanner.Scan() {
+			if password := strings.TrimPrefix(scanner.Text(), "password="); password != scanner.Text() && password != "" {
+				return Credential{password, "git credential fill for " + h}, true, nil
+			}
+		}
+	}
+	return Credential{}, false, nil
+}
+
+// TokenSource returns the token of requests, e.g. a short-lived token renewed before it expires
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// Authorize authenticates the requests to URLs below prefix, e.g. https://api.github.com/repos/team, with the tokens of s.
+// The source of the longest matching prefix is asked for every attempt, requests carrying a token already are sent as they are.
+// A nil source removes the prefix.
+func (c *Client) Authorize(prefix string, s TokenSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prefix = strings.TrimSuffix(prefix, "/")
+	if s == nil {
+		delete(c.tokens, prefix)
+		return
+	}
+	c.tokens[prefix] = s
+}
+
+// tokenSource returns the source of the longest prefix of URL, nil if none
+func (c *Client) tokenSource(URL string) TokenSource {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var source TokenSource
+	var longest string
+	for prefix, s := range c.tokens {
+		if !strings.HasPrefix(URL, prefix) || len(prefix) < len(longest) {
+			continue
+		}
+		// Match whole path segments, https://api.github.com/repos/team is no prefix of .../repos/teams
+		if rest := URL[len(prefix):]; rest != "" && rest[0] != '/' && rest[0] != '?' {
+			continue
+		}
+		source, longest = s, prefix
+	}
+	return source
+}
+
+// authorize returns the header with the token of the source of URL, unless it authenticates the request already
+func (c *Client) authorize(ctx context.Context, api string, URL string, header http.Header) (http.Header, error) {
+	if header.Get("Authorization") != "" || header.Get("PRIVATE-TOKEN") != "" {
+		return header, nil
+	}
+	s := c.tokenSource(URL)
+	if s == nil {
+		return header, nil
+	}
+	token, err := s.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	authorized := header.Clone()
+	if authorized == nil {
+		authorized = http.Header{}
+	}
+	setToken(authorized, api, token)
+	return authorized, nil
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+// setenv sets the environment variables for a test and returns a function restoring them
+func setenv(vars map[string]string) func() {
+	previous := map[string]*string{}
+	for k, v := range vars {
+		if old, ok := os.LookupEnv(k); ok {
+			previous[k] = &old
+		} else {
+			previous[k] = nil
+		}
+		if v == "" {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, v)
+		}
+	}
+	return func() {
+		for k, v := range previous {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+// stubGitCredentialFill replaces git credential fill with fill and returns a function restoring it
+func stubGitCredentialFill(fill func(context.Context, string) ([]byte, error)) func() {
+	previous := gitCredentialFill
+	gitCredentialFill = fill
+	return func() { gitCredentialFill = previous }
+}
+
+func TestEnvToken(t *testing.T) {
+	cases := []struct {
+		name, host, gomiler, github, gitlab string
+		want                                Credential
+		ok                                  bool
+	}{
+		{"gomiler first", "api.github.com", "g", "h", "l", Credential{"g", "$GOMILER_TOKEN"}, true},
+		{"github host", "api.github.com", "", "h", "l", Credential{"h", "$GITHUB_TOKEN"}, true},
+		{"gitlab host", "gitlab.com", "", "h", "l", Credential{"l", "$GITLAB_TOKEN"}, true},
+		{"github host without github token", "api.github.com", "", "", "l", Credential{}, false},
+		{"other host with one token", "devhub.example.com", "", "", "l", Credential{"l", "$GITLAB_TOKEN"}, true},
+		{"other host with both tokens", "devhub.example.com", "", "h", "l", Credential{}, false},
+		{"no tokens", "gitlab.com", "", "", "", Credential{}, false},
+	}
+	for _, c := range cases {
+		restore := setenv(map[string]string{"GOMILER_TOKEN": c.gomiler, "GITHUB_TOKEN": c.github, "GITLAB_TOKEN": c.gitlab})
+		got, ok := envToken(c.host)
+		restore()
+		if got != c.want || ok != c.ok {
+			t.Errorf("%s: expected %v %t, got %v %t", c.name, c.want, c.ok, got, ok)
+		}
+	}
+}
+
+func TestCredentialHosts(t *testing.T) {
+	cases := map[string][]string{
+		"gitlab.com":                {"gitlab.com"},
+		"api.github.com":            {"api.github.com", "github.com"},
+		"gitlab.example.com:8443":   {"gitlab.example.com:8443", "gitlab.example.com"},
+		"api.github.example.com:80": {"api.github.example.com:80", "api.github.example.com", "github.example.com:80", "github.example.com"},
+	}
+	for host, want := range cases {
+		if got := credentialHosts(host); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v, got %v", host, want, got)
+		}
+	}
+}
+
+func TestParseNetrc(t *testing.T) {
+	content := `machine gitlab.com login oauth2 password glpat-1
+machine github.com
+  login user
+  password ghp-1
+machine gitlab.com password glpat-2
+default login anonymous password fallback
+macdef init
+machine ignored.com password ignored
+`
+	want := map[string]string{"gitlab.com": "glpat-1", "github.com": "ghp-1", "": "fallback"}
+	if got := parseNetrc([]byte(content)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestLookupToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomiler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	netrc := filepath.Join(dir, "netrc")
+	err = ioutil.WriteFile(netrc, []byte("machine github.com login user password ghp-netrc\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer setenv(map[string]string{"GOMILER_TOKEN": "", "GITHUB_TOKEN": "", "GITLAB_TOKEN": "", "NETRC": netrc})()
+
+	var inputs []string
+	defer stubGitCredentialFill(func(ctx context.Context, input string) ([]byte, error) {
+		inputs = append(inputs, input)
+		if strings.Contains(input, "host=gitlab.example.com\n") {
+			return []byte("protocol=https\nhost=gitlab.example.com\nusername=oauth2\npassword=glpat-git\n"), nil
+		}
+		return nil, errors.New("exit status 128")
+	})()
+
+	c, ok, err := LookupToken(context.Background(), "api.github.com")
+	if err != nil || !ok || c != (Credential{"ghp-netrc", netrc}) {
+		t.Errorf("Expected the token of the netrc file, got %v %t %v", c, ok, err)
+	}
+	if len(inputs) != 0 {
+		t.Errorf("Expected no git credential lookup, got %q", inputs)
+	}
+
+	c, ok, err = LookupToken(context.Background(), "gitlab.example.com:8443")
+	if err != nil || !ok || c != (Credential{"glpat-git", "git credential fill for gitlab.example.com"}) {
+		t.Errorf("Expected the token of git credential fill, got %v %t %v", c, ok, err)
+	}
+	want := []string{"protocol=https\nhost=gitlab.example.com:8443\n\n", "protocol=https\nhost=gitlab.example.com\n\n"}
+	if !reflect.DeepEqual(inputs, want) {
+		t.Errorf("Expected git credential inputs %q, got %q", want, inputs)
+	}
+
+	c, ok, err = LookupToken(context.Background(), "gitlab.com")
+	if err != nil || ok {
+		t.Errorf("Expected no token, got %v %t %v", c, ok, err)
+	}
+
+	defer setenv(map[string]string{"GITLAB_TOKEN": "glpat-env"})()
+	c, ok, err = LookupToken(context.Background(), "gitlab.com")
+	if err != nil || !ok || c != (Credential{"glpat-env", "$GITLAB_TOKEN"}) {
+		t.Errorf("Expected the token of the environment, got %v %t %v", c, ok, err)
+	}
+}
+
+func TestReadTokenFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomiler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(path, []byte("  glpat-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := ReadTokenFile(path); err != nil || token != "glpat-file" {
+		t.Errorf("Expected glpat-file, got %q %v", token, err)
+	}
+
+	empty := filepath.Join(dir, "empty")
+	if err := ioutil.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadTokenFile(empty); err == nil {
+		t.Errorf("Expected an error for an empty token file")
+	}
+	if _, err := ReadTokenFile(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("Expected an error for a missing token file")
+	}
+}
+
+// countingSource returns the tokens token-1, token-2, ... numbered by the calls
+type countingSource struct {
+	prefix string
+	calls  int
+}
+
+func (s *countingSource) Token(ctx context.Context) (string, error) {
+	s.calls++
+	return s.prefix + "-" + strconv.Itoa(s.calls), nil
+}
+
+func TestClientAuthorize(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	var authorizations []string
+	for _, URL := range []string{
+		"https://api.github.com/repos/team/app/milestones",
+		"https://api.github.com/repos/team/lib/milestones",
+		"https://api.github.com/repos/teams/app/milestones",
+	} {
+		statuses := []int{503, 200}
+		httpmock.RegisterResponder("GET", URL, func(req *http.Request) (*http.Response, error) {
+			authorizations = append(authorizations, req.Header.Get("Authorization"))
+			status := statuses[0]
+			if len(statuses) > 1 {
+				statuses = statuses[1:]
+			}
+			return httpmock.NewStringResponse(status, "[]"), nil
+		})
+	}
+
+	var waits []time.Duration
+	c := testClient(&waits)
+	team, app := &countingSource{prefix: "team"}, &countingSource{prefix: "app"}
+	c.Authorize("https://api.github.com/repos/team/", team)
+	c.Authorize("https://api.github.com/repos/team/app", app)
+
+	cases := []struct {
+		URL, token string
+		want       []string
+	}{
+		// Every attempt asks the source of the longest prefix
+		{"https://api.github.com/repos/team/app/milestones", "", []string{"token app-1", "token app-2"}},
+		{"https://api.github.com/repos/team/lib/milestones", "", []string{"token team-1", "token team-2"}},
+		// A request with a token, or outside the prefixes, is sent as it is
+		{"https://api.github.com/repos/team/app/milestones", "own", []string{"token own"}},
+		{"https://api.github.com/repos/teams/app/milestones", "", []string{"", ""}},
+	}
+	for _, tc := range cases {
+		authorizations = nil
+		resp, _, err := c.Send(context.Background(), "github", "GET", tc.URL, APIHeader("github", tc.token), nil)
+		if err != nil || resp.StatusCode != 200 {
+			t.Fatalf("%s: expected 200, got %v", tc.URL, err)
+		}
+		if !reflect.DeepEqual(authorizations, tc.want) {
+			t.Errorf("%s: expected %q, got %q", tc.URL, tc.want, authorizations)
+		}
+	}
+
+	c.Authorize("https://api.github.com/repos/team/app", nil)
+	if s := c.tokenSource("https://api.github.com/repos/team/app/milestones"); s != team {
+		t.Errorf("Expected the source of the team after removing the one of app, got %v", s)
+	}
+}
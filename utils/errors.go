@@ -0,0 +1,149 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Kinds of API errors, an APIError unwraps to one of them if its status is known
+var (
+	// ErrUnauthorized is returned for a missing, invalid or insufficient token
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotFound is returned for a project, milestone or endpoint that does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrValidation is returned for a request the API rejected, e.g. a milestone title already taken
+	ErrValidation = errors.New("validation failed")
+	// ErrRateLimited is returned when the rate limit of the API is exceeded
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// APIError is an error response of the GitLab or GitHub API
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	// Message and field errors parsed from the body
+	Message string
+	Errors  []string
+	// Body as returned if it could not be parsed
+	Body string
+	kind error
+}
+
+func (e *APIError) Error() string {
+	detail := e.Message
+	if len(e.Errors) > 0 {
+		if detail != "" {
+			detail += ": "
+		}
+		detail += strings.Join(e.Errors, ", ")
+	}
+	if detail == "" {
+		detail = e.Body
+	}
+	return fmt.Sprintf("%s %s returned %s: %s", e.Method, e.URL, e.Status, detail)
+}
+
+// Unwrap returns the kind of the error, nil if the status is not one of the known kinds
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// CheckResponse returns an APIError for a response to a request without 2xx status, nil otherwise
+func CheckResponse(method string, URL string, resp *http.Response, body []byte) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	e := &APIError{
+		Method:     method,
+		URL:        URL,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+	}
+	parseErrorBody(e, body)
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		e.kind = ErrUnauthorized
+	case http.StatusForbidden:
+		// GitHub reports exceeded rate limits as 403
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" || strings.Contains(strings.ToLower(e.Message), "rate limit") {
+			e.kind = ErrRateLimited
+		} else {
+			e.kind = ErrUnauthorized
+		}
+	case http.StatusNotFound:
+		e.kind = ErrNotFound
+	case http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity:
+		e.kind = ErrValidation
+	case http.StatusTooManyRequests:
+		e.kind = ErrRateLimited
+	}
+	return e
+}
+
+// parseErrorBody sets the message and field errors of GitHub and GitLab error bodies,
+// e.g. {"message": "Validation Failed", "errors": [{"field": "title", "code": "already_exists"}]}
+// or {"message": {"title": ["has already been taken"]}}
+func parseErrorBody(e *APIError, body []byte) {
+	var parsed struct {
+		Message json.RawMessage `json:"message"`
+		Error   string          `json:"error"`
+		Errors  []struct {
+			Field   string `json:"field"`
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &parsed) != nil {
+		e.Body = strings.TrimSpace(string(body))
+		return
+	}
+	var message string
+	var fields map[string][]string
+	if json.Unmarshal(parsed.Message, &message) == nil {
+		e.Message = message
+	} else if json.Unmarshal(parsed.Message, &fields) == nil {
+		var keys []string
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			e.Errors = append(e.Errors, k+" "+strings.Join(fields[k], ", "))
+		}
+	}
+	if e.Message == "" {
+		e.Message = parsed.Error
+	}
+	for _, fe := range parsed.Errors {
+		switch {
+		case fe.Message != "":
+			e.Errors = append(e.Errors, fe.Message)
+		case fe.Field != "":
+			e.Errors = append(e.Errors, fe.Field+" "+fe.Code)
+		}
+	}
+	if e.Message == "" && len(e.Errors) == 0 {
+		e.Body = strings.TrimSpace(string(body))
+	}
+}
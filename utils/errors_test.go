@@ -0,0 +1,68 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCheckResponse(t *testing.T) {
+	cases := []struct {
+		status  int
+		header  string
+		body    string
+		kind    error
+		message string
+	}{
+		{401, "", `{"message": "Bad credentials"}`, ErrUnauthorized, "GET u returned 401: Bad credentials"},
+		{403, "", `{"message": "Must have admin rights"}`, ErrUnauthorized, "GET u returned 403: Must have admin rights"},
+		{403, "0", `{"message": "API rate limit exceeded"}`, ErrRateLimited, "GET u returned 403: API rate limit exceeded"},
+		{429, "", `Retry later`, ErrRateLimited, "GET u returned 429: Retry later"},
+		{404, "", `{"message": "404 Project Not Found"}`, ErrNotFound, "GET u returned 404: 404 Project Not Found"},
+		{422, "", `{"message": "Validation Failed", "errors": [{"resource": "Milestone", "field": "title", "code": "already_exists"}]}`,
+			ErrValidation, "GET u returned 422: Validation Failed: title already_exists"},
+		{400, "", `{"message": {"title": ["has already been taken"], "due_date": ["is invalid"]}}`,
+			ErrValidation, "GET u returned 400: due_date is invalid, title has already been taken"},
+		{500, "", `{"error": "internal"}`, nil, "GET u returned 500: internal"},
+	}
+	for _, c := range cases {
+		resp := &http.Response{StatusCode: c.status, Status: fmt.Sprint(c.status), Header: http.Header{}}
+		if c.header != "" {
+			resp.Header.Set("X-RateLimit-Remaining", c.header)
+		}
+		err := CheckResponse("GET", "u", resp, []byte(c.body))
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != c.status {
+			t.Errorf("%d: expected an APIError, got %v", c.status, err)
+			continue
+		}
+		if errors.Unwrap(err) != c.kind {
+			t.Errorf("%d: expected %v, got %v", c.status, c.kind, errors.Unwrap(err))
+		}
+		if err.Error() != c.message {
+			t.Errorf("%d: expected %q, got %q", c.status, c.message, err.Error())
+		}
+	}
+	if err := CheckResponse("GET", "u", &http.Response{StatusCode: 201}, nil); err != nil {
+		t.Errorf("Expected no error for 201, got %v", err)
+	}
+	wrapped := fmt.Errorf("project a: %w", CheckResponse("GET", "u", &http.Response{StatusCode: 404}, nil))
+	if !errors.Is(wrapped, ErrNotFound) {
+		t.Errorf("Expected wrapped errors to match their kind")
+	}
+}
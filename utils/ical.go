@@ -0,0 +1,104 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ICalContentType is the media type of iCalendar feeds
+const ICalContentType = "text/calendar; charset=utf-8"
+
+// ICalUID returns a stable UID for the milestone title of a calendar.
+// The same title always gets the same UID, whether taken from the schedule or the project.
+func ICalUID(calendar string, title string) string {
+	sum := sha1.Sum([]byte(calendar + "\n" + title))
+	return hex.EncodeToString(sum[:10]) + "@gomiler"
+}
+
+// escapeICalText escapes a TEXT value as described in RFC 5545 section 3.3.11
+func escapeICalText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// writeICalLine writes a content line folded at 75 octets, terminated by CRLF
+func writeICalLine(w *bufio.Writer, line string) {
+	for len(line) > 75 {
+		cut := 75
+		// do not split UTF-8 sequences
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n")
+		line = " " + line[cut:]
+	}
+	w.WriteString(line + "\r\n")
+}
+
+// WriteICal writes milestones as RFC 5545 calendar with one all-day event per due date, ordered by due date.
+// Milestones without a valid due date are left out.
+func WriteICal(w io.Writer, calendar string, milestones map[string]Milestone, now time.Time) error {
+	type event struct {
+		due time.Time
+		m   Milestone
+	}
+	var events []event
+	for _, m := range milestones {
+		due, err := ParseDueDate(m.DueDate)
+		if err != nil {
+			continue
+		}
+		events = append(events, event{due, m})
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].due.Equal(events[j].due) {
+			return events[i].m.Title < events[j].m.Title
+		}
+		return events[i].due.Before(events[j].due)
+	})
+
+	bw := bufio.NewWriter(w)
+	writeICalLine(bw, "BEGIN:VCALENDAR")
+	writeICalLine(bw, "VERSION:2.0")
+	writeICalLine(bw, "PRODID:-//Okkur Labs//GoMiler//EN")
+	writeICalLine(bw, "CALSCALE:GREGORIAN")
+	writeICalLine(bw, "X-WR-CALNAME:"+escapeICalText(calendar))
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, e := range events {
+		writeICalLine(bw, "BEGIN:VEVENT")
+		writeICalLine(bw, "UID:"+ICalUID(calendar, e.m.Title))
+		writeICalLine(bw, "DTSTAMP:"+stamp)
+		writeICalLine(bw, "DTSTART;VALUE=DATE:"+e.due.Format("20060102"))
+		writeICalLine(bw, "DTEND;VALUE=DATE:"+e.due.AddDate(0, 0, 1).Format("20060102"))
+		writeICalLine(bw, "SUMMARY:"+escapeICalText(e.m.Title))
+		if description := StripMarker(e.m.Description); description != "" {
+			writeICalLine(bw, "DESCRIPTION:"+escapeICalText(description))
+		}
+		if e.m.URL != "" {
+			writeICalLine(bw, "URL:"+e.m.URL)
+		}
+		writeICalLine(bw, "TRANSP:TRANSPARENT")
+		writeICalLine(bw, "END:VEVENT")
+	}
+	writeICalLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteICal(t *testing.T) {
+	milestones := map[string]Milestone{
+		"2020-w2": {Title: "2020-w2", DueDate: "2020-01-12T00:00:00Z", Description: Marker + "\nSprint, two; done", URL: "https://example.com/2"},
+		"2020-w1": {Title: "2020-w1", DueDate: "2020-01-05"},
+		"backlog": {Title: "backlog"},
+	}
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	err := WriteICal(&buf, "namespace/project", milestones, now)
+	if err != nil {
+		t.Error(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(out, "END:VCALENDAR\r\n") {
+		t.Errorf("Expected a calendar with CRLF line endings, got %q", out)
+	}
+	if strings.Count(out, "BEGIN:VEVENT") != 2 {
+		t.Errorf("Expected %d events, got %q", 2, out)
+	}
+	first := strings.Index(out, "SUMMARY:2020-w1")
+	second := strings.Index(out, "SUMMARY:2020-w2")
+	if first < 0 || second < first {
+		t.Errorf("Expected events ordered by due date, got %q", out)
+	}
+	for _, line := range []string{
+		"DTSTART;VALUE=DATE:20200105\r\n",
+		"DTEND;VALUE=DATE:20200106\r\n",
+		"DTSTART;VALUE=DATE:20200112\r\n",
+		"DTSTAMP:20200101T120000Z\r\n",
+		`DESCRIPTION:Sprint\, two\; done` + "\r\n",
+		"URL:https://example.com/2\r\n",
+		"UID:" + ICalUID("namespace/project", "2020-w1") + "\r\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("Expected %q in %q", line, out)
+		}
+	}
+}
+
+func TestICalUID(t *testing.T) {
+	if ICalUID("a/b", "2020-w1") != ICalUID("a/b", "2020-w1") {
+		t.Errorf("Expected stable UIDs")
+	}
+	if ICalUID("a/b", "2020-w1") == ICalUID("a/c", "2020-w1") || ICalUID("a/b", "2020-w1") == ICalUID("a/b", "2020-w2") {
+		t.Errorf("Expected distinct UIDs for distinct calendars and titles")
+	}
+}
+
+func TestWriteICalLineFolding(t *testing.T) {
+	milestones := map[string]Milestone{
+		"long": {Title: strings.Repeat("ä", 60), DueDate: "2020-01-05"},
+	}
+	var buf bytes.Buffer
+	err := WriteICal(&buf, "calendar", milestones, time.Now())
+	if err != nil {
+		t.Error(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Expected lines of at most 75 octets, got %d", len(line))
+		}
+	}
+	unfolded := strings.Replace(buf.String(), "\r\n ", "", -1)
+	if !strings.Contains(unfolded, "SUMMARY:"+strings.Repeat("ä", 60)) {
+		t.Errorf("Expected folded summary to unfold, got %q", unfolded)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// DefaultNoteSections groups features and bug fixes, everything else goes to OtherSection
+const DefaultNoteSections = "Features=feature,enhancement;Bug Fixes=bug,fix"
+
+// OtherSection is the section of items without a label of any section
+const OtherSection = "Other Changes"
+
+// DefaultNotesTemplate renders release notes as Markdown
+const DefaultNotesTemplate = `# {{.Milestone.Title}}
+{{range .Sections}}
+## {{.Title}}
+
+{{range .Items}}- {{.Title}} ({{.Reference}}){{if .Author}} by @{{.Author}}{{end}}
+{{end}}{{end}}`
+
+// NoteSection holds the release note items having any of its labels
+type NoteSection struct {
+	Title  string   `json:"title" yaml:"title"`
+	Labels []string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Items  []Issue  `json:"items" yaml:"items"`
+}
+
+// Notes are the release notes of a milestone
+type Notes struct {
+	Milestone  Milestone     `json:"-" yaml:"-"`
+	Title      string        `json:"title" yaml:"title"`
+	Sections   []NoteSection `json:"sections" yaml:"sections"`
+	Markdown   string        `json:"markdown" yaml:"markdown"`
+	ReleaseURL string        `json:"release_url,omitempty" yaml:"release_url,omitempty"`
+}
+
+// ParseNoteSections parses sections in the form "Title=label,label;Title=label"
+func ParseNoteSections(spec string) ([]NoteSection, error) {
+	sections := []NoteSection{}
+	for _, s := range strings.Split(spec, ";") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("Error: Invalid note section %q, expected Title=label,label", s)
+		}
+		section := NoteSection{Title: strings.TrimSpace(parts[0])}
+		for _, label := range strings.Split(parts[1], ",") {
+			label = strings.TrimSpace(label)
+			if label != "" {
+				section.Labels = append(section.Labels, label)
+			}
+		}
+		if len(section.Labels) == 0 {
+			return nil, fmt.Errorf("Error: Note section %s has no labels", section.Title)
+		}
+		sections = append(sections, section)
+	}
+	return sections, nil
+}
+
+// GroupNotes puts each item into the first section having one of its labels, ordered by number.
+// Items matching no section go to OtherSection and empty sections are left out.
+func GroupNotes(items []Issue, sections []NoteSection) []NoteSection {
+	sorted := make([]Issue, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Number < sorted[j].Number
+	})
+	grouped := make([]NoteSection, len(sections))
+	copy(grouped, sections)
+	other := NoteSection{Title: OtherSection}
+	for _, item := range sorted {
+		matched := false
+		for i := range grouped {
+			if hasAnyLabel(item, grouped[i].Labels) {
+				grouped[i].Items = append(grouped[i].Items, item)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			other.Items = append(other.Items, item)
+		}
+	}
+	result := []NoteSection{}
+	for _, section := range append(grouped, other) {
+		if len(section.Items) > 0 {
+			result = append(result, section)
+		}
+	}
+	return result
+}
+
+func hasAnyLabel(item Issue, labels []string) bool {
+	for _, l := range item.Labels {
+		for _, label := range labels {
+			if strings.EqualFold(l, label) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewNotes returns the notes of a milestone with its items grouped into sections
+func NewNotes(m Milestone, items []Issue, sections []NoteSection) Notes {
+	return Notes{
+		Milestone: m,
+		Title:     m.Title,
+		Sections:  GroupNotes(items, sections),
+	}
+}
+
+// RenderNotes renders the Markdown of notes from a text/template
+func RenderNotes(notes *Notes, tmpl string) error {
+	t, err := template.New("notes").Parse(tmpl)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err = t.Execute(&buf, notes)
+	if err != nil {
+		return err
+	}
+	notes.Markdown = buf.String()
+	return nil
+}
+
+// WriteNotes writes the Markdown of notes for text and table output, or the notes as json or yaml
+func WriteNotes(w io.Writer, notes Notes, format string) error {
+	switch format {
+	case "text", "table":
+		_, err := io.WriteString(w, notes.Markdown)
+		return err
+	case "json", "yaml":
+		return writeEncoded(w, notes, format)
+	}
+	return fmt.Errorf("Error: Invalid output format %s", format)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func mockNoteItems() []Issue {
+	return []Issue{
+		{Kind: "merge_request", Number: 3, Title: "Add export", Labels: []string{"Feature"}, Author: "tanuki"},
+		{Kind: "issue", Number: 2, Title: "Fix crash", Labels: []string{"bug", "feature"}},
+		{Kind: "issue", Number: 1, Title: "Update docs"},
+	}
+}
+
+func TestParseNoteSections(t *testing.T) {
+	sections, err := ParseNoteSections(DefaultNoteSections)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(sections) != 2 || sections[1].Title != "Bug Fixes" || len(sections[1].Labels) != 2 {
+		t.Errorf("Unexpected sections %v", sections)
+	}
+	for _, spec := range []string{"Features", "=bug", "Features= , "} {
+		_, err = ParseNoteSections(spec)
+		if err == nil {
+			t.Errorf("Expected to get an error for sections %q", spec)
+		}
+	}
+}
+
+func TestGroupNotes(t *testing.T) {
+	sections, _ := ParseNoteSections("Bug Fixes=bug;Features=feature;Security=security")
+	grouped := GroupNotes(mockNoteItems(), sections)
+	if len(grouped) != 3 {
+		t.Fatalf("Expected %d, got %d", 3, len(grouped))
+	}
+	expected := []struct {
+		title  string
+		number int
+	}{
+		{"Bug Fixes", 2},
+		{"Features", 3},
+		{OtherSection, 1},
+	}
+	for i, e := range expected {
+		if grouped[i].Title != e.title || len(grouped[i].Items) != 1 || grouped[i].Items[0].Number != e.number {
+			t.Errorf("Expected %s with #%d, got %v", e.title, e.number, grouped[i])
+		}
+	}
+}
+
+func TestRenderNotes(t *testing.T) {
+	sections, _ := ParseNoteSections(DefaultNoteSections)
+	notes := NewNotes(Milestone{Title: "2020-w1"}, mockNoteItems(), sections)
+	err := RenderNotes(&notes, DefaultNotesTemplate)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "# 2020-w1\n\n" +
+		"## Features\n\n" +
+		"- Fix crash (#2)\n" +
+		"- Add export (!3) by @tanuki\n\n" +
+		"## Other Changes\n\n" +
+		"- Update docs (#1)\n"
+	if notes.Markdown != expected {
+		t.Errorf("Expected %q, got %q", expected, notes.Markdown)
+	}
+	err = RenderNotes(&notes, "{{.Unknown}")
+	if err == nil {
+		t.Errorf("Expected to get an error when the template is invalid")
+	}
+
+	var buf bytes.Buffer
+	err = WriteNotes(&buf, notes, "json")
+	if err != nil {
+		t.Error(err)
+	}
+	var decoded Notes
+	err = json.Unmarshal(buf.Bytes(), &decoded)
+	if err != nil {
+		t.Error(err)
+	}
+	if decoded.Title != "2020-w1" || len(decoded.Sections) != 2 {
+		t.Errorf("Unexpected notes %v", decoded)
+	}
+}
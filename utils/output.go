@@ -0,0 +1,292 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ValidateOutputFormat checks if format is a supported output format
+func ValidateOutputFormat(format string) error {
+	switch format {
+	case "text", "json", "yaml", "table":
+		return nil
+	}
+	return fmt.Errorf("Error: Invalid output format %s", format)
+}
+
+// MilestoneResult is a milestone affected by a command
+type MilestoneResult struct {
+	Title   string `json:"title" yaml:"title"`
+	ID      string `json:"id,omitempty" yaml:"id,omitempty"`
+	Number  int    `json:"number,omitempty" yaml:"number,omitempty"`
+	URL     string `json:"url,omitempty" yaml:"url,omitempty"`
+	DueDate string `json:"due_date,omitempty" yaml:"due_date,omitempty"`
+	State   string `json:"state,omitempty" yaml:"state,omitempty"`
+	// Title of a renamed milestone after renaming
+	NewTitle string `json:"new_title,omitempty" yaml:"new_title,omitempty"`
+	// Reason the milestone was skipped or failed, or what changed
+	Detail string `json:"detail,omitempty" yaml:"detail,omitempty"`
+}
+
+// NewMilestoneResult returns the result of a milestone with detail
+func NewMilestoneResult(m Milestone, detail string) MilestoneResult {
+	return MilestoneResult{
+		Title:   m.Title,
+		ID:      m.ID,
+		Number:  m.Number,
+		URL:     m.URL,
+		DueDate: m.DueDate,
+		State:   m.State,
+		Detail:  detail,
+	}
+}
+
+// MilestoneResults returns the results of milestones ordered by title
+func MilestoneResults(milestones map[string]Milestone) []MilestoneResult {
+	var keys []string
+	for k := range milestones {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	results := []MilestoneResult{}
+	for _, key := range keys {
+		results = append(results, NewMilestoneResult(milestones[key], ""))
+	}
+	return results
+}
+
+// ReasonResults returns the results of milestones with a reason each, ordered by title.
+// The milestone data is taken from milestones if present.
+func ReasonResults(reasons map[string]string, milestones map[string]Milestone) []MilestoneResult {
+	var keys []string
+	for k := range reasons {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	results := []MilestoneResult{}
+	for _, key := range keys {
+		m, ok := milestones[key]
+		if !ok {
+			m = Milestone{Title: key}
+		}
+		results = append(results, NewMilestoneResult(m, reasons[key]))
+	}
+	return results
+}
+
+// Progress is the progress of a milestone in a report
+type Progress struct {
+	Title           string  `json:"title" yaml:"title"`
+	URL             string  `json:"url,omitempty" yaml:"url,omitempty"`
+	DueDate         string  `json:"due_date" yaml:"due_date"`
+	OpenIssues      int     `json:"open_issues" yaml:"open_issues"`
+	ClosedIssues    int     `json:"closed_issues" yaml:"closed_issues"`
+	PercentComplete float64 `json:"percent_complete" yaml:"percent_complete"`
+	DaysRemaining   int     `json:"days_remaining" yaml:"days_remaining"`
+}
+
+// Result holds the milestones affected by a command
+type Result struct {
+	// Project and error of a run against one of several projects
+	Project     string            `json:"project,omitempty" yaml:"project,omitempty"`
+	Error       string            `json:"error,omitempty" yaml:"error,omitempty"`
+	Created     []MilestoneResult `json:"created,omitempty" yaml:"created,omitempty"`
+	Failed      []MilestoneResult `json:"failed,omitempty" yaml:"failed,omitempty"`
+	Drifted     []MilestoneResult `json:"drifted,omitempty" yaml:"drifted,omitempty"`
+	Reactivated []MilestoneResult `json:"reactivated,omitempty" yaml:"reactivated,omitempty"`
+	Skipped     []MilestoneResult `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	RolledOver  []RolledOverItem  `json:"rolled_over,omitempty" yaml:"rolled_over,omitempty"`
+	Closed      []MilestoneResult `json:"closed,omitempty" yaml:"closed,omitempty"`
+	Pruned      []MilestoneResult `json:"pruned,omitempty" yaml:"pruned,omitempty"`
+	Renamed     []MilestoneResult `json:"renamed,omitempty" yaml:"renamed,omitempty"`
+	Unmatched   []MilestoneResult `json:"unmatched,omitempty" yaml:"unmatched,omitempty"`
+	Adopted     []MilestoneResult `json:"adopted,omitempty" yaml:"adopted,omitempty"`
+	Progress    []Progress        `json:"progress,omitempty" yaml:"progress,omitempty"`
+	// Actions not applied before the run was cancelled
+	Pending []MilestoneResult `json:"pending,omitempty" yaml:"pending,omitempty"`
+	// Retries and rate limit waits of the API requests
+	Throttled *Throttling `json:"throttled,omitempty" yaml:"throttled,omitempty"`
+}
+
+type resultSection struct {
+	name       string
+	heading    string
+	milestones []MilestoneResult
+}
+
+func (r Result) sections() []resultSection {
+	return []resultSection{
+		{"created", "New milestones:", r.Created},
+		{"failed", "Failed milestones:", r.Failed},
+		{"drifted", "Drifted milestones:", r.Drifted},
+		{"reactivated", "Reactivated milestones:", r.Reactivated},
+		{"skipped", "Skipped reactivation:", r.Skipped},
+		{"closed", "Closed milestones:", r.Closed},
+		{"pruned", "Pruned milestones:", r.Pruned},
+		{"renamed", "Renamed milestones:", r.Renamed},
+		{"unmatched", "Unmatched milestones:", r.Unmatched},
+		{"adopted", "Adopted milestones:", r.Adopted},
+		{"pending", "Not applied:", r.Pending},
+	}
+}
+
+// Empty reports whether no milestones were affected
+func (r Result) Empty() bool {
+	for _, s := range r.sections() {
+		if len(s.milestones) > 0 {
+			return false
+		}
+	}
+	return len(r.RolledOver) == 0 && len(r.Progress) == 0
+}
+
+func (r Result) writeText(w io.Writer) error {
+	for _, s := range r.sections() {
+		if len(s.milestones) == 0 {
+			continue
+		}
+		fmt.Fprintln(w, s.heading)
+		for _, m := range s.milestones {
+			line := "Title: " + m.Title
+			if m.NewTitle != "" {
+				line += " -> " + m.NewTitle
+			}
+			if m.DueDate != "" {
+				line += " - Due Date: " + m.DueDate
+			}
+			if m.Detail != "" {
+				line += " - " + m.Detail
+			}
+			if m.URL != "" {
+				line += " - " + m.URL
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+	if len(r.RolledOver) > 0 {
+		fmt.Fprintln(w, "Rolled over:")
+		for _, item := range r.RolledOver {
+			fmt.Fprintf(w, "%s %s %s: %s -> %s\n", item.Kind, item.Reference(), item.Title, item.From, item.To)
+		}
+	}
+	if len(r.Progress) > 0 {
+		fmt.Fprintln(w, "Milestone progress:")
+		for _, p := range r.Progress {
+			fmt.Fprintf(w, "Title: %s - Due Date: %s - Open: %d - Closed: %d - Complete: %.0f%% - Days Remaining: %d\n",
+				p.Title, p.DueDate, p.OpenIssues, p.ClosedIssues, p.PercentComplete, p.DaysRemaining)
+		}
+	}
+	r.writeThrottled(w)
+	return nil
+}
+
+func (r Result) writeThrottled(w io.Writer) {
+	if r.Throttled != nil {
+		fmt.Fprintf(w, "Throttled: %s\n", r.Throttled)
+	}
+}
+
+func (r Result) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if len(r.Progress) > 0 {
+		fmt.Fprintln(tw, "TITLE\tDUE DATE\tOPEN\tCLOSED\tCOMPLETE\tDAYS REMAINING\tURL")
+		for _, p := range r.Progress {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.0f%%\t%d\t%s\n",
+				p.Title, p.DueDate, p.OpenIssues, p.ClosedIssues, p.PercentComplete, p.DaysRemaining, p.URL)
+		}
+		err := tw.Flush()
+		r.writeThrottled(w)
+		return err
+	}
+	fmt.Fprintln(tw, "RESULT\tTITLE\tID\tDUE DATE\tDETAIL\tURL")
+	for _, s := range r.sections() {
+		for _, m := range s.milestones {
+			detail := m.Detail
+			if m.NewTitle != "" {
+				detail = "-> " + m.NewTitle
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", s.name, m.Title, m.ID, m.DueDate, detail, m.URL)
+		}
+	}
+	for _, item := range r.RolledOver {
+		fmt.Fprintf(tw, "rolled_over\t%s %s %s\t\t\t%s -> %s\t\n", item.Kind, item.Reference(), item.Title, item.From, item.To)
+	}
+	err := tw.Flush()
+	r.writeThrottled(w)
+	return err
+}
+
+// WriteOutput writes result as text, json, yaml or table
+func WriteOutput(w io.Writer, result Result, format string) error {
+	switch format {
+	case "text":
+		return result.writeText(w)
+	case "table":
+		return result.writeTable(w)
+	case "json", "yaml":
+		return writeEncoded(w, result, format)
+	}
+	return fmt.Errorf("Error: Invalid output format %s", format)
+}
+
+// WriteResults writes the results of several projects as text, json, yaml or table
+func WriteResults(w io.Writer, results []Result, format string) error {
+	switch format {
+	case "text", "table":
+		for _, r := range results {
+			fmt.Fprintf(w, "Project: %s\n", r.Project)
+			if r.Error != "" {
+				fmt.Fprintf(w, "Error: %s\n", r.Error)
+			}
+			if r.Empty() {
+				if r.Error == "" {
+					fmt.Fprintln(w, "No milestone changes needed")
+				}
+				r.writeThrottled(w)
+				continue
+			}
+			err := WriteOutput(w, r, format)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json", "yaml":
+		return writeEncoded(w, results, format)
+	}
+	return fmt.Errorf("Error: Invalid output format %s", format)
+}
+
+// writeEncoded writes v as json or yaml
+func writeEncoded(w io.Writer, v interface{}, format string) error {
+	if format == "yaml" {
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
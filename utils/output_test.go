@@ -0,0 +1,153 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func mockResult() Result {
+	return Result{
+		Created: MilestoneResults(map[string]Milestone{
+			"2020-01-02": {Title: "2020-01-02", ID: "2", URL: "https://example.com/2", DueDate: "2020-01-02"},
+			"2020-01-01": {Title: "2020-01-01", ID: "1", URL: "https://example.com/1", DueDate: "2020-01-01"},
+		}),
+		Failed: ReasonResults(map[string]string{"2020-01-03": "403 Forbidden"}, nil),
+		RolledOver: []RolledOverItem{
+			{Kind: "issue", Number: 1, Title: "Bug", From: "2019-12-31", To: "2020-01-01"},
+			{Kind: "merge_request", Number: 2, Title: "Fix", From: "2019-12-31", To: "2020-01-01"},
+		},
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, format := range []string{"text", "json", "yaml", "table"} {
+		if err := ValidateOutputFormat(format); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := ValidateOutputFormat("xml"); err == nil {
+		t.Errorf("Expected to get an error when output format is invalid")
+	}
+}
+
+func TestMilestoneResults(t *testing.T) {
+	results := mockResult().Created
+	if len(results) != 2 || results[0].Title != "2020-01-01" || results[0].URL != "https://example.com/1" {
+		t.Errorf("Expected results ordered by title, got %v", results)
+	}
+	failed := mockResult().Failed
+	if len(failed) != 1 || failed[0].Title != "2020-01-03" || failed[0].Detail != "403 Forbidden" {
+		t.Errorf("Expected failed result with reason, got %v", failed)
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	result := mockResult()
+	if result.Empty() || !(Result{}).Empty() {
+		t.Errorf("Expected only the empty result to be empty")
+	}
+	var buf bytes.Buffer
+	err := WriteOutput(&buf, result, "text")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "New milestones:\n" +
+		"Title: 2020-01-01 - Due Date: 2020-01-01 - https://example.com/1\n" +
+		"Title: 2020-01-02 - Due Date: 2020-01-02 - https://example.com/2\n" +
+		"Failed milestones:\n" +
+		"Title: 2020-01-03 - 403 Forbidden\n" +
+		"Rolled over:\n" +
+		"issue #1 Bug: 2019-12-31 -> 2020-01-01\n" +
+		"merge_request !2 Fix: 2019-12-31 -> 2020-01-01\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	err = WriteOutput(&buf, result, "json")
+	if err != nil {
+		t.Error(err)
+	}
+	var decoded Result
+	err = json.Unmarshal(buf.Bytes(), &decoded)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(decoded.Created) != 2 || decoded.Created[1].ID != "2" || len(decoded.RolledOver) != 2 {
+		t.Errorf("Expected %v, got %v", result, decoded)
+	}
+	if strings.Contains(buf.String(), "reactivated") {
+		t.Errorf("Expected empty sections to be omitted, got %s", buf.String())
+	}
+
+	buf.Reset()
+	err = WriteOutput(&buf, result, "yaml")
+	if err != nil {
+		t.Error(err)
+	}
+	decoded = Result{}
+	err = yaml.Unmarshal(buf.Bytes(), &decoded)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(decoded.Failed) != 1 || decoded.Failed[0].Detail != "403 Forbidden" {
+		t.Errorf("Expected %v, got %v", result, decoded)
+	}
+
+	buf.Reset()
+	err = WriteOutput(&buf, result, "table")
+	if err != nil {
+		t.Error(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 || !strings.HasPrefix(lines[0], "RESULT") || !strings.HasPrefix(lines[3], "failed") || !strings.Contains(lines[5], "merge_request !2 Fix") {
+		t.Errorf("Unexpected table %s", buf.String())
+	}
+
+	err = WriteOutput(&buf, result, "xml")
+	if err == nil {
+		t.Errorf("Expected to get an error when output format is invalid")
+	}
+}
+
+func TestWriteResultsThrottled(t *testing.T) {
+	results := []Result{{Project: "namespace/project", Throttled: &Throttling{Retries: 2, RateLimited: 1, Waited: "1m3s"}}}
+	var buf bytes.Buffer
+	err := WriteResults(&buf, results, "text")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "Project: namespace/project\n" +
+		"No milestone changes needed\n" +
+		"Throttled: 2 retries, 1 rate limit waits, waited 1m3s\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	err = WriteResults(&buf, results, "json")
+	if err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(buf.String(), `"throttled"`) || !strings.Contains(buf.String(), `"waited": "1m3s"`) {
+		t.Errorf("Expected the throttling in %s", buf.String())
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// PlanVersion is the version of the plan file format
+const PlanVersion = 1
+
+// Action kinds in the order they are applied
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionReopen   = "reopen"
+	ActionRollover = "rollover"
+	ActionClose    = "close"
+	ActionDelete   = "delete"
+)
+
+// ActionKinds lists the action kinds in the order they are applied
+var ActionKinds = []string{ActionCreate, ActionUpdate, ActionReopen, ActionRollover, ActionClose, ActionDelete}
+
+// ErrStalePlan is returned when the milestones of a project changed since planning
+var ErrStalePlan = errors.New("Error: milestones changed since planning, create a new plan")
+
+// Action is a change to a milestone
+type Action struct {
+	Kind      string    `json:"kind" yaml:"kind"`
+	Milestone Milestone `json:"milestone" yaml:"milestone"`
+	// Desired milestone and changed fields of an update
+	Desired *Milestone `json:"desired,omitempty" yaml:"desired,omitempty"`
+	Fields  []string   `json:"fields,omitempty" yaml:"fields,omitempty"`
+	// Title of the milestone open issues are rolled over to, with the comment and label to add to them
+	Target  string `json:"target,omitempty" yaml:"target,omitempty"`
+	Comment string `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Label   string `json:"label,omitempty" yaml:"label,omitempty"`
+}
+
+// Detail describes an action
+func (a Action) Detail() string {
+	switch a.Kind {
+	case ActionUpdate:
+		if a.Desired != nil {
+			return Drift{Existing: a.Milestone, Desired: *a.Desired, Fields: a.Fields}.Detail()
+		}
+	case ActionRollover:
+		return "open issues -> " + a.Target
+	}
+	return ""
+}
+
+// Plan holds the actions to apply to the milestones of a project
+type Plan struct {
+	Version   int       `json:"version" yaml:"version"`
+	API       string    `json:"api" yaml:"api"`
+	BaseURL   string    `json:"base_url" yaml:"base_url"`
+	Project   string    `json:"project" yaml:"project"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	// Fingerprint of the milestones of the project at planning time
+	Fingerprint string   `json:"fingerprint" yaml:"fingerprint"`
+	Actions     []Action `json:"actions" yaml:"actions"`
+	// Drifted milestones that are only reported and reactivations skipped by policy
+	Drifted []MilestoneResult `json:"drifted,omitempty" yaml:"drifted,omitempty"`
+	Skipped []MilestoneResult `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+}
+
+// NewPlan returns an empty plan for the milestones of a project
+func NewPlan(api string, baseURL string, project string, milestones map[string]Milestone, now time.Time) Plan {
+	return Plan{
+		Version:     PlanVersion,
+		API:         api,
+		BaseURL:     baseURL,
+		Project:     project,
+		CreatedAt:   now.UTC(),
+		Fingerprint: Fingerprint(milestones),
+		Actions:     []Action{},
+	}
+}
+
+// Add adds an action of kind for each of milestones, ordered by title
+func (p *Plan) Add(kind string, milestones map[string]Milestone) {
+	for _, r := range MilestoneResults(milestones) {
+		p.Actions = append(p.Actions, Action{Kind: kind, Milestone: milestones[r.Title]})
+	}
+}
+
+// Milestones returns the milestones of the actions of kind
+func (p Plan) Milestones(kind string) map[string]Milestone {
+	milestones := map[string]Milestone{}
+	for _, a := range p.Actions {
+		if a.Kind == kind {
+			milestones[a.Milestone.Title] = a.Milestone
+		}
+	}
+	return milestones
+}
+
+// Drifts returns the drifts of the update actions
+func (p Plan) Drifts() []Drift {
+	drifts := []Drift{}
+	for _, a := range p.Actions {
+		if a.Kind == ActionUpdate && a.Desired != nil {
+			drifts = append(drifts, Drift{Existing: a.Milestone, Desired: *a.Desired, Fields: a.Fields})
+		}
+	}
+	return drifts
+}
+
+// Fingerprint returns a hash of the milestone fields changed by actions.
+// Issue counts are left out, so issues being worked on do not invalidate a plan.
+func Fingerprint(milestones map[string]Milestone) string {
+	var keys []string
+	for k := range milestones {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		m := milestones[k]
+		due := m.DueDate
+		if d, err := ParseDueDate(m.DueDate); err == nil {
+			due = d.Format("2006-01-02")
+		}
+		fmt.Fprintf(h, "%q %q %q %q %q %q %d\n", m.Title, m.ID, m.State, due, m.StartDate, m.Description, m.Number)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Check returns an error if the plan cannot be applied to the current milestones of a project
+func (p Plan) Check(api string, baseURL string, project string, milestones map[string]Milestone) error {
+	if p.Version != PlanVersion {
+		return fmt.Errorf("Error: unsupported plan version %d", p.Version)
+	}
+	if p.API != api || p.BaseURL != baseURL || p.Project != project {
+		return fmt.Errorf("Error: plan is for %s project %s at %s, not %s project %s at %s", p.API, p.Project, p.BaseURL, api, project, baseURL)
+	}
+	if p.Fingerprint != Fingerprint(milestones) {
+		return ErrStalePlan
+	}
+	return nil
+}
+
+// SavePlan writes a plan as JSON
+func SavePlan(w io.Writer, p Plan) error {
+	return writeEncoded(w, p, "json")
+}
+
+// LoadPlan reads a plan written by SavePlan
+func LoadPlan(r io.Reader) (Plan, error) {
+	var p Plan
+	err := json.NewDecoder(r).Decode(&p)
+	return p, err
+}
+
+// WritePlan writes the actions of a plan as text, table, json or yaml
+func WritePlan(w io.Writer, p Plan, format string) error {
+	switch format {
+	case "text":
+		if len(p.Actions) == 0 {
+			fmt.Fprintln(w, "No changes planned")
+		} else {
+			var counts []string
+			for _, kind := range ActionKinds {
+				if n := len(p.Milestones(kind)); n > 0 {
+					counts = append(counts, fmt.Sprintf("%d to %s", n, kind))
+				}
+			}
+			fmt.Fprintf(w, "Plan: %s\n", strings.Join(counts, ", "))
+			for _, a := range p.Actions {
+				line := a.Kind + " " + a.Milestone.Title
+				if a.Milestone.DueDate != "" {
+					line += " - Due Date: " + a.Milestone.DueDate
+				}
+				if detail := a.Detail(); detail != "" {
+					line += " - " + detail
+				}
+				fmt.Fprintln(w, line)
+			}
+		}
+		return Result{Drifted: p.Drifted, Skipped: p.Skipped}.writeText(w)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ACTION\tTITLE\tID\tDUE DATE\tDETAIL")
+		for _, a := range p.Actions {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.Kind, a.Milestone.Title, a.Milestone.ID, a.Milestone.DueDate, a.Detail())
+		}
+		for _, m := range p.Drifted {
+			fmt.Fprintf(tw, "drifted\t%s\t%s\t%s\t%s\n", m.Title, m.ID, m.DueDate, m.Detail)
+		}
+		for _, m := range p.Skipped {
+			fmt.Fprintf(tw, "skipped\t%s\t%s\t%s\t%s\n", m.Title, m.ID, m.DueDate, m.Detail)
+		}
+		return tw.Flush()
+	case "json", "yaml":
+		return writeEncoded(w, p, format)
+	}
+	return fmt.Errorf("Error: Invalid output format %s", format)
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mockPlanMilestones() map[string]Milestone {
+	return map[string]Milestone{
+		"2020-01-01": {Title: "2020-01-01", ID: "1", State: "active", DueDate: "2020-01-01", Description: Marker},
+		"2020-01-02": {Title: "2020-01-02", ID: "2", State: "closed", DueDate: "2020-01-02", Description: Marker},
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	milestones := mockPlanMilestones()
+	fingerprint := Fingerprint(milestones)
+	counted := milestones["2020-01-01"]
+	counted.OpenIssues = 3
+	milestones["2020-01-01"] = counted
+	if Fingerprint(milestones) != fingerprint {
+		t.Errorf("Expected issue counts to be left out of the fingerprint")
+	}
+	closed := milestones["2020-01-01"]
+	closed.State = "closed"
+	milestones["2020-01-01"] = closed
+	if Fingerprint(milestones) == fingerprint {
+		t.Errorf("Expected a changed state to change the fingerprint")
+	}
+	rfc := mockPlanMilestones()
+	m := rfc["2020-01-01"]
+	m.DueDate = "2020-01-01T00:00:00Z"
+	rfc["2020-01-01"] = m
+	if Fingerprint(rfc) != fingerprint {
+		t.Errorf("Expected due dates to be compared by day")
+	}
+}
+
+func TestPlanCheck(t *testing.T) {
+	milestones := mockPlanMilestones()
+	p := NewPlan("gitlab", "https://gitlab.com/api/v4", "1", milestones, time.Now())
+	p.Add(ActionReopen, map[string]Milestone{"2020-01-02": milestones["2020-01-02"]})
+
+	var buf bytes.Buffer
+	err := SavePlan(&buf, p)
+	if err != nil {
+		t.Error(err)
+	}
+	loaded, err := LoadPlan(&buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(loaded.Milestones(ActionReopen)) != 1 {
+		t.Errorf("Expected the loaded plan to reopen %d milestone, got %v", 1, loaded.Actions)
+	}
+	err = loaded.Check("gitlab", "https://gitlab.com/api/v4", "1", milestones)
+	if err != nil {
+		t.Error(err)
+	}
+	err = loaded.Check("gitlab", "https://gitlab.com/api/v4", "2", milestones)
+	if err == nil {
+		t.Errorf("Expected to get an error when the plan is for another project")
+	}
+	delete(milestones, "2020-01-01")
+	err = loaded.Check("gitlab", "https://gitlab.com/api/v4", "1", milestones)
+	if err != ErrStalePlan {
+		t.Errorf("Expected %v, got %v", ErrStalePlan, err)
+	}
+}
+
+func TestWritePlan(t *testing.T) {
+	milestones := mockPlanMilestones()
+	p := NewPlan("gitlab", "https://gitlab.com/api/v4", "1", milestones, time.Now())
+	desired := milestones["2020-01-01"]
+	desired.DueDate = "2020-01-03"
+	p.Add(ActionCreate, map[string]Milestone{"2020-01-03": {Title: "2020-01-03", DueDate: "2020-01-03"}})
+	p.Actions = append(p.Actions, Action{Kind: ActionUpdate, Milestone: milestones["2020-01-01"], Desired: &desired, Fields: []string{"due date"}})
+	p.Skipped = ReasonResults(map[string]string{"2020-01-02": "reactivation is disabled"}, milestones)
+
+	var buf bytes.Buffer
+	err := WritePlan(&buf, p, "text")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "Plan: 1 to create, 1 to update\n" +
+		"create 2020-01-03 - Due Date: 2020-01-03\n" +
+		"update 2020-01-01 - Due Date: 2020-01-01 - Due Date: 2020-01-01 -> 2020-01-03\n" +
+		"Skipped reactivation:\n" +
+		"Title: 2020-01-02 - Due Date: 2020-01-02 - reactivation is disabled\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	err = WritePlan(&buf, NewPlan("gitlab", "", "1", nil, time.Now()), "text")
+	if err != nil {
+		t.Error(err)
+	}
+	if !strings.HasPrefix(buf.String(), "No changes planned") {
+		t.Errorf("Expected no changes, got %q", buf.String())
+	}
+	err = WritePlan(&buf, p, "xml")
+	if err == nil {
+		t.Errorf("Expected to get an error when output format is invalid")
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Repository is a GitHub repository or GitLab project of a namespace
+type Repository struct {
+	// Path relative to the listed namespace, e.g. subgroup/project
+	Path string
+	// Namespace and name of the repository, the namespace includes subgroups
+	Namespace string
+	Name      string
+	// GitLab project ID or GitHub repository name
+	ID       string
+	Archived bool
+	Topics   []string
+}
+
+// RepositoryFilter selects repositories by glob, topic and archived state
+type RepositoryFilter struct {
+	// Globs matched against the path of repositories, an empty Include matches all
+	Include []string
+	Exclude []string
+	// Repositories need at least one of the topics if any are set
+	Topics []string
+	// Include archived repositories
+	Archived bool
+}
+
+// ParseList splits a comma separated list, dropping empty elements
+func ParseList(s string) []string {
+	list := []string{}
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// Validate checks the syntax of the globs
+func (f RepositoryFilter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Error: Invalid glob %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Match reports whether a repository is selected by the filter
+func (f RepositoryFilter) Match(r Repository) bool {
+	if r.Archived && !f.Archived {
+		return false
+	}
+	if len(f.Include) > 0 && !matchAny(f.Include, r.Path) {
+		return false
+	}
+	if matchAny(f.Exclude, r.Path) {
+		return false
+	}
+	if len(f.Topics) == 0 {
+		return true
+	}
+	for _, topic := range r.Topics {
+		for _, t := range f.Topics {
+			if strings.EqualFold(topic, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterRepositories returns the repositories selected by the filter, ordered by path
+func FilterRepositories(repositories []Repository, f RepositoryFilter) []Repository {
+	selected := []Repository{}
+	for _, r := range repositories {
+		if f.Match(r) {
+			selected = append(selected, r)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].Path < selected[j].Path
+	})
+	return selected
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterRepositories(t *testing.T) {
+	repositories := []Repository{
+		{Path: "web", Topics: []string{"Gomiler"}},
+		{Path: "app"},
+		{Path: "sub/lib", Topics: []string{"gomiler"}},
+		{Path: "sub/old", Archived: true, Topics: []string{"gomiler"}},
+	}
+	cases := map[string]struct {
+		filter   RepositoryFilter
+		expected []string
+	}{
+		"all":      {RepositoryFilter{}, []string{"app", "sub/lib", "web"}},
+		"archived": {RepositoryFilter{Archived: true}, []string{"app", "sub/lib", "sub/old", "web"}},
+		"include":  {RepositoryFilter{Include: []string{"sub/*"}}, []string{"sub/lib"}},
+		"exclude":  {RepositoryFilter{Exclude: []string{"sub/*", "web"}}, []string{"app"}},
+		"topics":   {RepositoryFilter{Topics: []string{"gomiler"}}, []string{"sub/lib", "web"}},
+	}
+	for name, c := range cases {
+		paths := []string{}
+		for _, r := range FilterRepositories(repositories, c.filter) {
+			paths = append(paths, r.Path)
+		}
+		if !reflect.DeepEqual(paths, c.expected) {
+			t.Errorf("%s: expected %v, got %v", name, c.expected, paths)
+		}
+	}
+}
+
+func TestRepositoryFilterValidate(t *testing.T) {
+	if err := (RepositoryFilter{Include: []string{"sub/*"}}).Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := (RepositoryFilter{Exclude: []string{"[a"}}).Validate(); err == nil {
+		t.Errorf("Expected to get an error for an invalid glob")
+	}
+}
+
+func TestParseList(t *testing.T) {
+	list := ParseList(" a, b,,c ")
+	if !reflect.DeepEqual(list, []string{"a", "b", "c"}) {
+		t.Errorf("Unexpected list %v", list)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// Wednesday
+	now := time.Date(2018, 1, 3, 10, 7, 30, 0, time.UTC)
+	cases := map[string]time.Time{
+		"@every 1h":    time.Date(2018, 1, 3, 11, 7, 30, 0, time.UTC),
+		"30m":          time.Date(2018, 1, 3, 10, 37, 30, 0, time.UTC),
+		"@hourly":      time.Date(2018, 1, 3, 11, 0, 0, 0, time.UTC),
+		"@daily":       time.Date(2018, 1, 4, 0, 0, 0, 0, time.UTC),
+		"@weekly":      time.Date(2018, 1, 7, 0, 0, 0, 0, time.UTC),
+		"@monthly":     time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC),
+		"*/15 * * * *": time.Date(2018, 1, 3, 10, 15, 0, 0, time.UTC),
+		"0 6 * * 1-5":  time.Date(2018, 1, 4, 6, 0, 0, 0, time.UTC),
+		"30 9 * * 7":   time.Date(2018, 1, 7, 9, 30, 0, 0, time.UTC),
+		"0 0 15 3 *":   time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC),
+		// either day field matches if both are restricted
+		"0 12 10 * 5":   time.Date(2018, 1, 5, 12, 0, 0, 0, time.UTC),
+		"5,10 10 * * *": time.Date(2018, 1, 3, 10, 10, 0, 0, time.UTC),
+	}
+	for spec, expected := range cases {
+		s, err := ParseSchedule(spec)
+		if err != nil {
+			t.Errorf("%s: %v", spec, err)
+			continue
+		}
+		if next := s.Next(now); !next.Equal(expected) {
+			t.Errorf("%s: expected %v, got %v", spec, expected, next)
+		}
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{"", "10s", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("Expected to get an error for %q", spec)
+		}
+	}
+	s, err := ParseSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Next(time.Now()).IsZero() {
+		t.Errorf("Expected no next run on February 30th")
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Title formats are built from the placeholders {year}, {month}, {day} and {week}.
+// Formats containing {week} use the ISO year and week of the due date.
+var titlePlaceholders = map[string]string{
+	"{year}":  `(\d{4})`,
+	"{month}": `(\d{2})`,
+	"{day}":   `(\d{2})`,
+	"{week}":  `(\d{1,2})`,
+}
+
+// Rename maps an existing milestone to a new title
+type Rename struct {
+	Milestone Milestone
+	Title     string
+}
+
+// DefaultTitleFormat returns the title format gomiler uses for interval
+func DefaultTitleFormat(interval string) string {
+	switch interval {
+	case "daily":
+		return "{year}-{month}-{day}"
+	case "weekly":
+		return "{year}-w{week}"
+	case "monthly":
+		return "{year}-{month}"
+	}
+	return ""
+}
+
+// ValidateTitleFormat checks that format contains the placeholders needed to tell milestones of interval apart
+func ValidateTitleFormat(format string, interval string) error {
+	var required, forbidden []string
+	switch interval {
+	case "daily":
+		required = []string{"{year}", "{month}", "{day}"}
+		forbidden = []string{"{week}"}
+	case "weekly":
+		required = []string{"{year}", "{week}"}
+		forbidden = []string{"{month}", "{day}"}
+	case "monthly":
+		required = []string{"{year}", "{month}"}
+		forbidden = []string{"{day}", "{week}"}
+	default:
+		return fmt.Errorf("Error: Invalid interval")
+	}
+	for _, p := range required {
+		if strings.Count(format, p) != 1 {
+			return fmt.Errorf("title format %q for %s milestones must contain %s once", format, interval, p)
+		}
+	}
+	for _, p := range forbidden {
+		if strings.Contains(format, p) {
+			return fmt.Errorf("title format %q for %s milestones must not contain %s", format, interval, p)
+		}
+	}
+	return nil
+}
+
+// FormatTitle returns the title of the milestone due on day
+func FormatTitle(format string, day time.Time) string {
+	year := day.Year()
+	week := 0
+	if strings.Contains(format, "{week}") {
+		year, week = day.ISOWeek()
+	}
+	r := strings.NewReplacer(
+		"{year}", fmt.Sprintf("%04d", year),
+		"{month}", fmt.Sprintf("%02d", int(day.Month())),
+		"{day}", fmt.Sprintf("%02d", day.Day()),
+		"{week}", strconv.Itoa(week),
+	)
+	return r.Replace(format)
+}
+
+// ParseTitle parses a title following format and returns the due date of the milestone
+func ParseTitle(format string, title string) (time.Time, error) {
+	var pattern strings.Builder
+	var order []string
+	pattern.WriteString("^")
+	rest := format
+	for len(rest) > 0 {
+		i := strings.Index(rest, "{")
+		if i < 0 {
+			pattern.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		pattern.WriteString(regexp.QuoteMeta(rest[:i]))
+		rest = rest[i:]
+		matched := false
+		for p, expr := range titlePlaceholders {
+			if strings.HasPrefix(rest, p) {
+				pattern.WriteString(expr)
+				order = append(order, p)
+				rest = rest[len(p):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			pattern.WriteString(regexp.QuoteMeta("{"))
+			rest = rest[1:]
+		}
+	}
+	pattern.WriteString("$")
+
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return time.Time{}, err
+	}
+	match := re.FindStringSubmatch(title)
+	if match == nil {
+		return time.Time{}, fmt.Errorf("title %s does not match format %s", title, format)
+	}
+	values := map[string]int{"{month}": 1, "{day}": 1}
+	for i, p := range order {
+		values[p], _ = strconv.Atoi(match[i+1])
+	}
+
+	var day time.Time
+	switch {
+	case strings.Contains(format, "{week}"):
+		// ISO week 1 contains January 4th
+		jan4 := time.Date(values["{year}"], time.January, 4, 0, 0, 0, 0, time.Local)
+		day = LastDayWeek(jan4).AddDate(0, 0, 7*(values["{week}"]-1))
+	case strings.Contains(format, "{day}"):
+		day = time.Date(values["{year}"], time.Month(values["{month}"]), values["{day}"], 0, 0, 0, 0, time.Local)
+	default:
+		day = time.Date(values["{year}"], time.Month(values["{month}"])+1, 0, 0, 0, 0, 0, time.Local)
+	}
+	// Reject titles that do not round trip, like 2017-02-30 or 2017-w09
+	if FormatTitle(format, day) != title {
+		return time.Time{}, fmt.Errorf("title %s is not a valid %s title", title, format)
+	}
+	return day, nil
+}
+
+// IsGeneratedTitle reports whether title follows the naming scheme format
+func IsGeneratedTitle(title string, format string) bool {
+	_, err := ParseTitle(format, title)
+	return err == nil
+}
+
+// PlanRenames maps managed milestones with titles following fromFormat to titles following toFormat by date.
+// Milestones that can not be renamed are returned with the reason.
+func PlanRenames(milestones map[string]Milestone, fromFormat string, toFormat string) ([]Rename, map[string]string) {
+	var keys []string
+	for k := range milestones {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	renames := []Rename{}
+	unmatched := map[string]string{}
+	taken := map[string]bool{}
+	for k := range milestones {
+		taken[k] = true
+	}
+	for _, key := range keys {
+		m := milestones[key]
+		if IsGeneratedTitle(m.Title, toFormat) {
+			continue
+		}
+		day, err := ParseTitle(fromFormat, m.Title)
+		if err != nil {
+			unmatched[m.Title] = "title does not match " + fromFormat
+			continue
+		}
+		if !IsManaged(m.Description) {
+			unmatched[m.Title] = "milestone is not managed by gomiler"
+			continue
+		}
+		title := FormatTitle(toFormat, day)
+		if taken[title] {
+			unmatched[m.Title] = "milestone " + title + " already exists"
+			continue
+		}
+		taken[title] = true
+		renames = append(renames, Rename{Milestone: m, Title: title})
+	}
+	return renames, unmatched
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsGeneratedTitle(t *testing.T) {
+	titles := map[string]string{
+		"2017-03-04": "daily",
+		"2017-w9":    "weekly",
+		"2017-w52":   "weekly",
+		"2017-03":    "monthly",
+	}
+	for title, interval := range titles {
+		if !IsGeneratedTitle(title, DefaultTitleFormat(interval)) {
+			t.Errorf("Expected %s to be a generated %s title", title, interval)
+		}
+	}
+	invalid := map[string]string{
+		"Release 1.0": "daily",
+		"2017-02-30":  "daily",
+		"2017-w09":    "weekly",
+		"2017-w54":    "weekly",
+		"2017-03-04":  "monthly",
+	}
+	for title, interval := range invalid {
+		if IsGeneratedTitle(title, DefaultTitleFormat(interval)) {
+			t.Errorf("Expected %s not to be a generated %s title", title, interval)
+		}
+	}
+}
+
+func TestDefaultTitleFormatMatchesCreateMilestoneData(t *testing.T) {
+	for _, interval := range []string{"daily", "weekly", "monthly"} {
+		milestones, err := CreateMilestoneData(10, interval, nil, "gitlab")
+		if err != nil {
+			t.Error(err)
+		}
+		for title := range milestones {
+			if !IsGeneratedTitle(title, DefaultTitleFormat(interval)) {
+				t.Errorf("Expected %s to be a generated %s title", title, interval)
+			}
+		}
+	}
+}
+
+func TestValidateTitleFormat(t *testing.T) {
+	if err := ValidateTitleFormat("Sprint {year}-{week}", "weekly"); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateTitleFormat("Sprint {year}", "weekly"); err == nil {
+		t.Errorf("Expected to get an error when {week} is missing")
+	}
+	if err := ValidateTitleFormat("{year}-{month}-{day}", "monthly"); err == nil {
+		t.Errorf("Expected to get an error when {day} is used for monthly milestones")
+	}
+}
+
+func TestParseTitle(t *testing.T) {
+	day, err := ParseTitle("Sprint {year}-{week}", "Sprint 2020-53")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := time.Date(2021, time.January, 3, 0, 0, 0, 0, time.Local)
+	if !day.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, day)
+	}
+	day, err = ParseTitle("{year}-{month}", "2020-02")
+	if err != nil {
+		t.Error(err)
+	}
+	expected = time.Date(2020, time.February, 29, 0, 0, 0, 0, time.Local)
+	if !day.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, day)
+	}
+	_, err = ParseTitle("{year}-w{week}", "Sprint 2020-53")
+	if err == nil {
+		t.Errorf("Expected to get an error when title does not match format")
+	}
+}
+
+func TestPlanRenames(t *testing.T) {
+	milestones := map[string]Milestone{
+		"2020-w1":        {Title: "2020-w1", Description: Marker},
+		"2020-w2":        {Title: "2020-w2", Description: Marker},
+		"2020-w4":        {Title: "2020-w4"},
+		"Sprint 2020-2":  {Title: "Sprint 2020-2", Description: Marker},
+		"Sprint 2020-3":  {Title: "Sprint 2020-3", Description: Marker},
+		"Release v1.0.0": {Title: "Release v1.0.0"},
+	}
+	renames, unmatched := PlanRenames(milestones, "{year}-w{week}", "Sprint {year}-{week}")
+	if len(renames) != 1 {
+		t.Fatalf("Expected %d, got %d", 1, len(renames))
+	}
+	if renames[0].Milestone.Title != "2020-w1" || renames[0].Title != "Sprint 2020-1" {
+		t.Errorf("Expected %s -> %s, got %s -> %s", "2020-w1", "Sprint 2020-1", renames[0].Milestone.Title, renames[0].Title)
+	}
+	if _, ok := unmatched["2020-w2"]; !ok {
+		t.Errorf("Expected %s to be unmatched", "2020-w2")
+	}
+	if _, ok := unmatched["2020-w4"]; !ok {
+		t.Errorf("Expected %s to be unmatched", "2020-w4")
+	}
+	if _, ok := unmatched["Release v1.0.0"]; !ok {
+		t.Errorf("Expected %s to be unmatched", "Release v1.0.0")
+	}
+}
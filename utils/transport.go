@@ -0,0 +1,131 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TransportOptions configure TLS and the proxy of the requests to an API
+type TransportOptions struct {
+	// PEM file of CA certificates trusted in addition to the system ones
+	CACert string
+	// PEM files of the client certificate and key presented for mutual TLS
+	ClientCert string
+	ClientKey  string
+	// Skip verifying the certificate of the API, only meant for test instances
+	InsecureSkipVerify bool
+	// Proxy URL used instead of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	Proxy string
+}
+
+// Transport returns a transport with the options applied to the defaults of net/http
+func (o TransportOptions) Transport() (*http.Transport, error) {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify},
+	}
+
+	if o.CACert != "" {
+		pem, err := ioutil.ReadFile(o.CACert)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Error: no certificates found in CA bundle %s", o.CACert)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	if (o.ClientCert == "") != (o.ClientKey == "") {
+		return nil, fmt.Errorf("Error: a client certificate requires both a certificate and a key file")
+	}
+	if o.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(o.ClientCert, o.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if o.Proxy != "" {
+		proxy, err := url.Parse(o.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("Error: Invalid proxy %s, use an http, https or socks5 URL", o.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return transport, nil
+}
+
+// hostTransport is the client of the requests to a host with custom transport options
+type hostTransport struct {
+	options TransportOptions
+	client  *http.Client
+}
+
+// Configure sends the requests to host, e.g. gitlab.example.com:8443, through a transport with the options.
+// Requests to other hosts keep using HTTPClient. It reports whether the options of the host changed.
+func (c *Client) Configure(host string, o TransportOptions) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if current, ok := c.transports[host]; ok && current.options == o {
+		return false, nil
+	}
+	if _, ok := c.transports[host]; !ok && o == (TransportOptions{}) {
+		return false, nil
+	}
+	transport, err := o.Transport()
+	if err != nil {
+		return false, err
+	}
+	c.transports[host] = hostTransport{options: o, client: &http.Client{Transport: transport}}
+	return true, nil
+}
+
+// httpClient returns the client of the requests to host
+func (c *Client) httpClient(host string) *http.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t, ok := c.transports[host]; ok {
+		return t.client
+	}
+	return c.HTTPClient
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed client certificate and its key to dir
+func writeClientCert(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gomiler"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, certFile, keyFile
+}
+
+func TestClientConfigureTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomiler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	clientCert, certFile, keyFile := writeClientCert(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caFile := filepath.Join(dir, "ca.pem")
+	err = ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+
+	c := NewClient()
+	c.MaxRetries = 0
+	_, _, err = c.Send(context.Background(), "gitlab", "GET", server.URL, nil, nil)
+	if err == nil {
+		t.Error("Expected the certificate of an unknown CA to be rejected")
+	}
+
+	changed, err := c.Configure(u.Host, TransportOptions{CACert: caFile})
+	if err != nil || !changed {
+		t.Fatalf("Expected the options to change, got %t, %v", changed, err)
+	}
+	_, _, err = c.Send(context.Background(), "gitlab", "GET", server.URL, nil, nil)
+	if err == nil {
+		t.Error("Expected a request without client certificate to be rejected")
+	}
+
+	_, err = c.Configure(u.Host, TransportOptions{CACert: caFile, ClientCert: certFile, ClientKey: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, body, err := c.Send(context.Background(), "gitlab", "GET", server.URL, nil, nil)
+	if err != nil || resp.StatusCode != 200 || string(body) != "[]" {
+		t.Errorf("Expected the request to succeed, got %v", err)
+	}
+	changed, err = c.Configure(u.Host, TransportOptions{CACert: caFile, ClientCert: certFile, ClientKey: keyFile})
+	if err != nil || changed {
+		t.Errorf("Expected unchanged options to keep the transport, got %t, %v", changed, err)
+	}
+
+	_, err = c.Configure(u.Host, TransportOptions{InsecureSkipVerify: true, ClientCert: certFile, ClientKey: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = c.Send(context.Background(), "gitlab", "GET", server.URL, nil, nil)
+	if err != nil {
+		t.Errorf("Expected the request to succeed without verification, got %v", err)
+	}
+}
+
+func TestClientConfigureProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte("{}"))
+	}))
+	defer proxy.Close()
+
+	c := NewClient()
+	c.MaxRetries = 0
+	_, err := c.Configure("gitlab.example.com", TransportOptions{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = c.Send(context.Background(), "gitlab", "GET", "http://gitlab.example.com/api/v4/version", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxied != "http://gitlab.example.com/api/v4/version" {
+		t.Errorf("Expected the request to go through the proxy, got %q", proxied)
+	}
+}
+
+func TestTransportOptionsInvalid(t *testing.T) {
+	for _, o := range []TransportOptions{
+		{CACert: "missing.pem"},
+		{ClientCert: "client.pem"},
+		{Proxy: "ftp://proxy"},
+	} {
+		if _, err := o.Transport(); err == nil {
+			t.Errorf("Expected an error for %+v", o)
+		}
+	}
+}
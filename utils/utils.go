@@ -15,23 +15,131 @@ limitations under the License.
 package utils
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/peterhellberg/link"
 )
 
+// Marker is added to the description of milestones created or adopted by gomiler.
+// Only milestones carrying the marker are updated, closed, reopened or deleted.
+const Marker = "<!-- managed by gomiler -->"
+
 // Milestone struct to be used for milestone queries
 type Milestone struct {
-	DueDate string
-	ID      string
-	Title   string
-	State   string
-	Number  int
+	DueDate      string  `json:"due_date,omitempty" yaml:"due_date,omitempty"`
+	StartDate    string  `json:"start_date,omitempty" yaml:"start_date,omitempty"`
+	Description  string  `json:"description,omitempty" yaml:"description,omitempty"`
+	ID           string  `json:"id,omitempty" yaml:"id,omitempty"`
+	URL          string  `json:"url,omitempty" yaml:"url,omitempty"`
+	Title        string  `json:"title" yaml:"title"`
+	State        string  `json:"state,omitempty" yaml:"state,omitempty"`
+	Number       int     `json:"number,omitempty" yaml:"number,omitempty"`
+	OpenIssues   int     `json:"open_issues,omitempty" yaml:"open_issues,omitempty"`
+	ClosedIssues int     `json:"closed_issues,omitempty" yaml:"closed_issues,omitempty"`
+	Issues       []Issue `json:"-" yaml:"-"`
+}
+
+// Issue struct to be used for issue queries
+type Issue struct {
+	// Kind is issue, pull_request or merge_request
+	Kind      string     `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Number    int        `json:"number" yaml:"number"`
+	Title     string     `json:"title" yaml:"title"`
+	State     string     `json:"state" yaml:"state"`
+	URL       string     `json:"url,omitempty" yaml:"url,omitempty"`
+	Author    string     `json:"author,omitempty" yaml:"author,omitempty"`
+	Labels    []string   `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Weight    int        `json:"weight,omitempty" yaml:"weight,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty" yaml:"created_at,omitempty"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty" yaml:"closed_at,omitempty"`
+}
+
+// Reference returns the short reference of an issue, pull or merge request
+func (i Issue) Reference() string {
+	if i.Kind == "merge_request" {
+		return "!" + strconv.Itoa(i.Number)
+	}
+	return "#" + strconv.Itoa(i.Number)
+}
+
+// SetIssues sets the issues of a milestone and counts the open and closed ones
+func (m *Milestone) SetIssues(issues []Issue) {
+	m.Issues = issues
+	m.OpenIssues = 0
+	m.ClosedIssues = 0
+	for _, issue := range issues {
+		if issue.ClosedAt != nil || issue.State == "closed" {
+			m.ClosedIssues++
+		} else {
+			m.OpenIssues++
+		}
+	}
+}
+
+// PercentComplete returns the share of closed issues in percent, a milestone without issues is 0% complete
+func (m Milestone) PercentComplete() float64 {
+	total := m.OpenIssues + m.ClosedIssues
+	if total == 0 {
+		return 0
+	}
+	return float64(m.ClosedIssues) * 100 / float64(total)
+}
+
+// DaysRemaining returns the number of days left until the due date, 0 if it has passed or is unknown
+func (m Milestone) DaysRemaining(now time.Time) int {
+	due, err := ParseDueDate(m.DueDate)
+	if err != nil {
+		return 0
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	days := int(due.Sub(today).Hours() / 24)
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
+// Drift records the fields in which an existing milestone differs from the desired one
+type Drift struct {
+	Existing Milestone
+	Desired  Milestone
+	Fields   []string
+}
+
+// Detail describes the changed fields of a drift
+func (d Drift) Detail() string {
+	var changes []string
+	for _, field := range d.Fields {
+		switch field {
+		case "due date":
+			changes = append(changes, fmt.Sprintf("Due Date: %s -> %s", d.Existing.DueDate, d.Desired.DueDate))
+		case "start date":
+			changes = append(changes, fmt.Sprintf("Start Date: %s -> %s", d.Existing.StartDate, d.Desired.StartDate))
+		case "description":
+			changes = append(changes, fmt.Sprintf("Description: %q -> %q", StripMarker(d.Existing.Description), d.Desired.Description))
+		}
+	}
+	return strings.Join(changes, ", ")
+}
+
+// RolledOverItem records an issue or merge/pull request moved from an expired milestone to the next one
+type RolledOverItem struct {
+	Kind   string `json:"kind" yaml:"kind"`
+	Number int    `json:"number" yaml:"number"`
+	Title  string `json:"title" yaml:"title"`
+	From   string `json:"from" yaml:"from"`
+	To     string `json:"to" yaml:"to"`
+}
+
+// Reference returns the short reference of the rolled over issue, pull or merge request
+func (i RolledOverItem) Reference() string {
+	return Issue{Kind: i.Kind, Number: i.Number}.Reference()
 }
 
 // LastDayMonth function to get last day of the month
@@ -51,59 +159,226 @@ func LastDayWeek(lastDay time.Time) time.Time {
 	return lastDay
 }
 
+// ParseDueDate parses a GitLab (2006-01-02) or GitHub (RFC3339) due date and returns midnight of that day in local time.
+// GitHub stores due dates as UTC instants, so the day of an RFC3339 date is taken in UTC.
+func ParseDueDate(dueDate string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02", dueDate)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339, dueDate)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t = t.UTC()
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local), nil
+}
+
+// githubDate formats the day of t as midnight UTC, which GitHub keeps as the same day in every time zone
+func githubDate(t time.Time) string {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+}
+
+// GithubDueDate returns a due date as midnight UTC of its day for the due_on of GitHub, an invalid date is returned unchanged
+func GithubDueDate(dueDate string) string {
+	t, err := ParseDueDate(dueDate)
+	if err != nil {
+		return dueDate
+	}
+	return githubDate(t)
+}
+
+// DueDatePassed reports whether dueDate plus a grace period of days lies before the day of now
+func DueDatePassed(dueDate string, grace int, now time.Time) bool {
+	due, err := ParseDueDate(dueDate)
+	if err != nil {
+		return false
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	return due.AddDate(0, 0, grace).Before(today)
+}
+
+// IsManaged reports whether a milestone description carries the gomiler marker
+func IsManaged(description string) bool {
+	return strings.Contains(description, Marker)
+}
+
+// AddMarker adds the gomiler marker to a milestone description
+func AddMarker(description string) string {
+	if IsManaged(description) {
+		return description
+	}
+	if description == "" {
+		return Marker
+	}
+	return description + "\n\n" + Marker
+}
+
+// StripMarker removes the gomiler marker from a milestone description
+func StripMarker(description string) string {
+	return strings.TrimSpace(strings.Replace(description, Marker, "", -1))
+}
+
+// sameDay reports whether two due or start dates fall on the same day, two empty dates are the same
+func sameDay(a string, b string) bool {
+	if a == "" || b == "" {
+		return a == b
+	}
+	dayA, errA := ParseDueDate(a)
+	dayB, errB := ParseDueDate(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return dayA.Equal(dayB)
+}
+
+// CompareMilestones returns the fields in which existing differs from desired.
+// The start date is only compared if compareStartDate is set and the description only if desired has one.
+func CompareMilestones(existing Milestone, desired Milestone, compareStartDate bool) []string {
+	fields := []string{}
+	if !sameDay(existing.DueDate, desired.DueDate) {
+		fields = append(fields, "due date")
+	}
+	if compareStartDate && !sameDay(existing.StartDate, desired.StartDate) {
+		fields = append(fields, "start date")
+	}
+	if desired.Description != "" && StripMarker(existing.Description) != desired.Description {
+		fields = append(fields, "description")
+	}
+	return fields
+}
+
+// ValidateReactivationPolicy checks that policy is one of always, never, only-if-future-due or only-if-empty
+func ValidateReactivationPolicy(policy string) error {
+	switch policy {
+	case "always", "never", "only-if-future-due", "only-if-empty":
+		return nil
+	}
+	return fmt.Errorf("Error: Invalid reactivation policy %s", policy)
+}
+
+// ReactivationSkipReason returns why a closed milestone with itemCount issues and merge/pull requests
+// must not be reactivated under policy, or an empty string if it may be reactivated
+func ReactivationSkipReason(m Milestone, policy string, itemCount int, now time.Time) string {
+	switch policy {
+	case "never":
+		return "reactivation is disabled"
+	case "only-if-future-due":
+		if m.DueDate == "" {
+			return "milestone has no due date"
+		}
+		if DueDatePassed(m.DueDate, 0, now) {
+			return "due date has passed"
+		}
+	case "only-if-empty":
+		if itemCount > 0 {
+			return fmt.Sprintf("milestone has %d issues or merge/pull requests", itemCount)
+		}
+	}
+	return ""
+}
+
+// SelectReportMilestones returns the current milestone following titleFormat and up to recent milestones
+// due before it, ordered by due date
+func SelectReportMilestones(milestones map[string]Milestone, titleFormat string, recent int, now time.Time) []Milestone {
+	selected := []Milestone{}
+	for _, m := range milestones {
+		if !IsGeneratedTitle(m.Title, titleFormat) {
+			continue
+		}
+		if _, err := ParseDueDate(m.DueDate); err != nil {
+			continue
+		}
+		selected = append(selected, m)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		a, _ := ParseDueDate(selected[i].DueDate)
+		b, _ := ParseDueDate(selected[j].DueDate)
+		return a.Before(b)
+	})
+
+	// Keep the past milestones and the first one not due yet
+	end := len(selected)
+	for i, m := range selected {
+		if !DueDatePassed(m.DueDate, 0, now) {
+			end = i + 1
+			break
+		}
+	}
+	start := end - recent - 1
+	if start < 0 {
+		start = 0
+	}
+	return selected[start:end]
+}
+
 // CreateMilestoneData creates new milestones with title and due date
 func CreateMilestoneData(advance int, interval string, logger *log.Logger, api string) (map[string]Milestone, error) {
+	return CreateMilestoneDataWithFormat(advance, interval, DefaultTitleFormat(interval), logger, api)
+}
+
+// CreateMilestoneDataWithFormat creates new milestones with due date and a title following titleFormat
+func CreateMilestoneDataWithFormat(advance int, interval string, titleFormat string, logger *log.Logger, api string) (map[string]Milestone, error) {
 	today := time.Now().Local()
 	milestones := map[string]Milestone{}
 	switch interval {
 	case "daily":
 		for i := 0; i < advance; i++ {
 			var m Milestone
-			var dueDate string
-			title := today.AddDate(0, 0, i).Format("2006-01-02")
+			var dueDate, startDate string
+			title := FormatTitle(titleFormat, today.AddDate(0, 0, i))
 			switch api {
 			case "gitlab":
 				dueDate = today.AddDate(0, 0, i).Format("2006-01-02")
+				startDate = dueDate
 			case "github":
-				dueDate = today.AddDate(0, 0, i).Format(time.RFC3339)
+				dueDate = githubDate(today.AddDate(0, 0, i))
+				startDate = dueDate
 			}
 			m.Title = title
 			m.DueDate = dueDate
+			m.StartDate = startDate
 			milestones[title] = m
 		}
 	case "weekly":
 		for i := 0; i < advance; i++ {
 			var m Milestone
-			var dueDate string
+			var dueDate, startDate string
 			lastDay := LastDayWeek(today)
-			year, week := lastDay.ISOWeek()
-			title := strconv.Itoa(year) + "-w" + strconv.Itoa(week)
+			firstDay := lastDay.AddDate(0, 0, -6)
+			title := FormatTitle(titleFormat, lastDay)
 			switch api {
 			case "gitlab":
 				dueDate = lastDay.Format("2006-01-02")
+				startDate = firstDay.Format("2006-01-02")
 			case "github":
-				dueDate = lastDay.Format(time.RFC3339)
+				dueDate = githubDate(lastDay)
+				startDate = githubDate(firstDay)
 			}
 			m.Title = title
 			m.DueDate = dueDate
+			m.StartDate = startDate
 			milestones[title] = m
 			today = lastDay.AddDate(0, 0, 7)
 		}
 	case "monthly":
 		for i := 0; i < advance; i++ {
 			var m Milestone
-			var dueDate string
+			var dueDate, startDate string
 			date := today.AddDate(0, i, 0)
 			lastDay := LastDayMonth(date.Year(), int(date.Month()), time.UTC)
-			title := date.Format("2006-01")
+			firstDay := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+			title := FormatTitle(titleFormat, lastDay)
 			switch api {
 			case "gitlab":
 				dueDate = lastDay.Format("2006-01-02")
+				startDate = firstDay.Format("2006-01-02")
 			case "github":
-				dueDate = lastDay.Format(time.RFC3339)
+				dueDate = githubDate(lastDay)
+				startDate = githubDate(firstDay)
 			}
 			m.Title = title
 			m.DueDate = dueDate
+			m.StartDate = startDate
 			milestones[title] = m
 		}
 	default:
@@ -114,34 +389,21 @@ func CreateMilestoneData(advance int, interval string, logger *log.Logger, api s
 	return milestones, nil
 }
 
-// Paginate checks the linkHeader returned by the API and if a next page is present, appends the data to a [][]byte
-func Paginate(URL string, api string, token string) ([][]byte, error) {
-	apiData := make([][]byte, 1)
-	client := http.Client{}
+// Paginate checks the linkHeader returned by the API and if a next page is present, appends the data to a [][]byte.
+// A page without 2xx status is returned as APIError.
+func Paginate(ctx context.Context, URL string, api string, token string) ([][]byte, error) {
+	apiData := [][]byte{}
 	paginate := true
 	for paginate == true {
 		paginate = false
-		req, err := http.NewRequest("GET", URL, nil)
+		resp, respByte, err := DefaultClient.Send(ctx, api, "GET", URL, APIHeader(api, token), nil)
 		if err != nil {
 			return nil, err
 		}
-		switch api {
-		case "gitlab":
-			req.Header.Add("PRIVATE-TOKEN", token)
-		case "github":
-			req.Header.Add("Accept", "application/vnd.github.v3+json")
-			req.Header.Add("Authorization", "token "+token)
-		}
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, err
-		}
-		respByte, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
+		if err := CheckResponse("GET", URL, resp, respByte); err != nil {
 			return nil, err
 		}
 		apiData = append(apiData, respByte)
-		defer resp.Body.Close()
 
 		// Retrieve next page header
 		linkHeader := resp.Header.Get("Link")
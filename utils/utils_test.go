@@ -15,8 +15,11 @@ limitations under the License.
 package utils
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -46,7 +49,7 @@ func TestGithubCreateMilestoneDataDaily(t *testing.T) {
 		t.Error(err)
 	}
 	today := time.Now().Local().Format("2006-01-02")
-	todayFormatted := time.Now().Local().Format(time.RFC3339)
+	todayFormatted := today + "T00:00:00Z"
 	if milestones[today].DueDate != todayFormatted {
 		t.Errorf("Expected %s, got %s", today, milestones[today].DueDate)
 	}
@@ -61,7 +64,7 @@ func TestGithubCreateMilestoneDataWeekly(t *testing.T) {
 	lastDay := LastDayWeek(today)
 	year, week := lastDay.ISOWeek()
 	title := strconv.Itoa(year) + "-w" + strconv.Itoa(week)
-	expected := lastDay.Format(time.RFC3339)
+	expected := lastDay.Format("2006-01-02") + "T00:00:00Z"
 	if milestones[title].DueDate != expected {
 		t.Errorf("Expected %s, got %s", expected, milestones[title].DueDate)
 	}
@@ -201,7 +204,7 @@ func TestPaginate(t *testing.T) {
 	httpmock.Activate()
 	defer httpmock.DeactivateAndReset()
 	pages := MockPaginate("https://example.com")
-	apiData, err := Paginate("https://example.com", "github", "token")
+	apiData, err := Paginate(context.Background(), "https://example.com", "github", "token")
 	if err != nil {
 		t.Error(err)
 	}
@@ -210,40 +213,215 @@ func TestPaginate(t *testing.T) {
 	}
 }
 
+func TestPaginateAPIError(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", "https://example.com",
+		httpmock.NewStringResponder(404, `{"message": "404 Project Not Found"}`))
+	_, err := Paginate(context.Background(), "https://example.com", "gitlab", "token")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
 func TestPaginateFailWhenURLisWrong(t *testing.T) {
-	_, err := Paginate("https://example.c_m", "github", "token")
+	_, err := Paginate(context.Background(), "https://example.c_m", "github", "token")
 	if err == nil {
 		t.Errorf("Expected to get an error when url is wrong")
 	}
 }
 
-// MockPaginate creates a mock responder to return a byte slice
+// MockPaginate creates mock responders of pages linked to their next page and returns the number of pages
 func MockPaginate(url string) int {
-	linkHeader := []string{
-		"<http://example.com/page=1>; rel=\"next\", <http://example.com/page=3>; rel=\"last\"",
-		"<http://example.com/page=3>; rel=\"next\", <http://example.com/page=3>; rel=\"last\"",
-		"<http://example.com/page=2>; rel=\"first\", <http://example.com/page=3>; rel=\"last\"",
-	}
-	links := []string{
-		"http://example.com/page=1",
-		"http://example.com/page=3",
-		"http://example.com/page=2",
-	}
-	for i, link := range linkHeader {
-		httpmock.RegisterResponder("GET", links[i],
-			func(req *http.Request) (*http.Response, error) {
-				resp := httpmock.NewStringResponse(200, "testing")
-				resp.Header.Set("Link", link)
-				return resp, nil
-			},
-		)
-	}
-	httpmock.RegisterResponder("GET", url,
-		func(req *http.Request) (*http.Response, error) {
+	pages := []string{url, "http://example.com/page=2", "http://example.com/page=3"}
+	for i, page := range pages {
+		linkHeader := "<" + pages[len(pages)-1] + ">; rel=\"last\""
+		if i+1 < len(pages) {
+			linkHeader = "<" + pages[i+1] + ">; rel=\"next\", " + linkHeader
+		}
+		httpmock.RegisterResponder("GET", page, httpmock.ResponderFromResponse(func() *http.Response {
 			resp := httpmock.NewStringResponse(200, "testing")
-			resp.Header.Set("Link", linkHeader[0])
-			return resp, nil
-		},
-	)
-	return len(linkHeader)
+			resp.Header.Set("Link", linkHeader)
+			return resp
+		}()))
+	}
+	return len(pages)
+}
+
+func TestDueDatePassed(t *testing.T) {
+	now := time.Now().Local()
+	yesterday := now.AddDate(0, 0, -1)
+	if !DueDatePassed(yesterday.Format("2006-01-02"), 0, now) {
+		t.Errorf("Expected due date %s to have passed", yesterday.Format("2006-01-02"))
+	}
+	if !DueDatePassed(githubDate(yesterday), 0, now) {
+		t.Errorf("Expected due date %s to have passed", githubDate(yesterday))
+	}
+	if DueDatePassed(now.Format("2006-01-02"), 0, now) {
+		t.Errorf("Expected due date %s not to have passed", now.Format("2006-01-02"))
+	}
+	if DueDatePassed(yesterday.Format("2006-01-02"), 1, now) {
+		t.Errorf("Expected due date %s to be within grace period", yesterday.Format("2006-01-02"))
+	}
+	if DueDatePassed("", 0, now) {
+		t.Errorf("Expected empty due date not to have passed")
+	}
+}
+
+func TestCreateMilestoneDataStartDate(t *testing.T) {
+	milestones, err := CreateMilestoneData(5, "weekly", nil, "gitlab")
+	if err != nil {
+		t.Error(err)
+	}
+	for _, m := range milestones {
+		startDate, err := time.Parse("2006-01-02", m.StartDate)
+		if err != nil {
+			t.Error(err)
+		}
+		if startDate.Weekday() != time.Monday {
+			t.Errorf("Expected %s, got %s", time.Monday, startDate.Weekday())
+		}
+	}
+}
+
+func TestCompareMilestones(t *testing.T) {
+	existing := Milestone{Title: "2017-03", DueDate: "2017-03-31", StartDate: "2017-03-01", Description: "sprint"}
+	desired := Milestone{Title: "2017-03", DueDate: "2017-03-31T00:00:00Z", StartDate: "2017-03-01"}
+	fields := CompareMilestones(existing, desired, true)
+	if len(fields) != 0 {
+		t.Errorf("Expected no drift, got %v", fields)
+	}
+	desired.DueDate = "2017-03-30"
+	desired.StartDate = "2017-03-02"
+	desired.Description = "monthly sprint"
+	fields = CompareMilestones(existing, desired, true)
+	if len(fields) != 3 {
+		t.Errorf("Expected %d, got %d", 3, len(fields))
+	}
+	fields = CompareMilestones(existing, desired, false)
+	if len(fields) != 2 {
+		t.Errorf("Expected %d, got %d", 2, len(fields))
+	}
+}
+
+func TestGithubDueDateEastOfUTC(t *testing.T) {
+	local := time.Local
+	defer func() { time.Local = local }()
+	for _, zone := range []*time.Location{time.FixedZone("UTC+2", 2*3600), time.FixedZone("UTC-7", -7*3600)} {
+		time.Local = zone
+		milestones, err := CreateMilestoneData(3, "weekly", nil, "github")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, desired := range milestones {
+			// GitHub returns the due date as UTC instant
+			due, err := time.Parse(time.RFC3339, desired.DueDate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			existing := desired
+			existing.DueDate = due.UTC().Format(time.RFC3339)
+			if fields := CompareMilestones(existing, desired, false); len(fields) != 0 {
+				t.Errorf("%s: expected no drift of %s and %s, got %v", zone, existing.DueDate, desired.DueDate, fields)
+			}
+			if !strings.HasSuffix(desired.DueDate, "T00:00:00Z") {
+				t.Errorf("%s: expected the due date at midnight UTC, got %s", zone, desired.DueDate)
+			}
+		}
+		day, err := ParseDueDate("2018-01-02T07:00:00Z")
+		if err != nil || day.Format("2006-01-02") != "2018-01-02" || day.Location() != zone {
+			t.Errorf("%s: expected the UTC day of the due date, got %s, %v", zone, day, err)
+		}
+		if due := GithubDueDate("2018-01-02"); due != "2018-01-02T00:00:00Z" {
+			t.Errorf("%s: expected %s, got %s", zone, "2018-01-02T00:00:00Z", due)
+		}
+	}
+}
+
+func TestReactivationSkipReason(t *testing.T) {
+	now := time.Now().Local()
+	past := Milestone{Title: "past", DueDate: now.AddDate(0, 0, -1).Format("2006-01-02")}
+	future := Milestone{Title: "future", DueDate: now.AddDate(0, 0, 1).Format("2006-01-02")}
+	if ReactivationSkipReason(past, "always", 1, now) != "" {
+		t.Errorf("Expected %s to be reactivated", past.Title)
+	}
+	if ReactivationSkipReason(future, "never", 0, now) == "" {
+		t.Errorf("Expected %s not to be reactivated", future.Title)
+	}
+	if ReactivationSkipReason(past, "only-if-future-due", 0, now) == "" {
+		t.Errorf("Expected %s not to be reactivated", past.Title)
+	}
+	if ReactivationSkipReason(future, "only-if-future-due", 0, now) != "" {
+		t.Errorf("Expected %s to be reactivated", future.Title)
+	}
+	if ReactivationSkipReason(future, "only-if-empty", 2, now) == "" {
+		t.Errorf("Expected %s not to be reactivated", future.Title)
+	}
+	if ValidateReactivationPolicy("sometimes") == nil {
+		t.Errorf("Expected to get an error when policy is invalid")
+	}
+}
+
+func TestMarker(t *testing.T) {
+	description := AddMarker("sprint")
+	if !IsManaged(description) {
+		t.Errorf("Expected %q to be managed", description)
+	}
+	if AddMarker(description) != description {
+		t.Errorf("Expected marker to be added once, got %q", AddMarker(description))
+	}
+	if StripMarker(description) != "sprint" {
+		t.Errorf("Expected %s, got %s", "sprint", StripMarker(description))
+	}
+	if IsManaged("sprint") {
+		t.Errorf("Expected %q not to be managed", "sprint")
+	}
+}
+
+func TestMilestoneStatistics(t *testing.T) {
+	now := time.Now().Local()
+	closed := now.AddDate(0, 0, -1)
+	var m Milestone
+	m.DueDate = now.AddDate(0, 0, 3).Format("2006-01-02")
+	m.SetIssues([]Issue{
+		{Number: 1, State: "closed", ClosedAt: &closed},
+		{Number: 2, State: "open"},
+		{Number: 3, State: "opened"},
+		{Number: 4, State: "closed", ClosedAt: &closed},
+	})
+	if m.OpenIssues != 2 || m.ClosedIssues != 2 {
+		t.Errorf("Expected %d open and %d closed, got %d and %d", 2, 2, m.OpenIssues, m.ClosedIssues)
+	}
+	if m.PercentComplete() != 50 {
+		t.Errorf("Expected %d, got %f", 50, m.PercentComplete())
+	}
+	if m.DaysRemaining(now) != 3 {
+		t.Errorf("Expected %d, got %d", 3, m.DaysRemaining(now))
+	}
+	m.DueDate = now.AddDate(0, 0, -3).Format("2006-01-02")
+	if m.DaysRemaining(now) != 0 {
+		t.Errorf("Expected %d, got %d", 0, m.DaysRemaining(now))
+	}
+}
+
+func TestSelectReportMilestones(t *testing.T) {
+	now := time.Now().Local()
+	milestones := map[string]Milestone{}
+	for i := -5; i < 5; i++ {
+		title := now.AddDate(0, 0, i).Format("2006-01-02")
+		milestones[title] = Milestone{Title: title, DueDate: title}
+	}
+	milestones["Release 1.0"] = Milestone{Title: "Release 1.0", DueDate: now.Format("2006-01-02")}
+	selected := SelectReportMilestones(milestones, DefaultTitleFormat("daily"), 2, now)
+	if len(selected) != 3 {
+		t.Fatalf("Expected %d, got %d", 3, len(selected))
+	}
+	today := now.Format("2006-01-02")
+	if selected[2].Title != today {
+		t.Errorf("Expected %s, got %s", today, selected[2].Title)
+	}
+	twoDaysAgo := now.AddDate(0, 0, -2).Format("2006-01-02")
+	if selected[0].Title != twoDaysAgo {
+		t.Errorf("Expected %s, got %s", twoDaysAgo, selected[0].Title)
+	}
 }
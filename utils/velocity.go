@@ -0,0 +1,134 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Velocity records the issues and weights completed in a past milestone
+// with their rolling averages over the previous milestones
+type Velocity struct {
+	Title            string  `json:"title" yaml:"title"`
+	DueDate          string  `json:"due_date" yaml:"due_date"`
+	Completed        int     `json:"completed" yaml:"completed"`
+	Weight           int     `json:"weight" yaml:"weight"`
+	AverageCompleted float64 `json:"average_completed" yaml:"average_completed"`
+	AverageWeight    float64 `json:"average_weight" yaml:"average_weight"`
+}
+
+// SelectPastMilestones returns up to last milestones generated by gomiler, carrying its marker and following titleFormat,
+// whose due date has passed, ordered by due date
+func SelectPastMilestones(milestones map[string]Milestone, titleFormat string, last int, now time.Time) []Milestone {
+	managed := map[string]Milestone{}
+	for k, m := range milestones {
+		if IsManaged(m.Description) {
+			managed[k] = m
+		}
+	}
+	selected := SelectReportMilestones(managed, titleFormat, last, now)
+	if len(selected) > 0 && !DueDatePassed(selected[len(selected)-1].DueDate, 0, now) {
+		selected = selected[:len(selected)-1]
+	}
+	if len(selected) > last {
+		selected = selected[len(selected)-last:]
+	}
+	return selected
+}
+
+// CalculateVelocity returns the velocity of milestones ordered by due date.
+// Only issues closed on or before the due date count, work finished later does not inflate the milestone.
+// Averages are taken over the milestone and up to window-1 milestones before it.
+func CalculateVelocity(milestones []Milestone, window int) []Velocity {
+	if window < 1 {
+		window = 1
+	}
+	velocity := []Velocity{}
+	for i, m := range milestones {
+		v := Velocity{Title: m.Title, DueDate: m.DueDate}
+		due, err := ParseDueDate(m.DueDate)
+		endOfDue := due.AddDate(0, 0, 1)
+		for _, issue := range m.Issues {
+			if err != nil || issue.ClosedAt == nil || !issue.ClosedAt.Before(endOfDue) {
+				continue
+			}
+			v.Completed++
+			v.Weight += issue.Weight
+		}
+		velocity = append(velocity, v)
+
+		start := i - window + 1
+		if start < 0 {
+			start = 0
+		}
+		completed, weight := 0, 0
+		for _, previous := range velocity[start:] {
+			completed += previous.Completed
+			weight += previous.Weight
+		}
+		count := float64(len(velocity) - start)
+		velocity[i].AverageCompleted = float64(completed) / count
+		velocity[i].AverageWeight = float64(weight) / count
+	}
+	return velocity
+}
+
+// WriteVelocity writes velocity as table, csv, json or yaml
+func WriteVelocity(w io.Writer, velocity []Velocity, format string) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TITLE\tDUE DATE\tCOMPLETED\tWEIGHT\tAVG COMPLETED\tAVG WEIGHT")
+		for _, v := range velocity {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.1f\t%.1f\n", v.Title, v.DueDate, v.Completed, v.Weight, v.AverageCompleted, v.AverageWeight)
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"title", "due_date", "completed", "weight", "average_completed", "average_weight"})
+		for _, v := range velocity {
+			cw.Write([]string{
+				v.Title,
+				v.DueDate,
+				strconv.Itoa(v.Completed),
+				strconv.Itoa(v.Weight),
+				strconv.FormatFloat(v.AverageCompleted, 'f', 2, 64),
+				strconv.FormatFloat(v.AverageWeight, 'f', 2, 64),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(velocity)
+	case "yaml":
+		out, err := yaml.Marshal(velocity)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	}
+	return fmt.Errorf("Error: Invalid output format %s", format)
+}
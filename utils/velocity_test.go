@@ -0,0 +1,134 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func mockVelocityMilestones() []Milestone {
+	milestones := []Milestone{}
+	for i, completed := range []int{2, 4, 6} {
+		m := Milestone{Title: "2017-w" + strconv.Itoa(i+1), DueDate: "2017-01-0" + strconv.Itoa(i+1)}
+		due, _ := ParseDueDate(m.DueDate)
+		onDue, afterDue := due.Add(23*time.Hour), due.AddDate(0, 0, 1)
+		// Issues still open or closed after the due date are not completed in the milestone
+		issues := []Issue{
+			{Number: 100, State: "opened", Weight: 5},
+			{Number: 101, State: "closed", Weight: 3, ClosedAt: &afterDue},
+		}
+		for j := 0; j < completed; j++ {
+			issues = append(issues, Issue{Number: j, State: "closed", Weight: 2, ClosedAt: &onDue})
+		}
+		m.SetIssues(issues)
+		milestones = append(milestones, m)
+	}
+	return milestones
+}
+
+func TestSelectPastMilestones(t *testing.T) {
+	now := time.Now().Local()
+	milestones := map[string]Milestone{}
+	for i := -5; i < 5; i++ {
+		title := now.AddDate(0, 0, i).Format("2006-01-02")
+		milestones[title] = Milestone{Title: title, DueDate: title, Description: Marker}
+	}
+	// A milestone following the title format but not created by gomiler is left out
+	dayBefore := now.AddDate(0, 0, -2).Format("2006-01-02")
+	milestones[dayBefore] = Milestone{Title: dayBefore, DueDate: dayBefore}
+	selected := SelectPastMilestones(milestones, DefaultTitleFormat("daily"), 3, now)
+	if len(selected) != 3 {
+		t.Fatalf("Expected %d, got %d", 3, len(selected))
+	}
+	yesterday := now.AddDate(0, 0, -1).Format("2006-01-02")
+	fourDaysAgo := now.AddDate(0, 0, -4).Format("2006-01-02")
+	if selected[0].Title != fourDaysAgo || selected[2].Title != yesterday {
+		t.Errorf("Expected %s to %s without %s, got %v", fourDaysAgo, yesterday, dayBefore, selected)
+	}
+}
+
+func TestCalculateVelocity(t *testing.T) {
+	velocity := CalculateVelocity(mockVelocityMilestones(), 2)
+	if len(velocity) != 3 {
+		t.Fatalf("Expected %d, got %d", 3, len(velocity))
+	}
+	if velocity[2].Completed != 6 || velocity[2].Weight != 12 {
+		t.Errorf("Expected %d completed with weight %d, got %d and %d", 6, 12, velocity[2].Completed, velocity[2].Weight)
+	}
+	if velocity[0].AverageCompleted != 2 {
+		t.Errorf("Expected %f, got %f", 2.0, velocity[0].AverageCompleted)
+	}
+	if velocity[2].AverageCompleted != 5 || velocity[2].AverageWeight != 10 {
+		t.Errorf("Expected averages %f and %f, got %f and %f", 5.0, 10.0, velocity[2].AverageCompleted, velocity[2].AverageWeight)
+	}
+}
+
+func TestWriteVelocity(t *testing.T) {
+	velocity := CalculateVelocity(mockVelocityMilestones(), 3)
+	var buf bytes.Buffer
+	err := WriteVelocity(&buf, velocity, "csv")
+	if err != nil {
+		t.Error(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Errorf("Expected %d, got %d", 4, len(lines))
+	}
+	buf.Reset()
+	err = WriteVelocity(&buf, velocity, "json")
+	if err != nil {
+		t.Error(err)
+	}
+	decoded := []Velocity{}
+	err = json.Unmarshal(buf.Bytes(), &decoded)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(decoded) != 3 {
+		t.Errorf("Expected %d, got %d", 3, len(decoded))
+	}
+	buf.Reset()
+	err = WriteVelocity(&buf, velocity, "yaml")
+	if err != nil {
+		t.Error(err)
+	}
+	decoded = []Velocity{}
+	err = yaml.Unmarshal(buf.Bytes(), &decoded)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(decoded) != 3 || decoded[2].AverageCompleted != velocity[2].AverageCompleted {
+		t.Errorf("Expected %v, got %v", velocity, decoded)
+	}
+	buf.Reset()
+	err = WriteVelocity(&buf, velocity, "table")
+	if err != nil {
+		t.Error(err)
+	}
+	if !strings.HasPrefix(buf.String(), "TITLE") {
+		t.Errorf("Expected table header, got %s", buf.String())
+	}
+	err = WriteVelocity(&buf, velocity, "xml")
+	if err == nil {
+		t.Errorf("Expected to get an error when output format is invalid")
+	}
+}
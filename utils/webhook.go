@@ -0,0 +1,118 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// WebhookEvent is a milestone change received by webhook
+type WebhookEvent struct {
+	Action string
+	// Path of the project including its namespace, e.g. group/subgroup/project
+	Project   string
+	Milestone string
+}
+
+// reconcileActions are the milestone actions of GitHub and GitLab that can make a project deviate from its schedule
+var reconcileActions = map[string]bool{
+	"closed":  true,
+	"deleted": true,
+	"edited":  true,
+	"close":   true,
+	"delete":  true,
+	"update":  true,
+}
+
+// Reconcile reports whether the project of the event needs to be reconciled
+func (e WebhookEvent) Reconcile() bool {
+	return reconcileActions[e.Action]
+}
+
+// VerifyGithubSignature checks the X-Hub-Signature-256 header of a webhook against the HMAC of its body
+func VerifyGithubSignature(secret string, body []byte, signature string) bool {
+	if secret == "" || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// VerifyGitlabToken checks the X-Gitlab-Token header of a webhook
+func VerifyGitlabToken(secret string, token string) bool {
+	return secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(token)) == 1
+}
+
+// ParseGithubWebhook parses the payload of a GitHub milestone event
+func ParseGithubWebhook(body []byte) (WebhookEvent, error) {
+	var payload struct {
+		Action    string `json:"action"`
+		Milestone struct {
+			Title string `json:"title"`
+		} `json:"milestone"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	err := json.Unmarshal(body, &payload)
+	if err != nil {
+		return WebhookEvent{}, fmt.Errorf("invalid GitHub webhook: %v", err)
+	}
+	if payload.Repository.FullName == "" {
+		return WebhookEvent{}, fmt.Errorf("invalid GitHub webhook: no repository")
+	}
+	return WebhookEvent{
+		Action:    payload.Action,
+		Project:   payload.Repository.FullName,
+		Milestone: payload.Milestone.Title,
+	}, nil
+}
+
+// ParseGitlabWebhook parses the payload of a GitLab milestone event
+func ParseGitlabWebhook(body []byte) (WebhookEvent, error) {
+	var payload struct {
+		ObjectKind string `json:"object_kind"`
+		Action     string `json:"action"`
+		Project    struct {
+			PathWithNamespace string `json:"path_with_namespace"`
+		} `json:"project"`
+		ObjectAttributes struct {
+			Title string `json:"title"`
+		} `json:"object_attributes"`
+	}
+	err := json.Unmarshal(body, &payload)
+	if err != nil {
+		return WebhookEvent{}, fmt.Errorf("invalid GitLab webhook: %v", err)
+	}
+	if payload.ObjectKind != "milestone" || payload.Project.PathWithNamespace == "" {
+		return WebhookEvent{}, fmt.Errorf("invalid GitLab webhook: no milestone event of a project")
+	}
+	return WebhookEvent{
+		Action:    payload.Action,
+		Project:   payload.Project.PathWithNamespace,
+		Milestone: payload.ObjectAttributes.Title,
+	}, nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+)
+
+func TestVerifyGithubSignature(t *testing.T) {
+	body := []byte(`{"action": "closed"}`)
+	// HMAC-SHA256 of the body with the key "secret"
+	signature := "sha256=a962b6a8c62a98698fea8c3dbf54d393d33e333bbc87c349fc21b5eb39492a95"
+	if !VerifyGithubSignature("secret", body, signature) {
+		t.Errorf("Expected a valid signature")
+	}
+	invalid := []string{
+		"",
+		"a962b6a8c62a98698fea8c3dbf54d393d33e333bbc87c349fc21b5eb39492a95",
+		"sha256=zz",
+		// key "other"
+		"sha256=791eba881125aa9d89ce1407bc94807e1575e8b6f873bf3cf60126063c12938b",
+	}
+	for _, s := range invalid {
+		if VerifyGithubSignature("secret", body, s) {
+			t.Errorf("Expected signature %q to be invalid", s)
+		}
+	}
+	if VerifyGithubSignature("", body, signature) {
+		t.Errorf("Expected webhooks without secret to be rejected")
+	}
+}
+
+func TestVerifyGitlabToken(t *testing.T) {
+	if !VerifyGitlabToken("secret", "secret") {
+		t.Errorf("Expected a valid token")
+	}
+	if VerifyGitlabToken("secret", "other") || VerifyGitlabToken("", "") {
+		t.Errorf("Expected invalid tokens to be rejected")
+	}
+}
+
+func TestParseWebhooks(t *testing.T) {
+	e, err := ParseGithubWebhook([]byte(`{"action": "edited", "milestone": {"title": "2018-01-01"}, "repository": {"full_name": "octocat/app"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e != (WebhookEvent{Action: "edited", Project: "octocat/app", Milestone: "2018-01-01"}) || !e.Reconcile() {
+		t.Errorf("Unexpected event %v", e)
+	}
+	e, err = ParseGitlabWebhook([]byte(`{"object_kind": "milestone", "action": "create", "project": {"path_with_namespace": "group/sub/app"}, "object_attributes": {"title": "2018-01-01"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e != (WebhookEvent{Action: "create", Project: "group/sub/app", Milestone: "2018-01-01"}) || e.Reconcile() {
+		t.Errorf("Unexpected event %v", e)
+	}
+	if _, err := ParseGitlabWebhook([]byte(`{"object_kind": "push"}`)); err == nil {
+		t.Errorf("Expected to get an error for a push event")
+	}
+	if _, err := ParseGithubWebhook([]byte(`not json`)); err == nil {
+		t.Errorf("Expected to get an error for an invalid payload")
+	}
+}
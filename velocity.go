@@ -0,0 +1,72 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"go.okkur.org/gomiler/utils"
+)
+
+// runVelocity prints the issues and weights completed in the last milestones
+func runVelocity(args []string) {
+	var o options
+	var last, window int
+	fs := flag.NewFlagSet("velocity", flag.ExitOnError)
+	o.registerFlags(fs)
+	fs.IntVar(&last, "last", 6, "Number of past milestones to include")
+	fs.IntVar(&window, "window", 3, "Number of milestones to calculate rolling averages over")
+	fs.Parse(args)
+
+	// velocity is always tabular, so text output is a table and csv is supported as well
+	output := o.output
+	if output == "text" {
+		output = "table"
+	}
+	if output != "csv" {
+		if err := utils.ValidateOutputFormat(output); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	titleFormat, err := o.format()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if err := o.resolveToken(); err != nil {
+		logger.Fatal(err)
+	}
+	ctx, cancel := o.runContext()
+	defer cancel()
+	t, err := connect(ctx, o)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	allMilestones, err := getAllMilestones(ctx, t, o.token)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	milestones := utils.SelectPastMilestones(allMilestones, titleFormat, last, time.Now())
+	err = setMilestoneIssues(ctx, t, o.token, milestones)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	err = utils.WriteVelocity(os.Stdout, utils.CalculateVelocity(milestones, window), output)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2017 - The GoMiler Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"go.okkur.org/gomiler/utils"
+)
+
+// maxWebhookSize limits the payload of webhooks
+const maxWebhookSize = 1 << 20
+
+// webhookProviders returns the names of the providers whose webhook secret verifies the request
+func webhookProviders(c utils.Config, api string, r *http.Request, body []byte) map[string]bool {
+	providers := map[string]bool{}
+	for _, p := range c.Providers {
+		secret, err := p.ResolveWebhookSecret()
+		if err != nil {
+			logger.Println(err)
+			continue
+		}
+		var verified bool
+		switch api {
+		case "github":
+			verified = utils.VerifyGithubSignature(secret, body, r.Header.Get("X-Hub-Signature-256"))
+		case "gitlab":
+			verified = utils.VerifyGitlabToken(secret, r.Header.Get("X-Gitlab-Token"))
+		}
+		if verified {
+			providers[p.Name] = true
+		}
+	}
+	return providers
+}
+
+// enqueue marks a reconciliation of the project as pending.
+// It returns false if one is pending already, which then covers the event too.
+func (d *daemon) enqueue(project string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.queued[project] {
+		return false
+	}
+	if d.queued == nil {
+		d.queued = map[string]bool{}
+	}
+	d.queued[project] = true
+	return true
+}
+
+// reconcileEvent reconciles the project of a webhook event, waiting for a running pass to finish.
+// Once it started, a further event of the project queues another reconciliation.
+// The timeout of the daemon applies from then on.
+func (d *daemon) reconcileEvent(c utils.Config, providers map[string]bool, e utils.WebhookEvent) {
+	d.passMu.Lock()
+	defer d.passMu.Unlock()
+	d.mu.Lock()
+	delete(d.queued, e.Project)
+	d.mu.Unlock()
+	ctx, cancel := d.passContext()
+	defer cancel()
+
+	runs, err := projectRuns(ctx, c, d.args, providers, e.Project)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	if len(runs) == 0 {
+		logger.Printf("Ignoring webhook of project %s, it is not configured", e.Project)
+		return
+	}
+	logger.Printf("Milestone %s of %s was %s, reconciling", e.Milestone, e.Project, e.Action)
+	results, _ := reconcile(ctx, runs)
+	d.record("webhook", results)
+	err = utils.WriteResults(os.Stdout, results, d.output)
+	if err != nil {
+		logger.Println(err)
+	}
+}
+
+// webhook returns the handler of milestone webhooks of an API.
+// Verified events changing a milestone are accepted and their project reconciled in the background,
+// events arriving while a reconciliation of the project is pending are coalesced into it.
+func (d *daemon) webhook(api string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+		if err != nil {
+			http.Error(w, "could not read payload", http.StatusBadRequest)
+			return
+		}
+		d.mu.Lock()
+		c := d.config
+		d.mu.Unlock()
+		providers := webhookProviders(c, api, r, body)
+		if len(providers) == 0 {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var e utils.WebhookEvent
+		switch api {
+		case "github":
+			if r.Header.Get("X-GitHub-Event") != "milestone" {
+				fmt.Fprintln(w, "ignored")
+				return
+			}
+			e, err = utils.ParseGithubWebhook(body)
+		case "gitlab":
+			if r.Header.Get("X-Gitlab-Event") != "Milestone Hook" {
+				fmt.Fprintln(w, "ignored")
+				return
+			}
+			e, err = utils.ParseGitlabWebhook(body)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !e.Reconcile() {
+			fmt.Fprintln(w, "ignored")
+			return
+		}
+		if !d.enqueue(e.Project) {
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintln(w, "already queued")
+			return
+		}
+		go d.reconcileEvent(c, providers, e)
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, "accepted")
+	}
+}